@@ -132,7 +132,9 @@ func main() {
 			certInfo.Subject = endCert.Subject.CommonName
 			certInfo.Issuer = endCert.Issuer.String()
 			certInfo.SignatureAlg = endCert.SignatureAlgorithm.String()
-			certInfo.AlgWarning = utils.AlgWarnings(endCert)
+			certInfo.AlgWarnings = utils.AlgWarnings(goChain)
+			certInfo.AlgWarning = utils.AlgWarningString(certInfo.AlgWarnings)
+			utils.FillCertDetails(&certInfo, goChain)
 			certInfo.RawCerts = rawCerts
 
 			rec.CertInfo = &certInfo
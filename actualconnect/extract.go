@@ -14,7 +14,7 @@
 
 // // 从zgrab2/real文件夹下的各个.jsonl中提取出error==no such host的domain
 // func Extract_no_such_host() {
-// 	rootDir := "/home/wzq/scan-website/zgrab2/real" // 父级目录
+// 	rootDir := config.Get().Paths.ZGrabResultsDir // 父级目录
 // 	files, err := filepath.Glob(filepath.Join(rootDir, "*.jsonl"))
 // 	if err != nil {
 // 		fmt.Println("读取文件失败:", err)
@@ -109,6 +109,7 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"scan-website/config"
 	"strings"
 	"sync"
 
@@ -117,7 +118,7 @@ import (
 
 // 从 zgrab2/real 文件夹下的各个 .jsonl 中提取无法解析的 domain
 func Extract_no_such_host() {
-	rootDir := "/home/wzq/scan-website/zgrab2/real" // 父级目录
+	rootDir := config.Get().Paths.ZGrabResultsDir // 父级目录
 	files, err := filepath.Glob(filepath.Join(rootDir, "*.jsonl"))
 	if err != nil {
 		fmt.Println("读取文件失败:", err)
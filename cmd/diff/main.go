@@ -0,0 +1,37 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"scan-website/config"
+	"scan-website/measurement"
+)
+
+// 比较两次扫描的结果：有变化的域名写入 -out，整体变动统计写入 -stats
+func main() {
+	oldFile := flag.String("old", "", "earlier snapshot (.gz/.zst accepted)")
+	newFile := flag.String("new", "", "later snapshot (.gz/.zst accepted)")
+	kind := flag.String("kind", "init", "snapshot format: init (discover output) or check_dif (CheckDifferences output)")
+	output := flag.String("out", "", "per-domain change log, defaults to paths.diff_log")
+	statsFile := flag.String("stats", "", "aggregate churn stats, defaults to paths.diff_stats")
+	flag.Parse()
+
+	if *oldFile == "" || *newFile == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	cfg := config.Get()
+	if *output == "" {
+		*output = cfg.Paths.DiffLog
+	}
+	if *statsFile == "" {
+		*statsFile = cfg.Paths.DiffStats
+	}
+
+	if _, err := measurement.DiffScans(*oldFile, *newFile, *kind, *output, *statsFile); err != nil {
+		log.Fatalf("Diff failed: %v", err)
+	}
+	fmt.Printf("✅ Change log written to %s\n", *output)
+}
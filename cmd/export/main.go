@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"scan-website/config"
+	"scan-website/export"
+	"scan-website/pipeline"
+)
+
+// 导出扫描结果：-format parquet 把每次探测拆成一行写成 Parquet；
+// -format jsonl 按输出文件后缀逐行原样重写 JSONL，用于把已有结果无损地转成 .gz/.zst
+func main() {
+	input := flag.String("in", "", "result JSONL to read (.gz/.zst accepted), defaults to paths.init_jsonl")
+	output := flag.String("out", "probes.parquet", "output file")
+	format := flag.String("format", "parquet", "output format: parquet or jsonl")
+	flag.Parse()
+
+	if *input == "" {
+		*input = config.Get().Paths.InitJSONL
+	}
+
+	switch *format {
+	case "parquet":
+		rows, err := export.WriteParquet(*input, *output)
+		if err != nil {
+			log.Fatalf("Export failed: %v", err)
+		}
+		fmt.Printf("✅ %d probe rows written to %s\n", rows, *output)
+	case "jsonl":
+		count, err := recompress(*input, *output)
+		if err != nil {
+			log.Fatalf("Export failed: %v", err)
+		}
+		fmt.Printf("✅ %d results written to %s\n", count, *output)
+	default:
+		log.Fatalf("Unknown format %q, want parquet or jsonl", *format)
+	}
+}
+
+// 逐行原样复制，不解析成 DomainResult，旧版本结果中当前结构没有的字段和无法解析的行都会保留
+func recompress(input, output string) (int64, error) {
+	in, err := pipeline.Open(input)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open %s: %v", input, err)
+	}
+	defer in.Close()
+	writer, err := pipeline.NewWriter(output, false)
+	if err != nil {
+		return 0, err
+	}
+	br := bufio.NewReaderSize(in, 256*1024)
+	for {
+		line, rerr := br.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) > 0 {
+			if err := writer.WriteRaw(line); err != nil {
+				writer.Close()
+				return 0, err
+			}
+		}
+		if rerr == io.EOF {
+			break
+		}
+		if rerr != nil {
+			writer.Close()
+			return 0, fmt.Errorf("error reading %s: %v", input, rerr)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return 0, err
+	}
+	return writer.Count(), nil
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"scan-website/honeypot"
+	"time"
+)
+
+// 启动 Autodiscover 蜜罐；指定 -replay 时用扫描器自身的探测序列打一遍蜜罐并输出记录
+func main() {
+	httpAddr := flag.String("http", ":8080", "HTTP listen address")
+	httpsAddr := flag.String("https", ":8443", "HTTPS listen address")
+	certFile := flag.String("cert", "", "TLS certificate file (self-signed if empty)")
+	keyFile := flag.String("key", "", "TLS key file")
+	logFile := flag.String("log", "honeypot.jsonl", "JSONL file to append requests to")
+	replay := flag.String("replay", "", "domain to replay the Autodiscover probing sequence for")
+	flag.Parse()
+
+	f, err := os.OpenFile(*logFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		log.Fatalf("Failed to open log file: %v", err)
+	}
+	defer f.Close()
+
+	srv := &honeypot.Server{
+		HTTPAddr:  *httpAddr,
+		HTTPSAddr: *httpsAddr,
+		CertFile:  *certFile,
+		KeyFile:   *keyFile,
+		Log:       f,
+	}
+	if *replay == "" {
+		log.Fatal(srv.ListenAndServe())
+	}
+
+	go func() {
+		log.Fatal(srv.ListenAndServe())
+	}()
+	time.Sleep(500 * time.Millisecond) // 等待监听就绪
+
+	results := honeypot.Replay(*replay, "info@"+*replay, loopback(*httpAddr), loopback(*httpsAddr))
+	for _, r := range results {
+		fmt.Printf("%s[%d] %s -> %s\n", r.Method, r.Index, r.URI, r.Error)
+	}
+	out, _ := json.MarshalIndent(srv.Records(), "", "  ")
+	fmt.Println(string(out))
+}
+
+// ":8080" 这类只有端口的地址转成回环地址
+func loopback(addr string) string {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil || host != "" {
+		return addr
+	}
+	return net.JoinHostPort("127.0.0.1", port)
+}
@@ -22,5 +22,6 @@ func main() {
 	//measurement.Count()
 	//actualconnect.Extract_no_such_host()
 	//measurement.CountDomains_Certinfo("/home/wzq/scan-website/cmd/init.jsonl")
+	//measurement.DiffAnalysis() //config_dif.go
 	measurement.CheckDifferences()
 }
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"scan-website/config"
+	"scan-website/store"
+	"strings"
+	"text/tabwriter"
+)
+
+// 在 SQLite 结果库上运行保存的 SQL 报表或临时查询；-import 把已有的 JSONL 结果导入数据库
+func main() {
+	dbPath := flag.String("db", "", "SQLite result db, defaults to paths.sqlite_db")
+	report := flag.String("report", "", "name of a saved report in paths.reports")
+	query := flag.String("sql", "", "ad-hoc SQL to run")
+	list := flag.Bool("list", false, "list saved reports")
+	asCSV := flag.Bool("csv", false, "print results as CSV")
+	importFile := flag.String("import", "", "JSONL file to import (.gz/.zst accepted)")
+	kind := flag.String("kind", "init", "what -import reads: init (discover output), check or dane")
+	flag.Parse()
+
+	cfg := config.Get()
+	if *list {
+		reports, err := store.LoadReports(cfg.Paths.Reports)
+		if err != nil {
+			log.Fatalf("Failed to load reports: %v", err)
+		}
+		for _, r := range reports {
+			fmt.Printf("%-28s %s\n", r.Name, r.Description)
+		}
+		return
+	}
+
+	if *dbPath == "" {
+		*dbPath = cfg.Paths.SQLiteDB
+	}
+	if *dbPath == "" {
+		log.Fatal("No result db: pass -db or set paths.sqlite_db")
+	}
+	db, err := store.Open(*dbPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+
+	if *importFile != "" {
+		var n int
+		switch *kind {
+		case "init":
+			n, err = db.ImportDomainResults(*importFile)
+		case "check":
+			n, err = db.ImportCheckResults(*importFile)
+		case "dane":
+			n, err = db.ImportDANEResults(*importFile)
+		default:
+			log.Fatalf("Unknown -kind %q, want init, check or dane", *kind)
+		}
+		if err != nil {
+			log.Fatalf("Import failed after %d records: %v", n, err)
+		}
+		fmt.Printf("✅ Imported %d records from %s\n", n, *importFile)
+		return
+	}
+
+	sqlText := *query
+	if *report != "" {
+		reports, err := store.LoadReports(cfg.Paths.Reports)
+		if err != nil {
+			log.Fatalf("Failed to load reports: %v", err)
+		}
+		for _, r := range reports {
+			if r.Name == *report {
+				sqlText = r.SQL
+			}
+		}
+		if sqlText == "" {
+			log.Fatalf("Unknown report %q, see -list", *report)
+		}
+	}
+	if strings.TrimSpace(sqlText) == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	cols, rows, err := db.Query(sqlText)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *asCSV {
+		w := csv.NewWriter(os.Stdout)
+		w.Write(cols)
+		w.WriteAll(rows)
+		return
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, strings.Join(cols, "\t"))
+	for _, row := range rows {
+		fmt.Fprintln(w, strings.Join(row, "\t"))
+	}
+	w.Flush()
+}
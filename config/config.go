@@ -0,0 +1,367 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 全局配置：默认值 -> 配置文件 -> 环境变量，后者覆盖前者。
+// 配置文件路径取环境变量 SCAN_CONFIG，未设置时尝试当前目录下的 config.json
+
+const DefaultFile = "config.json"
+
+type Config struct {
+	Paths       Paths             `json:"paths"`
+	TrustStores map[string]string `json:"trust_stores"` // 信任库名称 -> PEM 文件，路径为空的跳过
+	Timeouts    Timeouts          `json:"timeouts"`
+	Concurrency Concurrency       `json:"concurrency"`
+	Resolvers   []string          `json:"resolvers"` // 多个时轮流使用
+
+	UseSystemStore   bool `json:"use_system_store"`   // 是否把系统信任库作为 "system" 加入验证
+	FetchAIA         bool `json:"fetch_aia"`          // 是否通过 AIA 联网补全缺失的中间证书
+	ValidateDNSSEC   bool `json:"validate_dnssec"`    // 是否在本地验证 SRV/MX/TLSA 应答的 DNSSEC 信任链
+	AutoconfigAllMX  bool `json:"autoconfig_all_mx"`  // Autoconfig 的 MX 方法是否遍历所有不同可注册域名的 MX，否则只用优先级最高的
+	EmulateClients   bool `json:"emulate_clients"`    // 是否按 Thunderbird/Outlook 的猜测顺序模拟客户端最终得到的配置
+	ProbeIPFamilies  bool `json:"probe_ip_families"`  // 是否对每个配置目标分别用 IPv4 和 IPv6 地址测试连通性
+	ProbeTLSVersions bool `json:"probe_tls_versions"` // 是否额外探测各配置服务器接受的 TLS 版本（每个主机多 4 次握手）
+
+	IPFamily string `json:"ip_family"` // 强制所有连接只用 "4" 或 "6"，为空时由系统决定
+
+	ProgressInterval Duration `json:"progress_interval"` // measurement 各轮处理打印进度的间隔，0 表示不打印
+
+	resolverIdx uint32
+}
+
+type Paths struct {
+	DomainsCSV      string `json:"domains_csv"`       // discover.Process 的输入
+	InitJSONL       string `json:"init_jsonl"`        // discover.Process 的输出，也是各统计的输入
+	CertsJSONL      string `json:"certs_jsonl"`       // 去重后的证书库
+	CheckResults    string `json:"check_results"`     // measurement.Check 的输出
+	CheckDifResults string `json:"check_dif_results"` // measurement.CheckDifferences 的输出
+	CertStats       string `json:"cert_stats"`        // measurement.CountDomains_Certinfo 的输出
+	TLSCheckScript  string `json:"tls_check_script"`  // tlscheck/test_tls.py
+	Python          string `json:"python"`            // 运行 TLSCheckScript 的解释器
+	ZGrabResultsDir string `json:"zgrab_results_dir"` // actualconnect 读取的 zgrab2 结果目录
+	DANEResults     string `json:"dane_results"`      // measurement.CheckDANE 的输出
+	ProviderRules   string `json:"provider_rules"`    // 邮件服务商识别规则
+	IPv6Stats       string `json:"ipv6_stats"`        // measurement.CountIPv6Readiness 的输出
+	SQLiteDB        string `json:"sqlite_db"`         // 可选的 SQLite 结果库，为空时不写
+	Reports         string `json:"reports"`           // cmd/query 使用的 SQL 报表目录
+	DiffLog         string `json:"diff_log"`          // cmd/diff 输出的逐域名变化日志
+	DiffStats       string `json:"diff_stats"`        // cmd/diff 输出的整体变动统计
+}
+
+type Timeouts struct {
+	HTTP     Duration `json:"http"`      // 获取配置的 HTTP 请求
+	Dial     Duration `json:"dial"`      // 建立 TCP 连接
+	DNS      Duration `json:"dns"`       // 单次 DNS 查询
+	Guess    Duration `json:"guess"`     // 猜测邮件服务器时的端口探测
+	TLSProbe Duration `json:"tls_probe"` // 每个 TLS 版本的握手
+}
+
+type Concurrency struct {
+	Discover  int `json:"discover"`   // discover.Process 同时处理的域名数
+	BatchSize int `json:"batch_size"` // 每批写入 init.jsonl 的结果数
+	Guess     int `json:"guess"`      // 单个域名猜测邮件服务器时的并发连接数
+	Check     int `json:"check"`      // measurement 中解析/比较配置的并发数
+	Stats     int `json:"stats"`      // measurement 中只做统计的并发数
+}
+
+// Duration 在 JSON 中写作 "15s"、"500ms"，也接受表示秒数的数字
+type Duration time.Duration
+
+func (d Duration) Std() time.Duration {
+	return time.Duration(d)
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var v interface{}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	switch val := v.(type) {
+	case float64:
+		*d = Duration(val * float64(time.Second))
+	case string:
+		parsed, err := time.ParseDuration(val)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q: %v", val, err)
+		}
+		*d = Duration(parsed)
+	default:
+		return fmt.Errorf("invalid duration: %s", string(b))
+	}
+	return nil
+}
+
+// Default 返回默认配置，路径都相对于当前工作目录
+func Default() *Config {
+	return &Config{
+		Paths: Paths{
+			DomainsCSV:      "domains.csv",
+			InitJSONL:       "init.jsonl",
+			CertsJSONL:      "certs.jsonl",
+			CheckResults:    "check_results.jsonl",
+			CheckDifResults: "check_dif_results.jsonl",
+			CertStats:       "cert_stats.json",
+			TLSCheckScript:  "tlscheck/test_tls.py",
+			Python:          "python3",
+			ZGrabResultsDir: "zgrab2/real",
+			DANEResults:     "dane_results.jsonl",
+			ProviderRules:   "providers.json",
+			IPv6Stats:       "ipv6_stats.json",
+			Reports:         "reports",
+			DiffLog:         "scan_diff.jsonl",
+			DiffStats:       "scan_diff_stats.json",
+		},
+		TrustStores: map[string]string{
+			"mozilla": "IncludedRootsPEM313.txt",
+		},
+		Timeouts: Timeouts{
+			HTTP:     Duration(15 * time.Second),
+			Dial:     Duration(15 * time.Second),
+			DNS:      Duration(5 * time.Second), // CNAME 查询最多重试 3 次，沿用原来 CNAME/Autodiscover SRV 的 5s
+			Guess:    Duration(2 * time.Second),
+			TLSProbe: Duration(5 * time.Second),
+		},
+		Concurrency: Concurrency{
+			Discover:  200,
+			BatchSize: 500,
+			Guess:     20,
+			Check:     10,
+			Stats:     50,
+		},
+		Resolvers:        []string{"8.8.8.8:53"},
+		FetchAIA:         true,
+		ProgressInterval: Duration(10 * time.Second),
+	}
+}
+
+// Load 在默认配置基础上读取配置文件（fileName 为空时跳过），再应用环境变量
+func Load(fileName string) (*Config, error) {
+	cfg := Default()
+	if fileName != "" {
+		data, err := os.ReadFile(fileName)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read config file: %v", err)
+		}
+		if err := json.Unmarshal(data, cfg); err != nil {
+			return nil, fmt.Errorf("failed to parse config file %s: %v", fileName, err)
+		}
+	}
+	if err := cfg.applyEnv(); err != nil {
+		return nil, err
+	}
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// 合并后的配置检查：并发数和批大小为 0 会让信号量死锁或每条结果都刷盘，超时为 0 则没有截止时间
+func (c *Config) validate() error {
+	ints := []struct {
+		name string
+		v    int
+	}{
+		{"concurrency.discover", c.Concurrency.Discover},
+		{"concurrency.batch_size", c.Concurrency.BatchSize},
+		{"concurrency.guess", c.Concurrency.Guess},
+		{"concurrency.check", c.Concurrency.Check},
+		{"concurrency.stats", c.Concurrency.Stats},
+	}
+	for _, f := range ints {
+		if f.v <= 0 {
+			return fmt.Errorf("invalid %s: %d, must be positive", f.name, f.v)
+		}
+	}
+	durations := []struct {
+		name string
+		v    Duration
+	}{
+		{"timeouts.http", c.Timeouts.HTTP},
+		{"timeouts.dial", c.Timeouts.Dial},
+		{"timeouts.dns", c.Timeouts.DNS},
+		{"timeouts.guess", c.Timeouts.Guess},
+		{"timeouts.tls_probe", c.Timeouts.TLSProbe},
+	}
+	for _, f := range durations {
+		if f.v <= 0 {
+			return fmt.Errorf("invalid %s: %s, must be positive", f.name, f.v.Std())
+		}
+	}
+	if c.ProgressInterval < 0 {
+		return fmt.Errorf("invalid progress_interval: %s, must not be negative", c.ProgressInterval.Std())
+	}
+	if len(c.Resolvers) == 0 {
+		return fmt.Errorf("no DNS resolver configured")
+	}
+	return nil
+}
+
+// 环境变量覆盖，名称为 SCAN_ 加大写的字段名
+func (c *Config) applyEnv() error {
+	strs := map[string]*string{
+		"SCAN_DOMAINS_CSV":       &c.Paths.DomainsCSV,
+		"SCAN_INIT_JSONL":        &c.Paths.InitJSONL,
+		"SCAN_CERTS_JSONL":       &c.Paths.CertsJSONL,
+		"SCAN_CHECK_RESULTS":     &c.Paths.CheckResults,
+		"SCAN_CHECK_DIF_RESULTS": &c.Paths.CheckDifResults,
+		"SCAN_CERT_STATS":        &c.Paths.CertStats,
+		"SCAN_TLS_CHECK_SCRIPT":  &c.Paths.TLSCheckScript,
+		"SCAN_PYTHON":            &c.Paths.Python,
+		"SCAN_ZGRAB_RESULTS_DIR": &c.Paths.ZGrabResultsDir,
+		"SCAN_DANE_RESULTS":      &c.Paths.DANEResults,
+		"SCAN_PROVIDER_RULES":    &c.Paths.ProviderRules,
+		"SCAN_IPV6_STATS":        &c.Paths.IPv6Stats,
+		"SCAN_SQLITE_DB":         &c.Paths.SQLiteDB,
+		"SCAN_REPORTS":           &c.Paths.Reports,
+		"SCAN_DIFF_LOG":          &c.Paths.DiffLog,
+		"SCAN_DIFF_STATS":        &c.Paths.DiffStats,
+	}
+	for name, p := range strs {
+		if v, ok := os.LookupEnv(name); ok {
+			*p = v
+		}
+	}
+
+	durations := map[string]*Duration{
+		"SCAN_HTTP_TIMEOUT":      &c.Timeouts.HTTP,
+		"SCAN_DIAL_TIMEOUT":      &c.Timeouts.Dial,
+		"SCAN_DNS_TIMEOUT":       &c.Timeouts.DNS,
+		"SCAN_GUESS_TIMEOUT":     &c.Timeouts.Guess,
+		"SCAN_TLS_PROBE_TIMEOUT": &c.Timeouts.TLSProbe,
+		"SCAN_PROGRESS_INTERVAL": &c.ProgressInterval,
+	}
+	for name, p := range durations {
+		if v, ok := os.LookupEnv(name); ok {
+			d, err := time.ParseDuration(v)
+			if err != nil {
+				return fmt.Errorf("invalid %s: %v", name, err)
+			}
+			*p = Duration(d)
+		}
+	}
+
+	ints := map[string]*int{
+		"SCAN_DISCOVER_CONCURRENCY": &c.Concurrency.Discover,
+		"SCAN_BATCH_SIZE":           &c.Concurrency.BatchSize,
+		"SCAN_GUESS_CONCURRENCY":    &c.Concurrency.Guess,
+		"SCAN_CHECK_CONCURRENCY":    &c.Concurrency.Check,
+		"SCAN_STATS_CONCURRENCY":    &c.Concurrency.Stats,
+	}
+	for name, p := range ints {
+		if v, ok := os.LookupEnv(name); ok {
+			n, err := strconv.Atoi(v)
+			if err != nil || n <= 0 {
+				return fmt.Errorf("invalid %s: %q", name, v)
+			}
+			*p = n
+		}
+	}
+
+	bools := map[string]*bool{
+		"SCAN_USE_SYSTEM_STORE":   &c.UseSystemStore,
+		"SCAN_FETCH_AIA":          &c.FetchAIA,
+		"SCAN_VALIDATE_DNSSEC":    &c.ValidateDNSSEC,
+		"SCAN_AUTOCONFIG_ALL_MX":  &c.AutoconfigAllMX,
+		"SCAN_EMULATE_CLIENTS":    &c.EmulateClients,
+		"SCAN_PROBE_IP_FAMILIES":  &c.ProbeIPFamilies,
+		"SCAN_PROBE_TLS_VERSIONS": &c.ProbeTLSVersions,
+	}
+	for name, p := range bools {
+		if v, ok := os.LookupEnv(name); ok {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return fmt.Errorf("invalid %s: %q", name, v)
+			}
+			*p = b
+		}
+	}
+
+	if v, ok := os.LookupEnv("SCAN_IP_FAMILY"); ok {
+		c.IPFamily = v
+	}
+	if c.IPFamily != "" && c.IPFamily != "4" && c.IPFamily != "6" {
+		return fmt.Errorf("invalid ip_family %q, want \"4\", \"6\" or empty", c.IPFamily)
+	}
+
+	// 逗号分隔，如 "8.8.8.8:53,1.1.1.1:53"
+	if v, ok := os.LookupEnv("SCAN_RESOLVERS"); ok {
+		c.Resolvers = nil
+		for _, r := range strings.Split(v, ",") {
+			if r = strings.TrimSpace(r); r != "" {
+				c.Resolvers = append(c.Resolvers, r)
+			}
+		}
+	}
+	// 形如 "mozilla=/path/a.pem,microsoft=/path/b.pem"，覆盖整个信任库列表
+	if v, ok := os.LookupEnv("SCAN_TRUST_STORES"); ok {
+		c.TrustStores = make(map[string]string)
+		for _, kv := range strings.Split(v, ",") {
+			name, path, found := strings.Cut(strings.TrimSpace(kv), "=")
+			if !found || name == "" {
+				return fmt.Errorf("invalid SCAN_TRUST_STORES entry: %q", kv)
+			}
+			c.TrustStores[name] = path
+		}
+	}
+	return nil
+}
+
+// Network 返回拨号使用的网络名：tcp4 / tcp6 / tcp
+func (c *Config) Network() string {
+	switch c.IPFamily {
+	case "4":
+		return "tcp4"
+	case "6":
+		return "tcp6"
+	}
+	return "tcp"
+}
+
+// Resolver 返回下一个 DNS 服务器地址（host:port）
+func (c *Config) Resolver() string {
+	i := atomic.AddUint32(&c.resolverIdx, 1) - 1
+	return c.Resolvers[int(i)%len(c.Resolvers)]
+}
+
+var (
+	current     *Config
+	currentOnce sync.Once
+)
+
+// Get 返回全局配置，首次调用时加载；配置文件有误时直接退出，避免用错误的参数跑完整轮扫描
+func Get() *Config {
+	currentOnce.Do(func() {
+		fileName := os.Getenv("SCAN_CONFIG")
+		if fileName == "" {
+			if _, err := os.Stat(DefaultFile); err == nil {
+				fileName = DefaultFile
+			}
+		}
+		cfg, err := Load(fileName)
+		if err != nil {
+			log.Fatalf("Failed to load config: %v", err)
+		}
+		current = cfg
+	})
+	return current
+}
+
+// Set 替换全局配置（需在任何扫描开始前调用）
+func Set(cfg *Config) {
+	currentOnce.Do(func() {})
+	current = cfg
+}
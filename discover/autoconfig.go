@@ -1,20 +1,19 @@
 package discover
 
 import (
-	"crypto/tls"
-	"encoding/base64"
 	"encoding/xml"
 	"fmt"
 	"io"
 	"net/http"
+	"scan-website/config"
 	"scan-website/models"
 	"scan-website/utils"
-	"time"
 )
 
 // 查询Autoconfig部分
-func QueryAutoconfig(domain string, email string) []models.AutoconfigResult {
+func QueryAutoconfig(domain string, email string, mx *models.MXResult) []models.AutoconfigResult {
 	var results []models.AutoconfigResult
+	allMX := config.Get().AutoconfigAllMX
 	//method1 直接通过url发送get请求得到config
 	urls := []string{
 		fmt.Sprintf("https://autoconfig.%s/mail/config-v1.1.xml?emailaddress=%s", domain, email),             //uri1
@@ -58,89 +57,91 @@ func QueryAutoconfig(domain string, email string) []models.AutoconfigResult {
 	}
 	results = append(results, result_ISPDB)
 
-	//method3 MX查询
-	mxHost, err := utils.ResolveMXRecord(domain)
+	//method3 MX查询，mx 为空时在这里查询
+	if mx == nil {
+		var err error
+		if mx, err = utils.LookupMX(domain); err != nil {
+			mx = &models.MXResult{Error: err.Error()}
+		}
+	}
+	switch {
+	case mx.Error != "":
+		results = append(results, models.AutoconfigResult{
+			Domain: domain,
+			Method: "MX",
+			Index:  0,
+			Error:  fmt.Sprintf("Resolve MX Record error for %s: %v", domain, mx.Error),
+		})
+	case mx.NullMX:
+		results = append(results, models.AutoconfigResult{
+			Domain: domain,
+			Method: "MX",
+			Index:  0,
+			Error:  fmt.Sprintf("null MX for %s", domain),
+		})
+	default:
+		for _, mxHost := range utils.MXDomains(mx, allMX) {
+			results = append(results, queryAutoconfigMX(domain, email, mxHost)...)
+		}
+	}
+	return results
+
+}
+
+// 用一个 MX 主机推出的域名（%MXFULLDOMAIN%/%MXMAINDOMAIN%）获取配置
+func queryAutoconfigMX(domain string, email string, mxHost string) []models.AutoconfigResult {
+	var results []models.AutoconfigResult
+	mxFullDomain, mxMainDomain, err := utils.ExtractDomains(mxHost)
 	if err != nil {
-		result_MX := models.AutoconfigResult{
+		return append(results, models.AutoconfigResult{
 			Domain: domain,
 			Method: "MX",
 			Index:  0,
-			Error:  fmt.Sprintf("Resolve MX Record error for %s: %v", domain, err),
+			MXHost: mxHost,
+			Error:  fmt.Sprintf("extract domain from mxHost error for %s: %v", domain, err),
+		})
+	}
+	method := "MX"
+	var urls []string
+	var indexes []int
+	if mxFullDomain == mxMainDomain {
+		method = "MX_samedomain"
+		urls = []string{
+			fmt.Sprintf("https://autoconfig.%s/mail/config-v1.1.xml?emailaddress=%s", mxFullDomain, email), //1
+			fmt.Sprintf("https://autoconfig.thunderbird.net/v1.1/%s", mxFullDomain),                        //3
 		}
-		results = append(results, result_MX)
+		indexes = []int{1, 3}
 	} else {
-		mxFullDomain, mxMainDomain, err := utils.ExtractDomains(mxHost)
+		urls = []string{
+			fmt.Sprintf("https://autoconfig.%s/mail/config-v1.1.xml?emailaddress=%s", mxFullDomain, email), //1
+			fmt.Sprintf("https://autoconfig.%s/mail/config-v1.1.xml?emailaddress=%s", mxMainDomain, email), //2
+			fmt.Sprintf("https://autoconfig.thunderbird.net/v1.1/%s", mxFullDomain),                        //3
+			fmt.Sprintf("https://autoconfig.thunderbird.net/v1.1/%s", mxMainDomain),                        //4
+		}
+		indexes = []int{1, 2, 3, 4}
+	}
+	for i, url := range urls {
+		config, redirects, certinfo, err := Get_autoconfig_config(domain, url, method, indexes[i])
+		result := models.AutoconfigResult{
+			Domain:    domain,
+			Method:    method,
+			Index:     indexes[i],
+			MXHost:    mxHost,
+			URI:       url,
+			Redirects: redirects,
+			Config:    config,
+			CertInfo:  certinfo,
+		}
 		if err != nil {
-			result_MX := models.AutoconfigResult{
-				Domain: domain,
-				Method: "MX",
-				Index:  0,
-				Error:  fmt.Sprintf("extract domain from mxHost error for %s: %v", domain, err),
-			}
-			results = append(results, result_MX)
-		} else {
-			if mxFullDomain == mxMainDomain {
-				urls := []string{
-					fmt.Sprintf("https://autoconfig.%s/mail/config-v1.1.xml?emailaddress=%s", mxFullDomain, email), //1
-					fmt.Sprintf("https://autoconfig.thunderbird.net/v1.1/%s", mxFullDomain),                        //3
-				}
-				for i, url := range urls {
-					config, redirects, certinfo, err := Get_autoconfig_config(domain, url, "MX_samedomain", i*2+1)
-					result := models.AutoconfigResult{
-						Domain:    domain,
-						Method:    "MX_samedomain",
-						Index:     i*2 + 1,
-						URI:       url,
-						Redirects: redirects,
-						Config:    config,
-						CertInfo:  certinfo,
-					}
-					if err != nil {
-						result.Error = err.Error()
-					}
-					results = append(results, result)
-				}
-			} else {
-				urls := []string{
-					fmt.Sprintf("https://autoconfig.%s/mail/config-v1.1.xml?emailaddress=%s", mxFullDomain, email), //1
-					fmt.Sprintf("https://autoconfig.%s/mail/config-v1.1.xml?emailaddress=%s", mxMainDomain, email), //2
-					fmt.Sprintf("https://autoconfig.thunderbird.net/v1.1/%s", mxFullDomain),                        //3
-					fmt.Sprintf("https://autoconfig.thunderbird.net/v1.1/%s", mxMainDomain),                        //4
-				}
-				for i, url := range urls {
-					config, redirects, certinfo, err := Get_autoconfig_config(domain, url, "MX", i+1)
-					result := models.AutoconfigResult{
-						Domain:    domain,
-						Method:    "MX",
-						Index:     i + 1,
-						URI:       url,
-						Redirects: redirects,
-						Config:    config,
-						CertInfo:  certinfo,
-					}
-					if err != nil {
-						result.Error = err.Error()
-					}
-					results = append(results, result)
-				}
-			}
+			result.Error = err.Error()
 		}
-
+		results = append(results, result)
 	}
 	return results
-
 }
 
 func Get_autoconfig_config(domain string, url string, method string, index int) (string, []map[string]interface{}, *models.CertInfo, error) {
-	client := &http.Client{
-		Transport: &http.Transport{
-			TLSClientConfig: &tls.Config{
-				InsecureSkipVerify: true,
-				MinVersion:         tls.VersionTLS10,
-			},
-		},
-		Timeout: 15 * time.Second,
-	}
+	client := newHTTPClient(true)
 	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
 		return "", []map[string]interface{}{}, nil, err
@@ -153,10 +154,12 @@ func Get_autoconfig_config(domain string, url string, method string, index int)
 	// 获取重定向历史记录
 	redirects := utils.GetRedirects(resp)
 	defer resp.Body.Close()
+	// 证书信息与响应内容无关，重定向过程中每一跳的证书记录在 redirects 中
+	certInfo := utils.BuildCertInfo(resp.TLS, resp.Request.URL.Hostname())
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return "", redirects, nil, fmt.Errorf("failed to read response body: %v", err)
+		return "", redirects, certInfo, fmt.Errorf("failed to read response body: %v", err)
 	}
 	var autoconfigResp models.AutoconfigResponse
 	err = xml.Unmarshal(body, &autoconfigResp)
@@ -164,54 +167,14 @@ func Get_autoconfig_config(domain string, url string, method string, index int)
 		// if (strings.HasPrefix(strings.TrimSpace(string(body)), `<?xml version="1.0"`) || strings.HasPrefix(strings.TrimSpace(string(body)), `<clientConfig`)) && !strings.Contains(strings.TrimSpace(string(body)), `<html`) && !strings.Contains(strings.TrimSpace(string(body)), `<item`) && !strings.Contains(strings.TrimSpace(string(body)), `lastmod`) && !strings.Contains(strings.TrimSpace(string(body)), `lt`) {
 		// 	saveno_XMLToFile("no_autoconfig_config.xml", string(body), domain)
 		// }
-		return "", redirects, nil, fmt.Errorf("failed to unmarshal XML: %v", err)
+		return "", redirects, certInfo, fmt.Errorf("failed to unmarshal XML: %v", err)
 	} else {
-		var certInfo models.CertInfo
-		// 提取证书信息
-		if resp.TLS != nil {
-			//var encodedData []byte
-			var encodedCerts []string
-			goChain := resp.TLS.PeerCertificates
-			endCert := goChain[0]
-
-			// 证书验证
-			dnsName := resp.Request.URL.Hostname()
-			var VerifyError error
-			certInfo.IsTrusted, VerifyError = utils.VerifyCertificate(goChain, dnsName)
-			if VerifyError != nil {
-				certInfo.VerifyError = VerifyError.Error()
-			} else {
-				certInfo.VerifyError = ""
-			}
-			certInfo.IsExpired = endCert.NotAfter.Before(time.Now())
-			certInfo.IsHostnameMatch = utils.VerifyHostname(endCert, dnsName)
-			certInfo.IsSelfSigned = utils.IsSelfSigned(endCert)
-			certInfo.IsInOrder = utils.IsChainInOrder(goChain)
-			certInfo.TLSVersion = resp.TLS.Version
-
-			// 提取证书的其他信息
-			certInfo.Subject = endCert.Subject.CommonName
-			certInfo.Issuer = endCert.Issuer.String()
-			certInfo.SignatureAlg = endCert.SignatureAlgorithm.String()
-			certInfo.AlgWarning = utils.AlgWarnings(endCert)
-
-			// 将证书编码为 base64 格式
-			for _, cert := range goChain {
-				encoded := base64.StdEncoding.EncodeToString(cert.Raw)
-				//encodedData = append(encodedData, []byte(encoded)...)
-				encodedCerts = append(encodedCerts, encoded)
-			}
-			//certInfo.RawCert = encodedData
-			certInfo.RawCerts = encodedCerts
-
-		}
-
 		config := string(body)
 		// outputfile := fmt.Sprintf("./autoconfig/autoconfig_%s_%d.xml", method, index) //12.18 用Index加以区分
 		// err = saveXMLToFile_autoconfig(outputfile, config, domain)
 		// if err != nil {
 		// 	return "", redirects, &certInfo, err
 		// }
-		return config, redirects, &certInfo, nil
+		return config, redirects, certInfo, nil
 	}
 }
@@ -2,8 +2,6 @@ package discover
 
 import (
 	"bytes"
-	"crypto/tls"
-	"encoding/base64"
 	"encoding/xml"
 	"fmt"
 	"io"
@@ -11,7 +9,6 @@ import (
 	"net/url"
 	"scan-website/models"
 	"scan-website/utils"
-	"time"
 )
 
 func QueryAutodiscover(domain string, email string) []models.AutodiscoverResult {
@@ -139,18 +136,7 @@ func getAutodiscoverConfig(origin_domain string, uri string, email_add string, m
 		return flag1, flag2, flag3, []map[string]interface{}{}, "", nil, fmt.Errorf("failed to create request: %v", err)
 	}
 	req.Header.Set("Content-Type", "text/xml")
-	client := &http.Client{
-		Transport: &http.Transport{
-			TLSClientConfig: &tls.Config{
-				InsecureSkipVerify: true,
-				MinVersion:         tls.VersionTLS10,
-			},
-		},
-		CheckRedirect: func(req *http.Request, via []*http.Request) error {
-			return http.ErrUseLastResponse // 禁止重定向
-		},
-		Timeout: 15 * time.Second,
-	}
+	client := newHTTPClient(false)
 
 	resp, err := client.Do(req)
 	if err != nil {
@@ -160,6 +146,8 @@ func getAutodiscoverConfig(origin_domain string, uri string, email_add string, m
 
 	redirects := utils.GetRedirects(resp) // 获取当前重定向链
 	defer resp.Body.Close()               //
+	// 无论响应内容如何都记录本跳的证书信息
+	certInfo := utils.BuildCertInfo(resp.TLS, resp.Request.URL.Hostname())
 	if resp.StatusCode == http.StatusFound || resp.StatusCode == http.StatusMovedPermanently {
 		// 处理重定向
 		flag1 = flag1 + 1
@@ -167,15 +155,15 @@ func getAutodiscoverConfig(origin_domain string, uri string, email_add string, m
 		location := resp.Header.Get("Location")
 		fmt.Printf("Redirect to: %s\n", location)
 		if location == "" {
-			return flag1, flag2, flag3, redirects, "", nil, fmt.Errorf("missing Location header in redirect")
+			return flag1, flag2, flag3, redirects, "", certInfo, fmt.Errorf("missing Location header in redirect")
 		} else if flag1 > 10 { //12.27限制重定向次数
 			//saveXMLToFile_autodiscover("./location.xml", origin_domain, email_add)
-			return flag1, flag2, flag3, redirects, "", nil, fmt.Errorf("too many redirect times")
+			return flag1, flag2, flag3, redirects, "", certInfo, fmt.Errorf("too many redirect times")
 		}
 
 		newURI, err := url.Parse(location)
 		if err != nil {
-			return flag1, flag2, flag3, redirects, "", nil, fmt.Errorf("failed to parse redirect URL: %s", location)
+			return flag1, flag2, flag3, redirects, "", certInfo, fmt.Errorf("failed to parse redirect URL: %s", location)
 		}
 
 		// 递归调用并合并重定向链
@@ -186,7 +174,7 @@ func getAutodiscoverConfig(origin_domain string, uri string, email_add string, m
 		// 处理成功响应
 		body, err := io.ReadAll(resp.Body)
 		if err != nil {
-			return flag1, flag2, flag3, redirects, "", nil, fmt.Errorf("failed to read response body: %v", err)
+			return flag1, flag2, flag3, redirects, "", certInfo, fmt.Errorf("failed to read response body: %v", err)
 		}
 
 		var autodiscoverResp models.AutodiscoverResponse
@@ -197,7 +185,7 @@ func getAutodiscoverConfig(origin_domain string, uri string, email_add string, m
 			// 	//if !strings.Contains(strings.TrimSpace(string(body)), `<html`) && !strings.Contains(strings.TrimSpace(string(body)), `<item`) && !strings.Contains(strings.TrimSpace(string(body)), `lastmod`) && !strings.Contains(strings.TrimSpace(string(body)), `lt`) {
 			// 	//saveno_XMLToFile("no_autodiscover_config.xml", string(body), email_add)
 			// } //记录错误格式的xml
-			return flag1, flag2, flag3, redirects, "", nil, fmt.Errorf("failed to unmarshal XML: %v", err)
+			return flag1, flag2, flag3, redirects, "", certInfo, fmt.Errorf("failed to unmarshal XML: %v", err)
 		}
 
 		// 处理 redirectAddr 和 redirectUrl
@@ -211,9 +199,9 @@ func getAutodiscoverConfig(origin_domain string, uri string, email_add string, m
 				return newflag1, newflag2, newflag3, append(redirects, nextRedirects...), result, certinfo, err
 			} else if newEmail != "" { //12.27
 				//saveXMLToFile_autodiscover("./flag2.xml", origin_domain, email_add)
-				return flag1, flag2, flag3, redirects, "", nil, fmt.Errorf("too many RedirectAddr")
+				return flag1, flag2, flag3, redirects, "", certInfo, fmt.Errorf("too many RedirectAddr")
 			} else {
-				return flag1, flag2, flag3, redirects, "", nil, fmt.Errorf("nil ReAddr")
+				return flag1, flag2, flag3, redirects, "", certInfo, fmt.Errorf("nil ReAddr")
 			}
 		} else if autodiscoverResp.Response.Account.Action == "redirectUrl" {
 			flag3 = flag3 + 1
@@ -225,92 +213,41 @@ func getAutodiscoverConfig(origin_domain string, uri string, email_add string, m
 				return newflag1, newflag2, newflag3, append(redirects, nextRedirects...), result, certinfo, err
 			} else if newUri != "" {
 				//saveXMLToFile_autodiscover("./flag3.xml", origin_domain, email_add)
-				return flag1, flag2, flag3, redirects, "", nil, fmt.Errorf("too many RedirectUrl")
+				return flag1, flag2, flag3, redirects, "", certInfo, fmt.Errorf("too many RedirectUrl")
 			} else {
-				return flag1, flag2, flag3, redirects, "", nil, fmt.Errorf("nil Reuri")
+				return flag1, flag2, flag3, redirects, "", certInfo, fmt.Errorf("nil Reuri")
 			}
 		} else if autodiscoverResp.Response.Account.Action == "settings" { //这才是我们需要的
 			// 记录并返回成功配置(3.13修改，因为会将Response命名空间不合规的也解析到这里)
 			// outputfile := fmt.Sprintf("./autodiscover/autodiscover_%s_%d_config.xml", method, index)
 			// saveXMLToFile_autodiscover(outputfile, string(body), email_add)
 
-			//只在可以直接返回xml配置的时候记录证书信息
-			var certInfo models.CertInfo
-			// 提取证书信息
-			if resp.TLS != nil {
-				//var encodedData []byte //8.15
-				var encodedCerts []string //8.15
-				goChain := resp.TLS.PeerCertificates
-				endCert := goChain[0]
-
-				// 证书验证
-				dnsName := resp.Request.URL.Hostname()
-				var VerifyError error
-				certInfo.IsTrusted, VerifyError = utils.VerifyCertificate(goChain, dnsName)
-				if VerifyError != nil {
-					certInfo.VerifyError = VerifyError.Error()
-				} else {
-					certInfo.VerifyError = ""
-				}
-
-				certInfo.IsExpired = endCert.NotAfter.Before(time.Now())
-				certInfo.IsHostnameMatch = utils.VerifyHostname(endCert, dnsName)
-				certInfo.IsSelfSigned = utils.IsSelfSigned(endCert)
-				certInfo.IsInOrder = utils.IsChainInOrder(goChain)
-				certInfo.TLSVersion = resp.TLS.Version
-
-				// 提取证书的其他信息
-				certInfo.Subject = endCert.Subject.CommonName
-				certInfo.Issuer = endCert.Issuer.String()
-				certInfo.SignatureAlg = endCert.SignatureAlgorithm.String()
-				certInfo.AlgWarning = utils.AlgWarnings(endCert)
-
-				// 将证书编码为 base64 格式
-				for _, cert := range goChain {
-					encoded := base64.StdEncoding.EncodeToString(cert.Raw)
-					//encodedData = append(encodedData, []byte(encoded)...)//8.16
-					encodedCerts = append(encodedCerts, encoded)
-				}
-				//certInfo.RawCert = encodedData //8.15
-				certInfo.RawCerts = encodedCerts
-			}
-			return flag1, flag2, flag3, redirects, string(body), &certInfo, nil
+			return flag1, flag2, flag3, redirects, string(body), certInfo, nil
 		} else if autodiscoverResp.Response.Error != nil {
 			//fmt.Printf("Error: %s\n", string(body))
 			// 处理错误响应
 			errorConfig := fmt.Sprintf("Errorcode:%d-%s\n", autodiscoverResp.Response.Error.ErrorCode, autodiscoverResp.Response.Error.Message)
 			//outputfile := fmt.Sprintf("./autodiscover/autodiscover_%s_%d_Errorconfig.txt", method, index)
 			//saveXMLToFile_autodiscover(outputfile, errorConfig, email_add)
-			return flag1, flag2, flag3, redirects, errorConfig, nil, nil
+			return flag1, flag2, flag3, redirects, errorConfig, certInfo, nil
 		} else {
 			//fmt.Printf("Response element not valid:%s\n", string(body))
 			//处理Response可能本身就不正确的响应,同时也会存储不合规的xml(unmarshal的时候合规但Response不合规)
 			alsoErrorConfig := fmt.Sprintf("Non-valid Response element for %s\n:", email_add)
 			//outputfile := fmt.Sprintf("./autodiscover/autodiscover_%s_%d_AlsoErrorConfig.xml", method, index)
 			//saveXMLToFile_autodiscover(outputfile, string(body), email_add)
-			return flag1, flag2, flag3, redirects, alsoErrorConfig, nil, nil
+			return flag1, flag2, flag3, redirects, alsoErrorConfig, certInfo, nil
 		}
 	} else {
 		// 处理非成功响应
 		//outputfile := fmt.Sprintf("./autodiscover/autodiscover_%s_%d_badresponse.txt", method, index)
 		badResponse := fmt.Sprintf("Bad response for %s: %d\n", email_add, resp.StatusCode)
 		//saveXMLToFile_autodiscover(outputfile, badResponse, email_add)
-		return flag1, flag2, flag3, redirects, badResponse, nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+		return flag1, flag2, flag3, redirects, badResponse, certInfo, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
 	}
 }
 func GET_AutodiscoverConfig(origin_domain string, uri string, email_add string) ([]map[string]interface{}, string, *models.CertInfo, error) { //使用先get后post方法
-	client := &http.Client{
-		Transport: &http.Transport{
-			TLSClientConfig: &tls.Config{
-				InsecureSkipVerify: true,
-				MinVersion:         tls.VersionTLS10,
-			},
-		},
-		CheckRedirect: func(req *http.Request, via []*http.Request) error {
-			return http.ErrUseLastResponse // 禁止重定向
-		},
-		Timeout: 15 * time.Second,
-	}
+	client := newHTTPClient(false)
 	resp, err := client.Get(uri)
 	if err != nil {
 		return []map[string]interface{}{}, "", nil, fmt.Errorf("failed to send request: %v", err)
@@ -318,39 +255,29 @@ func GET_AutodiscoverConfig(origin_domain string, uri string, email_add string)
 	defer resp.Body.Close()
 
 	redirects := utils.GetRedirects(resp) // 获取当前重定向链
+	certInfo := utils.BuildCertInfo(resp.TLS, resp.Request.URL.Hostname())
 
 	if resp.StatusCode == http.StatusFound || resp.StatusCode == http.StatusMovedPermanently { //仅通过get请求获取重定向地址
 		location := resp.Header.Get("Location")
 		fmt.Printf("Redirect to: %s\n", location)
 		if location == "" {
-			return nil, "", nil, fmt.Errorf("missing Location header in redirect")
+			return redirects, "", certInfo, fmt.Errorf("missing Location header in redirect")
 		}
 		newURI, err := url.Parse(location)
 		if err != nil {
-			return nil, "", nil, fmt.Errorf("failed to parse redirect URL: %s", location)
+			return redirects, "", certInfo, fmt.Errorf("failed to parse redirect URL: %s", location)
 		}
 
 		// 递归调用并合并重定向链
 		_, _, _, nextRedirects, result, certinfo, err := getAutodiscoverConfig(origin_domain, newURI.String(), email_add, "get_post", 0, 0, 0, 0)
 		return append(redirects, nextRedirects...), result, certinfo, err
 	} else {
-		return nil, "", nil, fmt.Errorf("not find Redirect Statuscode")
+		return redirects, "", certInfo, fmt.Errorf("not find Redirect Statuscode")
 	}
 }
 
 func direct_GET_AutodiscoverConfig(origin_domain string, uri string, email_add string, method string, index int, flag1 int, flag2 int, flag3 int) (int, int, int, []map[string]interface{}, string, *models.CertInfo, error) { //一路get请求
-	client := &http.Client{
-		Transport: &http.Transport{
-			TLSClientConfig: &tls.Config{
-				InsecureSkipVerify: true,
-				MinVersion:         tls.VersionTLS10,
-			},
-		},
-		CheckRedirect: func(req *http.Request, via []*http.Request) error {
-			return http.ErrUseLastResponse // 禁止重定向
-		},
-		Timeout: 15 * time.Second, // 设置请求超时时间
-	}
+	client := newHTTPClient(false)
 	resp, err := client.Get(uri)
 	if err != nil {
 		return flag1, flag2, flag3, []map[string]interface{}{}, "", nil, fmt.Errorf("failed to send request: %v", err)
@@ -358,21 +285,23 @@ func direct_GET_AutodiscoverConfig(origin_domain string, uri string, email_add s
 
 	redirects := utils.GetRedirects(resp)
 	defer resp.Body.Close() //
+	// 无论响应内容如何都记录本跳的证书信息
+	certInfo := utils.BuildCertInfo(resp.TLS, resp.Request.URL.Hostname())
 
 	if resp.StatusCode == http.StatusFound || resp.StatusCode == http.StatusMovedPermanently {
 		flag1 = flag1 + 1
 		location := resp.Header.Get("Location")
 		fmt.Printf("Redirect to: %s\n", location)
 		if location == "" {
-			return flag1, flag2, flag3, redirects, "", nil, fmt.Errorf("missing Location header in redirect")
+			return flag1, flag2, flag3, redirects, "", certInfo, fmt.Errorf("missing Location header in redirect")
 		} else if flag1 > 10 {
 			//saveXMLToFile_autodiscover("./location2.xml", origin_domain, email_add)
-			return flag1, flag2, flag3, redirects, "", nil, fmt.Errorf("too many redirect times")
+			return flag1, flag2, flag3, redirects, "", certInfo, fmt.Errorf("too many redirect times")
 		}
 
 		newURI, err := url.Parse(location)
 		if err != nil {
-			return flag1, flag2, flag3, redirects, "", nil, fmt.Errorf("failed to parse redirect URL: %s", location)
+			return flag1, flag2, flag3, redirects, "", certInfo, fmt.Errorf("failed to parse redirect URL: %s", location)
 		}
 
 		// 递归调用并合并重定向链
@@ -381,7 +310,7 @@ func direct_GET_AutodiscoverConfig(origin_domain string, uri string, email_add s
 	} else if resp.StatusCode >= 200 && resp.StatusCode < 300 {
 		body, err := io.ReadAll(resp.Body)
 		if err != nil {
-			return flag1, flag2, flag3, redirects, "", nil, fmt.Errorf("failed to read response body: %v", err)
+			return flag1, flag2, flag3, redirects, "", certInfo, fmt.Errorf("failed to read response body: %v", err)
 		}
 		var autodiscoverResp models.AutodiscoverResponse
 		err = xml.Unmarshal(body, &autodiscoverResp)
@@ -390,7 +319,7 @@ func direct_GET_AutodiscoverConfig(origin_domain string, uri string, email_add s
 			// 	//if !strings.Contains(strings.TrimSpace(string(body)), `<html`) && !strings.Contains(strings.TrimSpace(string(body)), `<item`) && !strings.Contains(strings.TrimSpace(string(body)), `lastmod`) && !strings.Contains(strings.TrimSpace(string(body)), `lt`) {
 			// 	saveno_XMLToFile("no_autodiscover_config_directget.xml", string(body), email_add)
 			// } //记录错误格式的xml
-			return flag1, flag2, flag3, redirects, "", nil, fmt.Errorf("failed to unmarshal XML: %v", err)
+			return flag1, flag2, flag3, redirects, "", certInfo, fmt.Errorf("failed to unmarshal XML: %v", err)
 		}
 		if autodiscoverResp.Response.Account.Action == "redirectAddr" {
 			flag2 = flag2 + 1
@@ -398,9 +327,9 @@ func direct_GET_AutodiscoverConfig(origin_domain string, uri string, email_add s
 			//outputfile := fmt.Sprintf("./autodiscover/autodiscover_%s_%d_redirectAddr_config.xml", method, index)
 			//saveXMLToFile_autodiscover(outputfile, string(body), email_add)
 			if newEmail != "" {
-				return flag1, flag2, flag3, redirects, string(body), nil, nil //TODO, 这里直接返回带redirect_email了
+				return flag1, flag2, flag3, redirects, string(body), certInfo, nil //TODO, 这里直接返回带redirect_email了
 			} else {
-				return flag1, flag2, flag3, redirects, "", nil, fmt.Errorf("nil ReAddr")
+				return flag1, flag2, flag3, redirects, "", certInfo, fmt.Errorf("nil ReAddr")
 			}
 		} else if autodiscoverResp.Response.Account.Action == "redirectUrl" {
 			flag3 = flag3 + 1
@@ -412,73 +341,33 @@ func direct_GET_AutodiscoverConfig(origin_domain string, uri string, email_add s
 				return newflag1, newflag2, newflag3, append(redirects, nextRedirects...), result, certinfo, err
 			} else if newUri != "" {
 				//saveXMLToFile_autodiscover("./flag32.xml", origin_domain, email_add)
-				return flag1, flag2, flag3, redirects, "", nil, fmt.Errorf("too many RedirectUrl")
+				return flag1, flag2, flag3, redirects, "", certInfo, fmt.Errorf("too many RedirectUrl")
 			} else {
-				return flag1, flag2, flag3, redirects, "", nil, fmt.Errorf("nil Reurl")
+				return flag1, flag2, flag3, redirects, "", certInfo, fmt.Errorf("nil Reurl")
 			}
 		} else if autodiscoverResp.Response.Account.Action == "settings" {
 			//outputfile := fmt.Sprintf("./autodiscover/autodiscover_%s_%d_config.xml", method, index)
 			//saveXMLToFile_autodiscover(outputfile, string(body), email_add)
-			//只在可以直接返回xml配置的时候记录证书信息
-			var certInfo models.CertInfo
-			// 提取证书信息
-			if resp.TLS != nil {
-				//var encodedData []byte
-				var encodedCerts []string
-				goChain := resp.TLS.PeerCertificates
-				endCert := goChain[0]
-
-				// 证书验证
-				dnsName := resp.Request.URL.Hostname()
-
-				var VerifyError error
-				certInfo.IsTrusted, VerifyError = utils.VerifyCertificate(goChain, dnsName)
-				if VerifyError != nil {
-					certInfo.VerifyError = VerifyError.Error()
-				} else {
-					certInfo.VerifyError = ""
-				}
-				certInfo.IsExpired = endCert.NotAfter.Before(time.Now())
-				certInfo.IsHostnameMatch = utils.VerifyHostname(endCert, dnsName)
-				certInfo.IsSelfSigned = utils.IsSelfSigned(endCert)
-				certInfo.IsInOrder = utils.IsChainInOrder(goChain)
-				certInfo.TLSVersion = resp.TLS.Version
-
-				// 提取证书的其他信息
-				certInfo.Subject = endCert.Subject.CommonName
-				certInfo.Issuer = endCert.Issuer.String()
-				certInfo.SignatureAlg = endCert.SignatureAlgorithm.String()
-				certInfo.AlgWarning = utils.AlgWarnings(endCert)
-
-				// 将证书编码为 base64 格式
-				for _, cert := range goChain {
-					encoded := base64.StdEncoding.EncodeToString(cert.Raw)
-					//encodedData = append(encodedData, []byte(encoded)...)
-					encodedCerts = append(encodedCerts, encoded)
-				}
-				//certInfo.RawCert = encodedData
-				certInfo.RawCerts = encodedCerts
-			}
-			return flag1, flag2, flag3, redirects, string(body), &certInfo, nil
+			return flag1, flag2, flag3, redirects, string(body), certInfo, nil
 		} else if autodiscoverResp.Response.Error != nil {
 			//fmt.Printf("Error: %s\n", string(body))
 			// 处理错误响应
 			errorConfig := fmt.Sprintf("Errorcode:%d-%s\n", autodiscoverResp.Response.Error.ErrorCode, autodiscoverResp.Response.Error.Message)
 			//outputfile := fmt.Sprintf("./autodiscover/autodiscover_%s_%d_Errorconfig.txt", method, index)
 			//saveXMLToFile_autodiscover(outputfile, errorConfig, email_add)
-			return flag1, flag2, flag3, redirects, errorConfig, nil, nil
+			return flag1, flag2, flag3, redirects, errorConfig, certInfo, nil
 		} else {
 			//fmt.Printf("Response element not valid:%s\n", string(body))
 			//处理Response可能本身就不正确的响应,同时也会存储不合规的xml(unmarshal的时候合规但Response不合规)
 			alsoErrorConfig := fmt.Sprintf("Non-valid Response element for %s\n:", email_add)
 			//outputfile := fmt.Sprintf("./autodiscover/autodiscover_%s_%d_AlsoErrorConfig.xml", method, index)
 			//saveXMLToFile_autodiscover(outputfile, string(body), email_add)
-			return flag1, flag2, flag3, redirects, alsoErrorConfig, nil, nil
+			return flag1, flag2, flag3, redirects, alsoErrorConfig, certInfo, nil
 		}
 	} else {
 		//outputfile := fmt.Sprintf("./autodiscover/autodiscover_%s_%d_badresponse.txt", method, index)
 		bad_response := fmt.Sprintf("Bad response for %s:%d\n", email_add, resp.StatusCode)
 		//saveXMLToFile_autodiscover(outputfile, bad_response, email_add)
-		return flag1, flag2, flag3, redirects, bad_response, nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode) //同时也想记录请求发送失败时的状态码
+		return flag1, flag2, flag3, redirects, bad_response, certInfo, fmt.Errorf("unexpected status code: %d", resp.StatusCode) //同时也想记录请求发送失败时的状态码
 	}
 }
@@ -0,0 +1,44 @@
+package discover
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"scan-website/config"
+)
+
+// 所有配置获取请求共用的拨号函数，honeypot 回放时会替换成本地监听地址；为空时按配置的超时直接拨号
+var DialContext func(ctx context.Context, network, addr string) (net.Conn, error)
+
+// 构造配置获取用的 http.Client，followRedirect 为 false 时禁止自动重定向
+func newHTTPClient(followRedirect bool) *http.Client {
+	cfg := config.Get()
+	dial := DialContext
+	if dial == nil {
+		dial = (&net.Dialer{Timeout: cfg.Timeouts.Dial.Std()}).DialContext
+	}
+	// 强制单一地址族时把 tcp 换成 tcp4/tcp6
+	if network := cfg.Network(); network != "tcp" {
+		inner := dial
+		dial = func(ctx context.Context, _, addr string) (net.Conn, error) {
+			return inner(ctx, network, addr)
+		}
+	}
+	client := &http.Client{
+		Transport: &http.Transport{
+			DialContext: dial,
+			TLSClientConfig: &tls.Config{
+				InsecureSkipVerify: true,
+				MinVersion:         tls.VersionTLS10,
+			},
+		},
+		Timeout: cfg.Timeouts.HTTP.Std(),
+	}
+	if !followRedirect {
+		client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse // 禁止重定向
+		}
+	}
+	return client
+}
@@ -0,0 +1,143 @@
+package discover
+
+import (
+	"net"
+	"scan-website/models"
+	"scan-website/utils"
+	"strconv"
+	"time"
+)
+
+// 模拟邮件客户端在没有 Autoconfig/Autodiscover/SRV 时的猜测过程：按客户端固定的
+// 主机名前缀 × 端口/socketType 顺序逐个尝试，第一个能完成协议协商的候选即为用户最终得到的配置
+
+type guessCandidate struct {
+	protocol   string // imap / pop3 / smtp
+	port       int
+	socketType string // SSL / STARTTLS
+}
+
+type guessOrder struct {
+	hostPrefixes map[string][]string // 协议 -> 依次尝试的前缀，空串表示域名本身
+	incoming     []guessCandidate    // 收件协议/端口顺序
+	outgoing     []guessCandidate
+}
+
+// Thunderbird guessConfig.js：收件先 IMAP 后 POP3，每种协议先 SSL 后 STARTTLS；
+// 发件依次 465/SSL、587/STARTTLS、25/STARTTLS；主机名外层循环，端口内层循环
+var thunderbirdOrder = guessOrder{
+	hostPrefixes: map[string][]string{
+		"imap": {"imap.", "mail.", ""},
+		"pop3": {"pop3.", "pop.", "mail.", ""},
+		"smtp": {"smtp.", "mail.", ""},
+	},
+	incoming: []guessCandidate{
+		{protocol: "imap", port: 993, socketType: "SSL"},
+		{protocol: "imap", port: 143, socketType: "STARTTLS"},
+		{protocol: "pop3", port: 995, socketType: "SSL"},
+		{protocol: "pop3", port: 110, socketType: "STARTTLS"},
+	},
+	outgoing: []guessCandidate{
+		{protocol: "smtp", port: 465, socketType: "SSL"},
+		{protocol: "smtp", port: 587, socketType: "STARTTLS"},
+		{protocol: "smtp", port: 25, socketType: "STARTTLS"},
+	},
+}
+
+// Outlook 手动/猜测配置的回退顺序：只尝试 imap./mail./smtp. 这类常见前缀，不试域名本身；
+// 发件优先 587/STARTTLS
+var outlookOrder = guessOrder{
+	hostPrefixes: map[string][]string{
+		"imap": {"imap.", "mail."},
+		"pop3": {"pop.", "pop3.", "mail."},
+		"smtp": {"smtp.", "mail."},
+	},
+	incoming: []guessCandidate{
+		{protocol: "imap", port: 993, socketType: "SSL"},
+		{protocol: "imap", port: 143, socketType: "STARTTLS"},
+		{protocol: "pop3", port: 995, socketType: "SSL"},
+		{protocol: "pop3", port: 110, socketType: "STARTTLS"},
+	},
+	outgoing: []guessCandidate{
+		{protocol: "smtp", port: 587, socketType: "STARTTLS"},
+		{protocol: "smtp", port: 465, socketType: "SSL"},
+		{protocol: "smtp", port: 25, socketType: "STARTTLS"},
+	},
+}
+
+var clientGuessOrders = []struct {
+	client string
+	order  *guessOrder
+}{
+	{"thunderbird", &thunderbirdOrder},
+	{"outlook", &outlookOrder},
+}
+
+// EmulateClientGuess 在 GuessMailServer 的结果之上按各客户端的顺序选出最终配置。
+// GuessMailServer 试过却未确认的主机端口直接跳过，其余候选用完整的协议协商（含 STARTTLS）验证，
+// 同一主机端口的验证结果在各客户端之间共用
+func EmulateClientGuess(domain string, guesses []models.GuessResult, timeout time.Duration) []models.ClientGuessResult {
+	// GuessMailServer 试过但没有确认协议的候选直接视为失败
+	unusable := guessProbeSpace(domain)
+	for _, g := range guesses {
+		if g.Confirmed {
+			delete(unusable, net.JoinHostPort(g.Host, strconv.Itoa(g.Port)))
+		}
+	}
+	probed := make(map[string]bool)
+
+	var results []models.ClientGuessResult
+	for _, c := range clientGuessOrders {
+		result := models.ClientGuessResult{Client: c.client}
+		try := func(candidates []guessCandidate) *models.GuessedServer {
+			// 主机名外层、端口内层，与 Thunderbird 的优先级一致
+			for _, protocolOrder := range protocolsOf(candidates) {
+				for _, prefix := range c.order.hostPrefixes[protocolOrder] {
+					host := prefix + domain
+					for _, cand := range candidates {
+						if cand.protocol != protocolOrder {
+							continue
+						}
+						// 客户端走到的候选都计入 Tried，缓存只用于避免重复连接
+						result.Tried++
+						key := net.JoinHostPort(host, strconv.Itoa(cand.port))
+						if unusable[key] {
+							continue
+						}
+						ok, seen := probed[key]
+						if !seen {
+							mode := "tls"
+							if cand.socketType == "STARTTLS" {
+								mode = "starttls"
+							}
+							_, err := utils.MailTLSHandshake(host, cand.port, cand.protocol, mode, timeout)
+							ok = err == nil
+							probed[key] = ok
+						}
+						if ok {
+							return &models.GuessedServer{Protocol: cand.protocol, Host: host, Port: cand.port, SocketType: cand.socketType}
+						}
+					}
+				}
+			}
+			return nil
+		}
+		result.Incoming = try(c.order.incoming)
+		result.Outgoing = try(c.order.outgoing)
+		results = append(results, result)
+	}
+	return results
+}
+
+// 按候选列表中首次出现的顺序返回协议
+func protocolsOf(candidates []guessCandidate) []string {
+	var protocols []string
+	seen := make(map[string]bool)
+	for _, c := range candidates {
+		if !seen[c.protocol] {
+			seen[c.protocol] = true
+			protocols = append(protocols, c.protocol)
+		}
+	}
+	return protocols
+}
@@ -0,0 +1,44 @@
+package discover
+
+import (
+	"fmt"
+	"scan-website/models"
+	"scan-website/utils"
+
+	"github.com/miekg/dns"
+)
+
+// ValidateDNSSEC 对域名的 MX 以及查到的各 SRV 服务做本地 DNSSEC 验证，结果写入 result.DNSSEC
+func ValidateDNSSEC(result *models.DomainResult) {
+	validator, err := utils.DefaultDNSSECValidator()
+	if err != nil {
+		result.ErrorMessages = append(result.ErrorMessages, fmt.Sprintf("DNSSEC validator error: %v", err))
+		return
+	}
+	result.DNSSEC = append(result.DNSSEC, validator.Validate(result.Domain, dns.TypeMX))
+	for _, service := range srvServices(&result.SRV) {
+		result.DNSSEC = append(result.DNSSEC, validator.Validate(service, dns.TypeSRV))
+	}
+}
+
+// 有记录或明确声明不提供的 SRV 服务名（去重）
+func srvServices(srv *models.SRVResult) []string {
+	seen := make(map[string]struct{})
+	var services []string
+	add := func(service string) {
+		if _, ok := seen[service]; ok {
+			return
+		}
+		seen[service] = struct{}{}
+		services = append(services, service)
+	}
+	for _, records := range [][]models.SRVRecord{srv.RecvRecords, srv.SendRecords, srv.OtherRecords} {
+		for _, r := range records {
+			add(r.Service)
+		}
+	}
+	for _, service := range srv.NotOffered {
+		add(service)
+	}
+	return services
+}
@@ -0,0 +1,126 @@
+package discover
+
+import (
+	"net"
+	"net/url"
+	"scan-website/config"
+	"scan-website/models"
+	"scan-website/utils"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// ISPDB 属于 Thunderbird，不是被测域名的目标
+const ispdbHost = "autoconfig.thunderbird.net"
+
+// ResolveTargets 收集 Autodiscover/Autoconfig 请求（含重定向）、SRV 目标和确认过的猜测结果，
+// 分别查询 A/AAAA；开启 ProbeIPFamilies 时再分别用 IPv4、IPv6 地址测试 TCP 连通性。
+// 解析不到任何地址的目标不记录
+func ResolveTargets(result *models.DomainResult) {
+	index := make(map[string]*models.TargetFamilies)
+	var targets []*models.TargetFamilies
+	add := func(source, host string, port int) {
+		host = strings.ToLower(strings.TrimSuffix(host, "."))
+		if host == "" || host == ispdbHost || port <= 0 {
+			return
+		}
+		key := net.JoinHostPort(host, strconv.Itoa(port))
+		if t, ok := index[key]; ok {
+			for _, s := range t.Sources {
+				if s == source {
+					return
+				}
+			}
+			t.Sources = append(t.Sources, source)
+			return
+		}
+		t := &models.TargetFamilies{Host: host, Port: port, Sources: []string{source}}
+		index[key] = t
+		targets = append(targets, t)
+	}
+	addURL := func(source, raw string) {
+		u, err := url.Parse(raw)
+		if err != nil {
+			return
+		}
+		port, _ := strconv.Atoi(u.Port())
+		if port == 0 {
+			switch u.Scheme {
+			case "https":
+				port = 443
+			case "http":
+				port = 80
+			}
+		}
+		add(source, u.Hostname(), port)
+	}
+
+	for _, r := range result.Autodiscover {
+		addURL("autodiscover", r.URI)
+		for _, hop := range r.Redirects {
+			if s, ok := hop["URL"].(string); ok {
+				addURL("autodiscover", s)
+			}
+		}
+	}
+	for _, r := range result.Autoconfig {
+		addURL("autoconfig", r.URI)
+		for _, hop := range r.Redirects {
+			if s, ok := hop["URL"].(string); ok {
+				addURL("autoconfig", s)
+			}
+		}
+	}
+	for _, records := range [][]models.SRVRecord{result.SRV.RecvRecords, result.SRV.SendRecords, result.SRV.OtherRecords} {
+		for _, r := range records {
+			add("srv", r.Target, int(r.Port))
+		}
+	}
+	for _, g := range result.GUESS {
+		if g.Confirmed && !g.ViaWildcard {
+			add("guess", g.Host, g.Port)
+		}
+	}
+
+	// 同一主机只解析一次
+	type addrs struct{ a, aaaa []string }
+	resolved := make(map[string]addrs)
+	probe := config.Get().ProbeIPFamilies
+	timeout := config.Get().Timeouts.Dial.Std()
+	for _, t := range targets {
+		r, ok := resolved[t.Host]
+		if !ok {
+			r.a, _ = utils.LookupAddrs(t.Host, dns.TypeA)
+			r.aaaa, _ = utils.LookupAddrs(t.Host, dns.TypeAAAA)
+			resolved[t.Host] = r
+		}
+		if len(r.a) == 0 && len(r.aaaa) == 0 {
+			continue
+		}
+		t.A, t.AAAA = r.a, r.aaaa
+		if probe {
+			t.IPv4 = probeFamily(r.a, t.Port, timeout)
+			t.IPv6 = probeFamily(r.aaaa, t.Port, timeout)
+		}
+		result.Targets = append(result.Targets, t)
+	}
+}
+
+// 连接该地址族的第一个地址，没有地址时返回 nil
+func probeFamily(addrs []string, port int, timeout time.Duration) *models.FamilyProbe {
+	if len(addrs) == 0 {
+		return nil
+	}
+	p := &models.FamilyProbe{Addr: addrs[0]}
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort(addrs[0], strconv.Itoa(port)), timeout)
+	if err != nil {
+		p.Error = err.Error()
+		return p
+	}
+	conn.Close()
+	p.Reachable = true
+	return p
+}
@@ -1,47 +1,93 @@
 package discover
 
 import (
-	"fmt"
+	"bufio"
+	"crypto/tls"
 	"net"
+	"scan-website/config"
+	"scan-website/models"
+	"scan-website/utils"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 )
 
-func GuessMailServer(domain string, timeout time.Duration, maxConcurrency int) []string {
-	prefixMap := map[string][]string{
-		"SMTP": {"smtp.", "smtps.", "mail.", "submission.", "mx."},
-		"IMAP": {"imap.", "imap4.", "imaps.", "mail.", "mx."},
-		"POP":  {"pop.", "pop3.", "pop3s.", "mail.", "mx."},
-	}
+// 各协议期望的问候语前缀
+var guessGreetings = map[string]string{
+	"SMTP": "220",
+	"IMAP": "* OK",
+	"POP":  "+OK",
+}
 
-	portMap := map[string][]int{
-		"SMTP": {465, 587},
-		"IMAP": {143, 993},
-		"POP":  {110, 995},
-	}
+// 各协议尝试的主机名前缀和端口
+var guessPrefixes = map[string][]string{
+	"SMTP": {"smtp.", "smtps.", "mail.", "submission.", "mx."},
+	"IMAP": {"imap.", "imap4.", "imaps.", "mail.", "mx."},
+	"POP":  {"pop.", "pop3.", "pop3s.", "mail.", "mx."},
+}
 
-	var results []string
-	var wg sync.WaitGroup
+var guessPorts = map[string][]int{
+	"SMTP": {465, 587},
+	"IMAP": {143, 993},
+	"POP":  {110, 995},
+}
+
+// 隐式 TLS 端口，握手成功即视为确认
+var guessTLSPorts = map[int]bool{465: true, 993: true, 995: true}
+
+// GuessMailServer 按常见前缀和端口猜测邮件服务器。只返回能连上的主机端口，
+// Confirmed 表示读到了对应协议的问候语（明文端口）或完成了 TLS 握手（隐式 TLS 端口）；
+// wildcard 不为空时，地址与通配符相同的结果标记 ViaWildcard
+func GuessMailServer(domain string, timeout time.Duration, maxConcurrency int, wildcard *models.WildcardInfo) []models.GuessResult {
+	// 先解析每个主机名，解析不到的不再连接
+	ips := make(map[string][]string)
+	for _, prefixes := range guessPrefixes {
+		for _, prefix := range prefixes {
+			ips[prefix+domain] = nil
+		}
+	}
+	var resolveWG sync.WaitGroup
 	var mu sync.Mutex
 	semaphore := make(chan struct{}, maxConcurrency) // 控制最大并发数
+	for host := range ips {
+		resolveWG.Add(1)
+		go func(host string) {
+			defer resolveWG.Done()
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+			addrs, _ := utils.LookupHostAddrs(host)
+			mu.Lock()
+			ips[host] = addrs
+			mu.Unlock()
+		}(host)
+	}
+	resolveWG.Wait()
 
-	for proto, prefixes := range prefixMap {
-		for _, port := range portMap[proto] {
+	var results []models.GuessResult
+	var wg sync.WaitGroup
+
+	for proto, prefixes := range guessPrefixes {
+		for _, port := range guessPorts[proto] {
 			for _, prefix := range prefixes {
+				host := prefix + domain
+				if len(utils.FilterFamily(ips[host], config.Get().Network())) == 0 {
+					continue
+				}
 				wg.Add(1)
-				go func(host string, port int) {
+				go func(proto, host string, port int, addrs []string) {
 					defer wg.Done()
 					semaphore <- struct{}{}        // 获取令牌
 					defer func() { <-semaphore }() // 释放令牌
 
-					conn, err := net.DialTimeout("tcp", fmt.Sprintf("%s:%d", host, port), timeout)
-					if err == nil {
-						conn.Close()
+					result := probeGuess(proto, host, port, addrs, timeout)
+					result.ViaWildcard = utils.MatchesWildcard(wildcard, addrs)
+					if result.Reach {
 						mu.Lock()
-						results = append(results, fmt.Sprintf("%s:%d", host, port))
+						results = append(results, result)
 						mu.Unlock()
 					}
-				}(prefix+domain, port)
+				}(proto, host, port, ips[host])
 			}
 		}
 	}
@@ -49,3 +95,62 @@ func GuessMailServer(domain string, timeout time.Duration, maxConcurrency int) [
 	wg.Wait()
 	return results
 }
+
+// 返回 GuessMailServer 会尝试的所有 host:port
+func guessProbeSpace(domain string) map[string]bool {
+	space := make(map[string]bool)
+	for proto, prefixes := range guessPrefixes {
+		for _, port := range guessPorts[proto] {
+			for _, prefix := range prefixes {
+				space[net.JoinHostPort(prefix+domain, strconv.Itoa(port))] = true
+			}
+		}
+	}
+	return space
+}
+
+// 连接第一个解析到的（符合强制地址族的）地址并确认协议
+func probeGuess(proto, host string, port int, addrs []string, timeout time.Duration) models.GuessResult {
+	result := models.GuessResult{
+		Host:     host,
+		Port:     port,
+		Protocol: proto,
+		IPs:      addrs,
+	}
+	dialAddr := utils.FilterFamily(addrs, config.Get().Network())[0]
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort(dialAddr, strconv.Itoa(port)), timeout)
+	if err != nil {
+		return result
+	}
+	defer conn.Close()
+	result.Reach = true
+	conn.SetDeadline(time.Now().Add(timeout))
+
+	if guessTLSPorts[port] {
+		tlsConn := tls.Client(conn, &tls.Config{
+			ServerName:         host,
+			InsecureSkipVerify: true,
+			MinVersion:         tls.VersionTLS10,
+		})
+		if err := tlsConn.Handshake(); err != nil {
+			result.Error = err.Error()
+			return result
+		}
+		result.Confirmed = true
+		result.TLS = true
+		// 握手后顺便读取问候语，读不到不影响确认
+		if line, err := bufio.NewReader(tlsConn).ReadString('\n'); err == nil {
+			result.Banner = strings.TrimSpace(line)
+		}
+		return result
+	}
+
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	result.Banner = strings.TrimSpace(line)
+	result.Confirmed = strings.HasPrefix(result.Banner, guessGreetings[proto])
+	return result
+}
@@ -0,0 +1,198 @@
+package discover
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"scan-website/models"
+	"scan-website/utils"
+	"strconv"
+	"strings"
+)
+
+// 策略文件的上限，RFC 8461 3.3 建议不超过 64KB
+const mtaSTSPolicyLimit = 64 * 1024
+
+// QueryMTASTS 查询 _mta-sts TXT 记录、获取并解析策略文件、查询 _smtp._tls TLS-RPT 记录，
+// 最后用策略的 mx 模式逐个检查 MX。TXT 缺失时仍尝试获取策略，以便发现只部署了一半的情况
+func QueryMTASTS(domain string, mx *models.MXResult) *models.MTASTSResult {
+	result := &models.MTASTSResult{}
+	addErr := func(format string, args ...interface{}) {
+		result.Errors = append(result.Errors, fmt.Sprintf(format, args...))
+	}
+
+	records, err := utils.LookupTXT("_mta-sts." + domain)
+	if err != nil {
+		addErr("MTA-STS TXT lookup error: %v", err)
+	}
+	var stsRecords []string
+	for _, r := range records {
+		if strings.HasPrefix(r, "v=STSv1") {
+			stsRecords = append(stsRecords, r)
+		}
+	}
+	switch {
+	case len(stsRecords) > 1: // RFC 8461 3.1：多条记录时视为没有部署
+		addErr("multiple STSv1 TXT records")
+	case len(stsRecords) == 1:
+		result.TXTRecord = stsRecords[0]
+		fields := parseTagList(stsRecords[0])
+		result.ID = fields["id"]
+		if result.ID == "" {
+			addErr("STSv1 TXT record without id")
+		}
+	}
+
+	result.PolicyURL = "https://mta-sts." + domain + "/.well-known/mta-sts.txt"
+	policy, certInfo, err := fetchMTASTSPolicy(result.PolicyURL, "mta-sts."+domain)
+	result.CertInfo = certInfo
+	if err != nil {
+		if result.TXTRecord != "" {
+			addErr("MTA-STS policy error: %v", err)
+		}
+	} else {
+		result.Policy = policy
+		if result.TXTRecord == "" {
+			addErr("MTA-STS policy published without TXT record")
+		}
+		if certInfo == nil || !certInfo.IsTrusted || !certInfo.IsHostnameMatch {
+			result.PolicyIgnored = true
+			addErr("MTA-STS policy served with an untrusted or mismatched certificate")
+		}
+	}
+
+	rpt, err := utils.LookupTXT("_smtp._tls." + domain)
+	if err != nil {
+		addErr("TLS-RPT TXT lookup error: %v", err)
+	}
+	for _, r := range rpt {
+		if !strings.HasPrefix(r, "v=TLSRPTv1") {
+			continue
+		}
+		if result.TLSRPT != nil { // RFC 8460 3：多条记录时不发送报告
+			addErr("multiple TLSRPTv1 TXT records")
+			break
+		}
+		result.TLSRPT = &models.TLSRPTRecord{Record: r}
+		for _, uri := range strings.Split(parseTagList(r)["rua"], ",") {
+			if uri = strings.TrimSpace(uri); uri != "" {
+				result.TLSRPT.RUA = append(result.TLSRPT.RUA, uri)
+			}
+		}
+	}
+
+	if result.Policy != nil && result.Policy.Mode != "none" && mx != nil && !mx.NullMX {
+		for _, r := range mx.Records {
+			if r.Host == "" {
+				continue
+			}
+			host := models.MTASTSMXHost{Host: r.Host, Match: MatchMTASTSPolicy(result.Policy, r.Host)}
+			result.MXHosts = append(result.MXHosts, host)
+			if !host.Match {
+				result.MXMatch = "mismatch"
+			} else if result.MXMatch == "" {
+				result.MXMatch = "match"
+			}
+		}
+	}
+	return result
+}
+
+func fetchMTASTSPolicy(url, host string) (*models.MTASTSPolicy, *models.CertInfo, error) {
+	// RFC 8461 3.3：不跟随重定向
+	client := newHTTPClient(false)
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	certInfo := utils.BuildCertInfo(resp.TLS, host)
+	if resp.StatusCode != http.StatusOK {
+		return nil, certInfo, fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+	if mediaType, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type")); err != nil || mediaType != "text/plain" {
+		return nil, certInfo, fmt.Errorf("unexpected content type %q", resp.Header.Get("Content-Type"))
+	}
+	policy, err := ParseMTASTSPolicy(io.LimitReader(resp.Body, mtaSTSPolicyLimit))
+	return policy, certInfo, err
+}
+
+// ParseMTASTSPolicy 解析 "key: value" 形式的策略文件（RFC 8461 3.2），行尾可以是 CRLF 或 LF
+func ParseMTASTSPolicy(r io.Reader) (*models.MTASTSPolicy, error) {
+	policy := &models.MTASTSPolicy{MaxAge: -1}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		key, value, found := strings.Cut(line, ":")
+		if !found {
+			return nil, fmt.Errorf("malformed policy line %q", line)
+		}
+		value = strings.TrimSpace(value)
+		switch strings.TrimSpace(key) {
+		case "version":
+			policy.Version = value
+		case "mode":
+			policy.Mode = value
+		case "mx":
+			policy.MX = append(policy.MX, strings.ToLower(value))
+		case "max_age":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 0 || n > 31557600 {
+				return nil, fmt.Errorf("invalid max_age %q", value)
+			}
+			policy.MaxAge = n
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if policy.Version != "STSv1" {
+		return nil, fmt.Errorf("invalid version %q", policy.Version)
+	}
+	switch policy.Mode {
+	case "enforce", "testing":
+		if len(policy.MX) == 0 {
+			return nil, fmt.Errorf("mode %s without mx", policy.Mode)
+		}
+	case "none":
+	default:
+		return nil, fmt.Errorf("invalid mode %q", policy.Mode)
+	}
+	if policy.MaxAge < 0 {
+		return nil, fmt.Errorf("missing max_age")
+	}
+	return policy, nil
+}
+
+// MatchMTASTSPolicy 判断 MX 主机是否符合策略中的某个 mx 模式，"*." 只匹配最左边一个标签
+func MatchMTASTSPolicy(policy *models.MTASTSPolicy, mx string) bool {
+	mx = strings.ToLower(strings.TrimSuffix(mx, "."))
+	for _, pattern := range policy.MX {
+		if suffix, ok := strings.CutPrefix(pattern, "*."); ok {
+			label, rest, found := strings.Cut(mx, ".")
+			if found && label != "" && rest == suffix {
+				return true
+			}
+		} else if mx == pattern {
+			return true
+		}
+	}
+	return false
+}
+
+// 解析 "v=STSv1; id=20160831085700Z;" 形式的标签列表
+func parseTagList(record string) map[string]string {
+	fields := make(map[string]string)
+	for _, part := range strings.Split(record, ";") {
+		key, value, found := strings.Cut(strings.TrimSpace(part), "=")
+		if found {
+			fields[strings.TrimSpace(key)] = strings.TrimSpace(value)
+		}
+	}
+	return fields
+}
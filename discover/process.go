@@ -1,14 +1,16 @@
 package discover
 
 import (
-	"bufio"
 	"encoding/csv"
-	"encoding/json"
 	"fmt"
 	"os"
 	"runtime"
 	"runtime/debug"
+	"scan-website/config"
 	"scan-website/models"
+	"scan-website/pipeline"
+	"scan-website/store"
+	"scan-website/utils"
 	"strings"
 	"sync"
 )
@@ -19,28 +21,27 @@ func freeMem() {
 	debug.FreeOSMemory()
 }
 
-// JSONL 文件写入，使用缓冲减少 I/O 竞争
-func writeResultToJSONLFile(fileName string, results []models.DomainResult, fileLock *sync.Mutex) error {
-	fileLock.Lock()
-	defer fileLock.Unlock()
-
-	// 使用 bufio 缓冲写入
-	file, err := os.OpenFile(fileName, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
-	if err != nil {
-		return fmt.Errorf("error opening file: %v", err)
+// 一批结果写入 JSONL，批次之间刷新缓冲，中途退出时已完成的批次都已落盘
+func writeResultToJSONLFile(writer *pipeline.Writer, results []models.DomainResult) error {
+	for _, result := range results {
+		if err := writer.Write(result); err != nil {
+			return err
+		}
 	}
-	defer file.Close()
+	return writer.Flush()
+}
 
-	writer := bufio.NewWriterSize(file, 64*1024)
-	for _, result := range results {
-		jsonBytes, err := json.Marshal(result)
-		if err != nil {
-			return fmt.Errorf("error marshaling JSON: %v", err)
+// 证书库中新出现的证书追加写入 certs.jsonl
+func writeCertsToJSONLFile(writer *pipeline.Writer, records []models.CertRecord) error {
+	if len(records) == 0 {
+		return nil
+	}
+	for _, rec := range records {
+		if err := writer.Write(rec); err != nil {
+			return err
 		}
-		writer.Write(append(jsonBytes, '\n'))
 	}
-	writer.Flush() // 避免频繁 file.Sync()
-	return nil
+	return writer.Flush()
 }
 
 // 逐行读取 CSV，避免一次性加载大量数据
@@ -72,20 +73,47 @@ func fetchDomainsFromCSVStream(filename string, processFunc func(string, int)) e
 }
 func Process() {
 	var wg sync.WaitGroup
-	fileLock := &sync.Mutex{} // 用于写入 JSONL 时加锁
-	fileName := "init.jsonl"
-	//csvFile := "tranco_KJ7VW.csv"
-	//csvFile := "remaining_domains.csv" //3.19续
-	csvFile := "/home/wzq/scan-website/domains.csv"
+	cfg := config.Get()
+	fileName := cfg.Paths.InitJSONL
+	certFileName := cfg.Paths.CertsJSONL // 结果中只保存证书指纹，证书本体去重后写在这里
+	csvFile := cfg.Paths.DomainsCSV
 
 	// 控制并发的信号量，限制最大 Goroutine 数量
-	semaphore := make(chan struct{}, 200)
-	batchSize := 500
+	semaphore := make(chan struct{}, cfg.Concurrency.Discover)
+	batchSize := cfg.Concurrency.BatchSize
 	var currentBatch []models.DomainResult
 	var resultsMutex sync.Mutex
 
+	utils.DefaultCertStore = utils.NewCertStore()
+	if _, err := os.Stat(certFileName); err == nil {
+		// 续扫时已写过的证书不再重复写入
+		if err := utils.DefaultCertStore.Load(certFileName); err != nil {
+			fmt.Printf("Failed to load cert store: %v\n", err)
+		}
+	}
+
+	// 两个输出文件各只打开一次，续扫时追加；后缀为 .gz/.zst 时压缩写入
+	writer, err := pipeline.NewWriter(fileName, true)
+	if err != nil {
+		fmt.Printf("Failed to open %s: %v\n", fileName, err)
+		return
+	}
+	defer writer.Close()
+	certWriter, err := pipeline.NewWriter(certFileName, true)
+	if err != nil {
+		fmt.Printf("Failed to open %s: %v\n", certFileName, err)
+		return
+	}
+	defer certWriter.Close()
+	db, err := store.OpenConfigured() // 可选，未配置时为 nil
+	if err != nil {
+		fmt.Printf("Failed to open result db: %v\n", err)
+		return
+	}
+	defer db.Close()
+
 	// 使用流式读取 CSV
-	err := fetchDomainsFromCSVStream(csvFile, func(domain string, index int) {
+	err = fetchDomainsFromCSVStream(csvFile, func(domain string, index int) {
 		wg.Add(1)
 		semaphore <- struct{}{} // 占用一个信号量
 
@@ -101,9 +129,17 @@ func Process() {
 			resultsMutex.Lock()
 			currentBatch = append(currentBatch, domainResult)
 			if len(currentBatch) >= batchSize {
-				if err := writeResultToJSONLFile(fileName, currentBatch, fileLock); err != nil {
+				// 先写证书，保证结果中引用的证书都已落盘
+				if err := writeCertsToJSONLFile(certWriter, utils.DefaultCertStore.TakeNew()); err != nil {
+					fmt.Printf("Error writing certs to JSONL: %v\n", err)
+				}
+				utils.ResetChainCache()
+				if err := writeResultToJSONLFile(writer, currentBatch); err != nil {
 					fmt.Printf("Error writing batch to JSONL: %v\n", err)
 				}
+				if err := db.AddDomainResults(currentBatch); err != nil {
+					fmt.Printf("Error writing batch to result db: %v\n", err)
+				}
 				currentBatch = nil // 清空批次
 				freeMem()          // 释放内存，防止 OOM//3.17
 			}
@@ -120,12 +156,18 @@ func Process() {
 	wg.Wait()
 
 	// 处理剩余的批次
+	if err := writeCertsToJSONLFile(certWriter, utils.DefaultCertStore.TakeNew()); err != nil {
+		fmt.Printf("Error writing certs to JSONL: %v\n", err)
+	}
 	if len(currentBatch) > 0 {
-		if err := writeResultToJSONLFile(fileName, currentBatch, fileLock); err != nil {
+		if err := writeResultToJSONLFile(writer, currentBatch); err != nil {
 			fmt.Printf("Error writing last batch to JSONL: %v\n", err)
 		}
+		if err := db.AddDomainResults(currentBatch); err != nil {
+			fmt.Printf("Error writing last batch to result db: %v\n", err)
+		}
 		freeMem() // 释放最后的内存
 	}
 
-	fmt.Printf("Results successfully saved to %s\n", fileName)
+	fmt.Printf("Results successfully saved to %s (%d unique certificates in %s)\n", fileName, utils.DefaultCertStore.Len(), certFileName)
 }
@@ -2,7 +2,9 @@ package discover
 
 import (
 	"fmt"
+	"scan-website/config"
 	"scan-website/models"
+	"scan-website/provider"
 	"scan-website/utils"
 	"time"
 )
@@ -21,12 +23,24 @@ func ProcessDomain(domain string) models.DomainResult {
 		domainResult.ErrorMessages = append(domainResult.ErrorMessages, fmt.Sprintf("CNAME lookup error: %v", err))
 	}
 	domainResult.CNAME = cnameRecords
+	// 通配符探测，后面用来标记只是通配符命中的猜测和配置请求
+	wildcard, err := utils.DetectWildcard(domain)
+	if err != nil {
+		domainResult.ErrorMessages = append(domainResult.ErrorMessages, fmt.Sprintf("Wildcard probe error: %v", err))
+	}
+	domainResult.Wildcard = wildcard
 	// Autodiscover 查询
 	autodiscoverResults := QueryAutodiscover(domain, email)
 	domainResult.Autodiscover = autodiscoverResults
 	//domainResult.ErrorMessages = append(domainResult.ErrorMessages, errors...)
+	// MX 查询，Autoconfig 的 MX 方法也用这里的结果
+	mxResult, err := utils.LookupMX(domain)
+	if err != nil {
+		mxResult = &models.MXResult{Error: err.Error()}
+	}
+	domainResult.MX = mxResult
 	// Autoconfig 查询
-	autoconfigResults := QueryAutoconfig(domain, email)
+	autoconfigResults := QueryAutoconfig(domain, email, mxResult)
 	domainResult.Autoconfig = autoconfigResults
 	// if err := queryAutoconfig(domain, &result); err != nil {
 	// 	result.ErrorMessages = append(result.ErrorMessages, fmt.Sprintf("Autoconfig error: %v", err))
@@ -37,9 +51,28 @@ func ProcessDomain(domain string) models.DomainResult {
 	// if err := querySRV(domain, &result); err != nil {
 	// 	result.ErrorMessages = append(result.ErrorMessages, fmt.Sprintf("SRV error: %v", err))
 	// }
+	MarkWildcardConfigs(&domainResult)
 	//GUESS 9.13
-	guessResults := GuessMailServer(domain, 2*time.Second, 20)
+	guessResults := GuessMailServer(domain, config.Get().Timeouts.Guess.Std(), config.Get().Concurrency.Guess, wildcard)
 	domainResult.GUESS = guessResults
+	if config.Get().EmulateClients {
+		domainResult.ClientGuess = EmulateClientGuess(domain, guessResults, config.Get().Timeouts.TLSProbe.Std())
+	}
+	// 各目标的 A/AAAA 与分地址族连通性
+	ResolveTargets(&domainResult)
+	// MTA-STS / TLS-RPT
+	domainResult.MTASTS = QueryMTASTS(domain, mxResult)
+
+	if config.Get().ValidateDNSSEC {
+		ValidateDNSSEC(&domainResult)
+	}
+
+	if config.Get().ProbeTLSVersions {
+		ProbeConfigHostsTLS(&domainResult)
+	}
+
+	// 最后根据以上所有结果识别邮件服务商
+	provider.Annotate(&domainResult)
 
 	return domainResult
 }
@@ -2,10 +2,10 @@ package discover
 
 import (
 	"fmt"
+	"scan-website/config"
 	"scan-website/models"
-	"sort"
+	"scan-website/utils"
 	"strings"
-	"time"
 
 	"github.com/miekg/dns"
 )
@@ -40,15 +40,23 @@ func QuerySRV(domain string) models.SRVResult {
 		"_submission._tcp." + domain,
 		"_submissions._tcp." + domain,
 	}
+	otherServices := []string{
+		"_autodiscover._tcp." + domain,
+		"_caldav._tcp." + domain,
+		"_caldavs._tcp." + domain,
+		"_carddav._tcp." + domain,
+		"_carddavs._tcp." + domain,
+		"_jmap._tcp." + domain,
+	}
 
-	var recvRecords, sendRecords []models.SRVRecord
+	var recvRecords, sendRecords, otherRecords []models.SRVRecord
+	var notOffered []string
+	cnameCache := make(map[string][]string) // 同一目标只查一次 CNAME
 
 	// 查询(IMAP/POP3)
 	for _, service := range recvServices {
-		records, adBit, err := lookupSRVWithAD_srv(service)
-		//record_ADbit_SRV(service, "SRV_record_ad_srv.txt", domain, adBit)
-
-		if err != nil || len(records) == 0 {
+		records, adBit, offered, err := collectSRV(service, cnameCache)
+		if err != nil {
 			fmt.Printf("Failed to query SRV for %s or no records found: %v\n", service, err)
 			continue
 		}
@@ -63,28 +71,16 @@ func QuerySRV(domain string) models.SRVResult {
 		} else if strings.HasPrefix(service, "_pop3") {
 			dnsrecord.ADbit_pop3 = &adBit
 		}
-
-		// 添加 SRV 记录
-		for _, record := range records {
-			if record.Target == "." {
-				continue
-			}
-			recvRecords = append(recvRecords, models.SRVRecord{
-				Service:  service,
-				Priority: record.Priority,
-				Weight:   record.Weight,
-				Port:     record.Port,
-				Target:   record.Target,
-			})
+		if !offered {
+			notOffered = append(notOffered, service)
 		}
+		recvRecords = append(recvRecords, records...)
 	}
 
 	// 查询 (SMTP)
 	for _, service := range sendServices {
-		records, adBit, err := lookupSRVWithAD_srv(service)
-		//record_ADbit_SRV(service, "SRV_record_ad_srv.txt", domain, adBit)
-
-		if err != nil || len(records) == 0 {
+		records, adBit, offered, err := collectSRV(service, cnameCache)
+		if err != nil {
 			fmt.Printf("Failed to query SRV for %s or no records found: %v\n", service, err)
 			continue
 		}
@@ -95,49 +91,39 @@ func QuerySRV(domain string) models.SRVResult {
 		} else if strings.HasPrefix(service, "_submission") {
 			dnsrecord.ADbit_smtp = &adBit
 		}
-
-		// 添加 SRV 记录
-		for _, record := range records {
-			if record.Target == "." {
-				continue
-			}
-			sendRecords = append(sendRecords, models.SRVRecord{
-				Service:  service,
-				Priority: record.Priority,
-				Weight:   record.Weight,
-				Port:     record.Port,
-				Target:   record.Target,
-			})
+		if !offered {
+			notOffered = append(notOffered, service)
 		}
+		sendRecords = append(sendRecords, records...)
 	}
 
-	// 对收件服务和发件服务进行排序
-	sort.Slice(recvRecords, func(i, j int) bool {
-		if recvRecords[i].Priority == recvRecords[j].Priority {
-			return recvRecords[i].Weight > recvRecords[j].Weight
+	// 查询 Autodiscover、CalDAV/CardDAV、JMAP
+	for _, service := range otherServices {
+		records, _, offered, err := collectSRV(service, cnameCache)
+		if err != nil {
+			fmt.Printf("Failed to query SRV for %s or no records found: %v\n", service, err)
+			continue
 		}
-		return recvRecords[i].Priority < recvRecords[j].Priority
-	})
-
-	sort.Slice(sendRecords, func(i, j int) bool {
-		if sendRecords[i].Priority == sendRecords[j].Priority {
-			return sendRecords[i].Weight > sendRecords[j].Weight
+		if !offered {
+			notOffered = append(notOffered, service)
 		}
-		return sendRecords[i].Priority < sendRecords[j].Priority
-	})
+		otherRecords = append(otherRecords, records...)
+	}
 
 	// 返回组合后的结果
 	return models.SRVResult{
-		Domain:      domain,
-		DNSRecord:   &dnsrecord,
-		RecvRecords: recvRecords,
-		SendRecords: sendRecords,
+		Domain:       domain,
+		DNSRecord:    &dnsrecord,
+		RecvRecords:  recvRecords,
+		SendRecords:  sendRecords,
+		OtherRecords: otherRecords,
+		NotOffered:   notOffered,
 	}
 }
 
 func queryDNSManager(domain string) (string, bool, error) {
-	resolverAddr := "8.8.8.8:53" // Google Public DNS
-	timeout := 15 * time.Second  // DNS 查询超时时间
+	resolverAddr := config.Get().Resolver()
+	timeout := config.Get().Timeouts.DNS.Std() // DNS 查询超时时间
 
 	client := &dns.Client{
 		Net:     "udp",
@@ -180,10 +166,43 @@ func queryDNSManager(domain string) (string, bool, error) {
 	return "", false, fmt.Errorf("no SOA or NS records found for domain: %s", domain)
 }
 
+// 查询一个服务的 SRV 记录，按优先级、权重排成确定的顺序保存，并检查每个目标是否为 CNAME。
+// offered 为 false 表示该服务以 "." 明确声明不提供，此时不返回记录
+func collectSRV(service string, cnameCache map[string][]string) ([]models.SRVRecord, bool, bool, error) {
+	records, adBit, err := lookupSRVWithAD_srv(service)
+	if err != nil {
+		return nil, adBit, false, err
+	}
+	if len(records) == 0 {
+		return nil, adBit, false, fmt.Errorf("no srvRecord found")
+	}
+	if utils.IsSRVNotOffered(records) {
+		return nil, adBit, false, nil
+	}
+
+	var result []models.SRVRecord
+	for _, record := range utils.SortSRV(utils.FilterSRVTargets(records)) {
+		cnames, ok := cnameCache[record.Target]
+		if !ok {
+			cnames, _ = utils.LookupCNAME(record.Target)
+			cnameCache[record.Target] = cnames
+		}
+		result = append(result, models.SRVRecord{
+			Service:     service,
+			Priority:    record.Priority,
+			Weight:      record.Weight,
+			Port:        record.Port,
+			Target:      record.Target,
+			TargetCNAME: cnames,
+		})
+	}
+	return result, adBit, true, nil
+}
+
 func lookupSRVWithAD_srv(service string) ([]*dns.SRV, bool, error) {
 	// DNS Resolver configuration
-	resolverAddr := "8.8.8.8:53" // Google Public DNS
-	timeout := 15 * time.Second  // Timeout for DNS query
+	resolverAddr := config.Get().Resolver()
+	timeout := config.Get().Timeouts.DNS.Std() // Timeout for DNS query
 
 	// Create a DNS client
 	client := &dns.Client{
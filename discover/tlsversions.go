@@ -0,0 +1,52 @@
+package discover
+
+import (
+	"net/url"
+	"scan-website/config"
+	"scan-website/models"
+	"scan-website/utils"
+)
+
+// 收集 Autodiscover/Autoconfig 请求和重定向中出现过的所有 HTTPS 主机
+func configHTTPSHosts(result *models.DomainResult) [][2]string {
+	seen := make(map[string]bool)
+	var hosts [][2]string
+	add := func(raw string) {
+		u, err := url.Parse(raw)
+		if err != nil || u.Scheme != "https" || u.Hostname() == "" {
+			return
+		}
+		port := u.Port()
+		if port == "" {
+			port = "443"
+		}
+		key := u.Hostname() + ":" + port
+		if !seen[key] {
+			seen[key] = true
+			hosts = append(hosts, [2]string{u.Hostname(), port})
+		}
+	}
+	addRedirects := func(redirects []map[string]interface{}) {
+		for _, r := range redirects {
+			if s, ok := r["URL"].(string); ok {
+				add(s)
+			}
+		}
+	}
+	for _, r := range result.Autodiscover {
+		add(r.URI)
+		addRedirects(r.Redirects)
+	}
+	for _, r := range result.Autoconfig {
+		add(r.URI)
+		addRedirects(r.Redirects)
+	}
+	return hosts
+}
+
+// ProbeConfigHostsTLS 对每个配置服务器分别用 TLS 1.0~1.3 握手，结果写入 result.TLSVersions
+func ProbeConfigHostsTLS(result *models.DomainResult) {
+	for _, h := range configHTTPSHosts(result) {
+		result.TLSVersions = append(result.TLSVersions, utils.ProbeTLSVersions(h[0], h[1], config.Get().Timeouts.TLSProbe.Std()))
+	}
+}
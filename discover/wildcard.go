@@ -0,0 +1,39 @@
+package discover
+
+import (
+	"net/url"
+	"scan-website/models"
+	"scan-website/utils"
+	"strings"
+)
+
+// MarkWildcardConfigs 检查 Autodiscover/Autoconfig 请求中域名下的子域名（autodiscover./autoconfig. 等），
+// 地址与通配符解析相同的结果标记 ViaWildcard（通常是返回通用 HTML 页面的网站主机）
+func MarkWildcardConfigs(result *models.DomainResult) {
+	if result.Wildcard == nil {
+		return
+	}
+	cache := make(map[string]bool)
+	viaWildcard := func(rawURL string) bool {
+		u, err := url.Parse(rawURL)
+		if err != nil {
+			return false
+		}
+		host := strings.ToLower(u.Hostname())
+		if !strings.HasSuffix(host, "."+strings.ToLower(result.Domain)) {
+			return false
+		}
+		if v, ok := cache[host]; ok {
+			return v
+		}
+		ips, _ := utils.LookupHostAddrs(host)
+		cache[host] = utils.MatchesWildcard(result.Wildcard, ips)
+		return cache[host]
+	}
+	for i := range result.Autodiscover {
+		result.Autodiscover[i].ViaWildcard = viaWildcard(result.Autodiscover[i].URI)
+	}
+	for i := range result.Autoconfig {
+		result.Autoconfig[i].ViaWildcard = viaWildcard(result.Autoconfig[i].URI)
+	}
+}
@@ -0,0 +1,57 @@
+package export
+
+import (
+	"fmt"
+	"os"
+	"scan-website/models"
+	"scan-website/pipeline"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+// 每攒够这么多行写一次，行组大小由 parquet-go 控制
+const parquetBatch = 4096
+
+// WriteParquet 流式读取结果 JSONL（可以是 .gz/.zst），把每个域名的探测记录写成 zstd 压缩的 Parquet，
+// 返回写入的行数
+func WriteParquet(input, output string) (int64, error) {
+	file, err := os.Create(output)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create %s: %v", output, err)
+	}
+	defer file.Close()
+
+	writer := parquet.NewGenericWriter[ProbeRow](file, parquet.Compression(&parquet.Zstd))
+	var total int64
+	batch := make([]ProbeRow, 0, parquetBatch)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if _, err := writer.Write(batch); err != nil {
+			return fmt.Errorf("failed to write parquet rows: %v", err)
+		}
+		total += int64(len(batch))
+		batch = batch[:0]
+		return nil
+	}
+
+	err = pipeline.ReadAll(input, func(result models.DomainResult) error {
+		batch = append(batch, ProbeRows(&result)...)
+		if len(batch) >= parquetBatch {
+			return flush()
+		}
+		return nil
+	})
+	if err == nil {
+		err = flush()
+	}
+	if err != nil {
+		writer.Close()
+		return total, err
+	}
+	if err := writer.Close(); err != nil {
+		return total, fmt.Errorf("failed to finish parquet file: %v", err)
+	}
+	return total, file.Close()
+}
@@ -0,0 +1,172 @@
+package export
+
+import (
+	"net"
+	"net/url"
+	"scan-website/models"
+	"strconv"
+	"strings"
+)
+
+// 把 DomainResult 拆成每次探测一行的扁平记录，供 DuckDB/pandas 直接查询。
+// 完整的 XML 配置和证书链不导出，只保留长度和指纹，需要时按 domain+mechanism+index 回查 JSONL
+
+// ProbeRow 一次探测（一个 Autodiscover/Autoconfig 请求、一条 SRV 记录或一个猜测的主机端口）
+type ProbeRow struct {
+	DomainID    int64  `parquet:"domain_id"`
+	Domain      string `parquet:"domain,dict"`
+	Mechanism   string `parquet:"mechanism,dict"` // autodiscover / autoconfig / srv / guess
+	Method      string `parquet:"method,dict"`    // 请求方法、SRV 服务名或猜测的协议
+	Index       int32  `parquet:"index"`          // 同一机制内的序号
+	URI         string `parquet:"uri"`            // 请求的 URI；SRV/GUESS 为 host:port
+	Host        string `parquet:"host,dict"`
+	Port        int32  `parquet:"port"`
+	FinalURL    string `parquet:"final_url"` // 重定向链的最后一跳
+	Redirects   int32  `parquet:"redirects"` // 重定向次数
+	Status      int32  `parquet:"status"`    // 最后一跳的 HTTP 状态码，没有响应时为 0
+	ErrorCode   string `parquet:"error_code,dict"`
+	Error       string `parquet:"error"`
+	HasConfig   bool   `parquet:"has_config"` // 取得了 XML 配置
+	ConfigBytes int32  `parquet:"config_bytes"`
+	ViaWildcard bool   `parquet:"via_wildcard"`
+
+	CertFingerprint string `parquet:"cert_fingerprint"` // leaf 证书的 SHA-256
+	CertTrusted     bool   `parquet:"cert_trusted"`
+	CertHostMatch   bool   `parquet:"cert_hostname_match"`
+	CertExpired     bool   `parquet:"cert_expired"`
+	CertIssuer      string `parquet:"cert_issuer,dict"`
+}
+
+// ProbeRows 返回一个域名的所有探测记录
+func ProbeRows(result *models.DomainResult) []ProbeRow {
+	var rows []ProbeRow
+	base := ProbeRow{DomainID: int64(result.Domain_id), Domain: result.Domain}
+
+	for _, r := range result.Autodiscover {
+		row := base
+		row.Mechanism = "autodiscover"
+		row.Method = r.Method
+		row.Index = int32(r.Index)
+		row.ViaWildcard = r.ViaWildcard
+		fillHTTP(&row, r.URI, r.Redirects, r.Config, r.Error, r.CertInfo)
+		rows = append(rows, row)
+	}
+	for _, r := range result.Autoconfig {
+		row := base
+		row.Mechanism = "autoconfig"
+		row.Method = r.Method
+		row.Index = int32(r.Index)
+		row.ViaWildcard = r.ViaWildcard
+		fillHTTP(&row, r.URI, r.Redirects, r.Config, r.Error, r.CertInfo)
+		rows = append(rows, row)
+	}
+
+	i := 0
+	for _, records := range [][]models.SRVRecord{result.SRV.RecvRecords, result.SRV.SendRecords, result.SRV.OtherRecords} {
+		for _, r := range records {
+			row := base
+			row.Mechanism = "srv"
+			row.Method = r.Service
+			row.Index = int32(i)
+			row.Host = strings.TrimSuffix(r.Target, ".")
+			row.Port = int32(r.Port)
+			row.URI = net.JoinHostPort(row.Host, strconv.Itoa(int(r.Port)))
+			rows = append(rows, row)
+			i++
+		}
+	}
+	for _, service := range result.SRV.NotOffered {
+		row := base
+		row.Mechanism = "srv"
+		row.Method = service
+		row.Index = int32(i)
+		row.ErrorCode = "not_offered"
+		rows = append(rows, row)
+		i++
+	}
+
+	for i, g := range result.GUESS {
+		row := base
+		row.Mechanism = "guess"
+		row.Method = g.Protocol
+		row.Index = int32(i)
+		row.Host = g.Host
+		row.Port = int32(g.Port)
+		row.URI = net.JoinHostPort(g.Host, strconv.Itoa(g.Port))
+		row.ViaWildcard = g.ViaWildcard
+		row.Error = g.Error
+		switch {
+		case g.Confirmed:
+		case g.Reach:
+			row.ErrorCode = "unconfirmed"
+		default:
+			row.ErrorCode = "unreachable"
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// Autodiscover/Autoconfig 请求共用的字段
+func fillHTTP(row *ProbeRow, uri string, redirects []map[string]interface{}, config, errMsg string, ci *models.CertInfo) {
+	row.URI = uri
+	if u, err := url.Parse(uri); err == nil {
+		row.Host = u.Hostname()
+		port, _ := strconv.Atoi(u.Port())
+		if port == 0 {
+			switch u.Scheme {
+			case "https":
+				port = 443
+			case "http":
+				port = 80
+			}
+		}
+		row.Port = int32(port)
+	}
+	if n := len(redirects); n > 0 {
+		last := redirects[n-1]
+		row.Redirects = int32(n - 1)
+		if s, ok := last["URL"].(string); ok {
+			row.FinalURL = s
+		}
+		row.Status = int32(hopStatus(last["Status"]))
+	}
+	row.Error = errMsg
+	row.ConfigBytes = int32(len(config))
+	row.HasConfig = strings.HasPrefix(strings.TrimSpace(config), "<")
+	row.ErrorCode = errorCode(config, errMsg, row.Status)
+	if ci != nil {
+		row.CertFingerprint = ci.Fingerprint
+		row.CertTrusted = ci.IsTrusted
+		row.CertHostMatch = ci.IsHostnameMatch
+		row.CertExpired = ci.IsExpired
+		row.CertIssuer = ci.Issuer
+	}
+}
+
+// 重定向记录从 JSON 读回时状态码是 float64，直接来自扫描时是 int
+func hopStatus(v interface{}) int {
+	switch s := v.(type) {
+	case int:
+		return s
+	case float64:
+		return int(s)
+	}
+	return 0
+}
+
+// 把失败原因归为几类：Autodiscover 的 <ErrorCode>、非 2xx 状态码、响应内容无效、请求失败
+func errorCode(config, errMsg string, status int32) string {
+	switch {
+	case strings.HasPrefix(config, "Errorcode:"):
+		code, _, _ := strings.Cut(strings.TrimPrefix(config, "Errorcode:"), "-")
+		return "autodiscover_" + code
+	case strings.HasPrefix(config, "Non-valid"):
+		return "invalid_response"
+	case strings.HasPrefix(config, "Bad response"), status >= 300 && status != 0 && config == "":
+		return "http_" + strconv.Itoa(int(status))
+	case errMsg != "" && config == "":
+		return "request_failed"
+	}
+	return ""
+}
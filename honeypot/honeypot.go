@@ -0,0 +1,227 @@
+package honeypot
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Autodiscover 凭据泄露蜜罐：模拟 Autodiscover/Autoconfig 端点，记录客户端访问的路径和携带的认证头
+
+// Record 记录一次请求
+type Record struct {
+	Time            string `json:"time"`
+	Remote          string `json:"remote"`
+	Listener        string `json:"listener"` // http / https
+	SNI             string `json:"sni,omitempty"`
+	Host            string `json:"host"`
+	Method          string `json:"method"`
+	Path            string `json:"path"`
+	Query           string `json:"query,omitempty"`
+	UserAgent       string `json:"user_agent,omitempty"`
+	Endpoint        string `json:"endpoint"` // autodiscover / autoconfig / other
+	AuthScheme      string `json:"auth_scheme,omitempty"`
+	AuthUser        string `json:"auth_user,omitempty"`
+	AuthHasPassword bool   `json:"auth_has_password,omitempty"`
+	EMailAddress    string `json:"email,omitempty"` // POST 请求体中的 EMailAddress
+}
+
+type Server struct {
+	HTTPAddr  string // 如 ":80"，为空则不监听
+	HTTPSAddr string // 如 ":443"，为空则不监听
+	CertFile  string // 为空时使用自签名证书
+	KeyFile   string
+	Log       io.Writer // 每条 Record 以 JSONL 写入
+
+	mu      sync.Mutex
+	records []Record
+}
+
+// 与 getAutodiscoverConfig 发出的请求体一致
+type autodiscoverRequest struct {
+	XMLName xml.Name `xml:"Autodiscover"`
+	Request struct {
+		EMailAddress             string `xml:"EMailAddress"`
+		AcceptableResponseSchema string `xml:"AcceptableResponseSchema"`
+	} `xml:"Request"`
+}
+
+// 已带认证头时返回的错误响应，格式与 models.AutodiscoverResponse 对应
+const errorResponse = `<?xml version="1.0" encoding="utf-8"?>
+<Autodiscover xmlns="http://schemas.microsoft.com/exchange/autodiscover/responseschema/2006">
+	<Response xmlns="http://schemas.microsoft.com/exchange/autodiscover/outlook/responseschema/2006a">
+		<Error Time="%s" Id="2477272013">
+			<ErrorCode>600</ErrorCode>
+			<Message>Invalid Request</Message>
+			<DebugData />
+		</Error>
+	</Response>
+</Autodiscover>`
+
+func classifyPath(path string) string {
+	p := strings.ToLower(path)
+	switch {
+	case strings.HasPrefix(p, "/autodiscover/"):
+		return "autodiscover"
+	case p == "/mail/config-v1.1.xml" || strings.HasPrefix(p, "/.well-known/autoconfig/"):
+		return "autoconfig"
+	default:
+		return "other"
+	}
+}
+
+// 解析 Authorization 头，Basic 认证时解码出用户名
+func parseAuth(header string) (scheme, user string, hasPassword bool) {
+	if header == "" {
+		return "", "", false
+	}
+	scheme, value, _ := strings.Cut(header, " ")
+	if !strings.EqualFold(scheme, "Basic") {
+		return scheme, "", false
+	}
+	decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(value))
+	if err != nil {
+		return scheme, "", false
+	}
+	user, password, _ := strings.Cut(string(decoded), ":")
+	return scheme, user, password != ""
+}
+
+func (s *Server) handler(listener string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rec := Record{
+			Time:      time.Now().Format(time.RFC3339),
+			Remote:    r.RemoteAddr,
+			Listener:  listener,
+			Host:      r.Host,
+			Method:    r.Method,
+			Path:      r.URL.Path,
+			Query:     r.URL.RawQuery,
+			UserAgent: r.UserAgent(),
+			Endpoint:  classifyPath(r.URL.Path),
+		}
+		if r.TLS != nil {
+			rec.SNI = r.TLS.ServerName
+		}
+		rec.AuthScheme, rec.AuthUser, rec.AuthHasPassword = parseAuth(r.Header.Get("Authorization"))
+		if r.Method == http.MethodPost {
+			body, _ := io.ReadAll(io.LimitReader(r.Body, 64*1024))
+			var req autodiscoverRequest
+			if err := xml.Unmarshal(body, &req); err == nil {
+				rec.EMailAddress = req.Request.EMailAddress
+			}
+		}
+		s.record(rec)
+
+		switch {
+		case rec.Endpoint != "autodiscover":
+			http.NotFound(w, r)
+		case rec.AuthScheme == "":
+			// 与 Exchange 一致，先要求 Basic 认证，真实客户端此时会把凭据发过来
+			w.Header().Set("WWW-Authenticate", `Basic realm="`+r.Host+`"`)
+			w.WriteHeader(http.StatusUnauthorized)
+		default:
+			w.Header().Set("Content-Type", "text/xml; charset=utf-8")
+			fmt.Fprintf(w, errorResponse, time.Now().Format("15:04:05.0000000"))
+		}
+	}
+}
+
+func (s *Server) record(rec Record) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records = append(s.records, rec)
+	if s.Log != nil {
+		line, err := json.Marshal(rec)
+		if err != nil {
+			log.Printf("marshal honeypot record error: %v", err)
+			return
+		}
+		s.Log.Write(append(line, '\n'))
+	}
+}
+
+// Records 返回目前为止记录的所有请求
+func (s *Server) Records() []Record {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Record(nil), s.records...)
+}
+
+// ListenAndServe 同时启动 HTTP 和 HTTPS 监听，任一监听出错即返回
+func (s *Server) ListenAndServe() error {
+	errCh := make(chan error, 2)
+	if s.HTTPAddr != "" {
+		srv := &http.Server{Addr: s.HTTPAddr, Handler: s.handler("http")}
+		go func() { errCh <- srv.ListenAndServe() }()
+	}
+	if s.HTTPSAddr != "" {
+		tlsConfig, err := s.tlsConfig()
+		if err != nil {
+			return err
+		}
+		srv := &http.Server{Addr: s.HTTPSAddr, Handler: s.handler("https"), TLSConfig: tlsConfig}
+		go func() { errCh <- srv.ListenAndServeTLS("", "") }()
+	}
+	if s.HTTPAddr == "" && s.HTTPSAddr == "" {
+		return fmt.Errorf("no listen address configured")
+	}
+	return <-errCh
+}
+
+func (s *Server) tlsConfig() (*tls.Config, error) {
+	var cert tls.Certificate
+	var err error
+	if s.CertFile != "" {
+		cert, err = tls.LoadX509KeyPair(s.CertFile, s.KeyFile)
+	} else {
+		cert, err = selfSignedCert()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load honeypot certificate: %v", err)
+	}
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS10, // 与扫描端保持一致
+	}, nil
+}
+
+// 生成一张覆盖任意主机名的自签名证书（扫描端本身 InsecureSkipVerify）
+func selfSignedCert() (tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 64))
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: "autodiscover"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(365 * 24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		DNSNames:     []string{"autodiscover", "*"},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, nil
+}
@@ -0,0 +1,30 @@
+package honeypot
+
+import (
+	"context"
+	"net"
+	"scan-website/config"
+	"scan-website/discover"
+	"scan-website/models"
+)
+
+// Replay 把扫描器自身的 Autodiscover 探测序列打到本地蜜罐上：
+// 所有 80 端口的连接转发到 httpAddr，其余端口转发到 httpsAddr。
+// 会临时替换 discover.DialContext，不能与正常扫描并发执行
+func Replay(domain, email, httpAddr, httpsAddr string) []models.AutodiscoverResult {
+	dialer := &net.Dialer{Timeout: config.Get().Timeouts.Dial.Std()}
+	orig := discover.DialContext
+	discover.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		_, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, err
+		}
+		if port == "80" {
+			return dialer.DialContext(ctx, network, httpAddr)
+		}
+		return dialer.DialContext(ctx, network, httpsAddr)
+	}
+	defer func() { discover.DialContext = orig }()
+
+	return discover.QueryAutodiscover(domain, email)
+}
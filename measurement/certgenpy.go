@@ -1,13 +1,12 @@
 package measurement
 
 import (
-	"bufio"
 	"encoding/json"
 	"fmt"
-	"io"
 	"log"
-	"os"
+	"scan-website/config"
 	"scan-website/models"
+	"scan-website/pipeline"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -16,16 +15,6 @@ import (
 )
 
 func CountDomains_Certinfo(inputFile string) {
-	file, err := os.Open(inputFile)
-	if err != nil {
-		log.Fatalf("Failed to open file: %v", err)
-	}
-	defer file.Close()
-
-	reader := bufio.NewReader(file)
-	sem := make(chan struct{}, 50) // 控制并发数
-	var wg sync.WaitGroup
-
 	// 统计变量
 	var (
 		domainProcessed                int64
@@ -37,86 +26,115 @@ func CountDomains_Certinfo(inputFile string) {
 		no_trusted_autoconfig          = make(map[string]struct{})
 		no_match_hostname_autoconfig   = make(map[string]struct{})
 		no_indate_autoconfig           = make(map[string]struct{})
+
+		// 所有 HTTPS 端点（含重定向中间跳、返回错误或非配置内容的端点）
+		totalautodiscover_endpoint_cert   = make(map[string]struct{})
+		no_trusted_autodiscover_endpoint  = make(map[string]struct{})
+		no_match_hostname_autodiscover_ep = make(map[string]struct{})
+		no_indate_autodiscover_endpoint   = make(map[string]struct{})
+		totalautoconfig_endpoint_cert     = make(map[string]struct{})
+		no_trusted_autoconfig_endpoint    = make(map[string]struct{})
+		no_match_hostname_autoconfig_ep   = make(map[string]struct{})
+		no_indate_autoconfig_endpoint     = make(map[string]struct{})
 	)
 
 	// 互斥锁保护共享变量
 	var mu sync.Mutex
+	providers := newProviderIndex()
 
-	for {
-		line, err := reader.ReadString('\n')
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			log.Fatalf("Error reading line: %v", err)
+	opts := pipeline.Options{
+		Workers:  config.Get().Concurrency.Stats, // 控制并发数
+		Label:    "certinfo",
+		Progress: config.Get().ProgressInterval.Std(),
+	}
+	err := pipeline.ForEach(inputFile, opts, func(obj models.DomainResult) {
+		domain := obj.Domain
+		atomic.AddInt64(&domainProcessed, 1)
+		providers.add(obj)
+
+		// 所有端点的证书统计
+		mu.Lock()
+		for _, entry := range obj.Autodiscover {
+			for _, ci := range endpointCerts(entry.CertInfo, entry.Redirects) {
+				totalautodiscover_endpoint_cert[domain] = struct{}{}
+				if !ci.IsTrusted {
+					no_trusted_autodiscover_endpoint[domain] = struct{}{}
+				}
+				if !ci.IsHostnameMatch {
+					no_match_hostname_autodiscover_ep[domain] = struct{}{}
+				}
+				if ci.IsExpired {
+					no_indate_autodiscover_endpoint[domain] = struct{}{}
+				}
+			}
 		}
-
-		var obj models.DomainResult
-		if err := json.Unmarshal([]byte(line), &obj); err != nil {
-			log.Printf("❌ JSON 解析失败，跳过此行: %v", err)
-			continue
+		for _, entry := range obj.Autoconfig {
+			for _, ci := range endpointCerts(entry.CertInfo, entry.Redirects) {
+				totalautoconfig_endpoint_cert[domain] = struct{}{}
+				if !ci.IsTrusted {
+					no_trusted_autoconfig_endpoint[domain] = struct{}{}
+				}
+				if !ci.IsHostnameMatch {
+					no_match_hostname_autoconfig_ep[domain] = struct{}{}
+				}
+				if ci.IsExpired {
+					no_indate_autoconfig_endpoint[domain] = struct{}{}
+				}
+			}
 		}
-
-		sem <- struct{}{} // 占位
-		wg.Add(1)
-		go func(obj models.DomainResult) {
-			defer wg.Done()
-			defer func() { <-sem }()
-
-			domain := obj.Domain
-			atomic.AddInt64(&domainProcessed, 1)
-
-			// Autoconfig 统计
-			for _, entry := range obj.Autoconfig {
-				if entry.Config != "" {
-					doc := etree.NewDocument()
-					if err := doc.ReadFromString(entry.Config); err == nil {
-						if doc.SelectElement("clientConfig") != nil {
-							mu.Lock()
-							if entry.CertInfo != nil {
-								totalautoconfig_cert[domain] = struct{}{} //如果同一个域名不同路径的证书不一样呢
-								if !entry.CertInfo.IsTrusted {
-									no_trusted_autoconfig[domain] = struct{}{}
-								}
-								if !entry.CertInfo.IsHostnameMatch {
-									no_match_hostname_autoconfig[domain] = struct{}{}
-								}
-								if entry.CertInfo.IsExpired {
-									no_indate_autoconfig[domain] = struct{}{}
-								}
+		mu.Unlock()
+
+		// Autoconfig 统计
+		for _, entry := range obj.Autoconfig {
+			if entry.Config != "" {
+				doc := etree.NewDocument()
+				if err := doc.ReadFromString(entry.Config); err == nil {
+					if doc.SelectElement("clientConfig") != nil {
+						mu.Lock()
+						if entry.CertInfo != nil {
+							totalautoconfig_cert[domain] = struct{}{} //如果同一个域名不同路径的证书不一样呢
+							if !entry.CertInfo.IsTrusted {
+								no_trusted_autoconfig[domain] = struct{}{}
+							}
+							if !entry.CertInfo.IsHostnameMatch {
+								no_match_hostname_autoconfig[domain] = struct{}{}
+							}
+							if entry.CertInfo.IsExpired {
+								no_indate_autoconfig[domain] = struct{}{}
 							}
-
-							mu.Unlock()
 						}
+
+						mu.Unlock()
 					}
 				}
 			}
+		}
 
-			// Autodiscover 统计
-			for _, entry := range obj.Autodiscover {
-				if entry.Config != "" && !strings.HasPrefix(entry.Config, "Bad") && !strings.HasPrefix(entry.Config, "Errorcode") {
-					doc := etree.NewDocument()
-					if err := doc.ReadFromString(entry.Config); err == nil && doc.SelectElement("Autodiscover") != nil {
-						mu.Lock()
-						totalautodiscover_cert[domain] = struct{}{}
-						if !entry.CertInfo.IsTrusted {
-							no_trusted_autodiscover[domain] = struct{}{}
-						}
-						if !entry.CertInfo.IsHostnameMatch {
-							no_match_hostname_autodiscover[domain] = struct{}{}
-						}
-						if entry.CertInfo.IsExpired {
-							no_indate_autodiscover[domain] = struct{}{}
-						}
-						mu.Unlock()
+		// Autodiscover 统计
+		for _, entry := range obj.Autodiscover {
+			if entry.Config != "" && !strings.HasPrefix(entry.Config, "Bad") && !strings.HasPrefix(entry.Config, "Errorcode") {
+				doc := etree.NewDocument()
+				if err := doc.ReadFromString(entry.Config); err == nil && doc.SelectElement("Autodiscover") != nil {
+					mu.Lock()
+					totalautodiscover_cert[domain] = struct{}{}
+					if !entry.CertInfo.IsTrusted {
+						no_trusted_autodiscover[domain] = struct{}{}
+					}
+					if !entry.CertInfo.IsHostnameMatch {
+						no_match_hostname_autodiscover[domain] = struct{}{}
+					}
+					if entry.CertInfo.IsExpired {
+						no_indate_autodiscover[domain] = struct{}{}
 					}
+					mu.Unlock()
 				}
 			}
-		}(obj)
+		}
+	})
+	if err != nil {
+		log.Fatalf("Error reading %s: %v", inputFile, err)
 	}
 
-	wg.Wait()
-
 	// 输出统计结果
 	fmt.Printf("✅ Autodiscover可以获取配置的域名证书数量: %d\n", len(totalautodiscover_cert))
 	fmt.Printf("✅ Autodiscover证书不可信任的域名数量: %d\n", len(no_trusted_autodiscover))
@@ -127,6 +145,14 @@ func CountDomains_Certinfo(inputFile string) {
 	fmt.Printf("✅ Autoconfig证书不可信任的域名数量: %d\n", len(no_trusted_autoconfig))
 	fmt.Printf("✅ Autoconfig证书主机名不匹配的域名数量: %d\n", len(no_match_hostname_autoconfig))
 	fmt.Printf("✅ Autoconfig证书过期的域名数量: %d\n", len(no_indate_autoconfig))
+	fmt.Printf("✅ Autodiscover所有HTTPS端点有证书的域名数量: %d\n", len(totalautodiscover_endpoint_cert))
+	fmt.Printf("✅ Autodiscover端点证书不可信任的域名数量: %d\n", len(no_trusted_autodiscover_endpoint))
+	fmt.Printf("✅ Autodiscover端点证书主机名不匹配的域名数量: %d\n", len(no_match_hostname_autodiscover_ep))
+	fmt.Printf("✅ Autodiscover端点证书过期的域名数量: %d\n", len(no_indate_autodiscover_endpoint))
+	fmt.Printf("✅ Autoconfig所有HTTPS端点有证书的域名数量: %d\n", len(totalautoconfig_endpoint_cert))
+	fmt.Printf("✅ Autoconfig端点证书不可信任的域名数量: %d\n", len(no_trusted_autoconfig_endpoint))
+	fmt.Printf("✅ Autoconfig端点证书主机名不匹配的域名数量: %d\n", len(no_match_hostname_autoconfig_ep))
+	fmt.Printf("✅ Autoconfig端点证书过期的域名数量: %d\n", len(no_indate_autoconfig_endpoint))
 	fmt.Printf("✅ 一共处理了域名数量: %d\n", domainProcessed)
 
 	// 将 domain_stats 写入文件
@@ -139,11 +165,46 @@ func CountDomains_Certinfo(inputFile string) {
 		"no_trusted_autoconfig":          mapToSlice(no_trusted_autoconfig),
 		"no_match_hostname_autoconfig":   mapToSlice(no_match_hostname_autoconfig),
 		"no_indate_autoconfig":           mapToSlice(no_indate_autoconfig),
+
+		"totalautodiscover_endpoint_cert":   mapToSlice(totalautodiscover_endpoint_cert),
+		"no_trusted_autodiscover_endpoint":  mapToSlice(no_trusted_autodiscover_endpoint),
+		"no_match_hostname_autodiscover_ep": mapToSlice(no_match_hostname_autodiscover_ep),
+		"no_indate_autodiscover_endpoint":   mapToSlice(no_indate_autodiscover_endpoint),
+		"totalautoconfig_endpoint_cert":     mapToSlice(totalautoconfig_endpoint_cert),
+		"no_trusted_autoconfig_endpoint":    mapToSlice(no_trusted_autoconfig_endpoint),
+		"no_match_hostname_autoconfig_ep":   mapToSlice(no_match_hostname_autoconfig_ep),
+		"no_indate_autoconfig_endpoint":     mapToSlice(no_indate_autoconfig_endpoint),
 	}
 
-	if err := saveToJSON("./cert_stats.json", dataToSave); err != nil {
+	if err := saveToJSON(config.Get().Paths.CertStats, dataToSave); err != nil {
 		log.Fatalf("Error saving cert_stats: %v", err)
 	}
+	providers.save(config.Get().Paths.CertStats, dataToSave)
+}
+
+// 一条结果涉及的所有 HTTPS 证书：最终端点加上重定向链中每一跳（HTTP 端点没有证书，跳过）
+func endpointCerts(final *models.CertInfo, redirects []map[string]interface{}) []*models.CertInfo {
+	var certs []*models.CertInfo
+	if final != nil && len(final.ChainFingerprints) > 0 {
+		certs = append(certs, final)
+	}
+	for _, r := range redirects {
+		raw, ok := r["CertInfo"]
+		if !ok || raw == nil {
+			continue
+		}
+		// 从 JSON 读回时是 map，重新编码一次转成 CertInfo
+		b, err := json.Marshal(raw)
+		if err != nil {
+			continue
+		}
+		var ci models.CertInfo
+		if err := json.Unmarshal(b, &ci); err != nil || len(ci.ChainFingerprints) == 0 {
+			continue
+		}
+		certs = append(certs, &ci)
+	}
+	return certs
 }
 
 // func mapToSlice(m map[string]struct{}) []string {
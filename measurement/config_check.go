@@ -1,18 +1,18 @@
 package measurement
 
 import (
-	"bufio"
 	"encoding/json"
 	"fmt"
-	"io"
 	"log"
 	"os"
 	"path/filepath"
+	"scan-website/config"
 	"scan-website/models"
+	"scan-website/pipeline"
+	"scan-website/store"
 	"sort"
+	"strconv"
 	"strings"
-	"sync"
-	"sync/atomic"
 
 	"github.com/beevik/etree"
 )
@@ -505,6 +505,7 @@ func parseXMLConfig_Autodiscover(config string) (*models.MethodConfig, error) {
 		Method:       "Autodiscover",
 		Protocols:    protocols,
 		OverallCheck: finalStatus,
+		Settings:     settingsFromAutodiscover(config),
 	}
 	return result, nil
 
@@ -711,6 +712,7 @@ func parseXMLConfig_Autoconfig(config string) (*models.MethodConfig, error) {
 		Method:       "Autoconfig",
 		Protocols:    protocols,
 		OverallCheck: finalStatus,
+		Settings:     settingsFromAutoconfig(config),
 	}
 	return result, nil
 
@@ -736,7 +738,15 @@ func getServiceType(service string) string {
 	}
 }
 
-// 解析每个对象中的Autodiscover的config
+// 端口检查通过后再看目标是否为别名（RFC 2782 要求目标必须是 A/AAAA 记录所在的主机名）
+func checkSRVTarget(record models.SRVRecord, singleCheck string) string {
+	if singleCheck == "Valid" && len(record.TargetCNAME) > 0 {
+		return "Invalid, target is a CNAME"
+	}
+	return singleCheck
+}
+
+// 解析每个对象中的SRV记录
 func parseConfig_SRV(SRVResult *models.SRVResult) (*models.MethodConfig, error) {
 	var protocols []models.ProtocolInfo
 	finalStatus := "Invalid"
@@ -763,6 +773,7 @@ func parseConfig_SRV(SRVResult *models.SRVResult) (*models.MethodConfig, error)
 					protocol.SingleCheck = "Invalid, unknown protocol type"
 				}
 			}
+			protocol.SingleCheck = checkSRVTarget(RecvRecord, protocol.SingleCheck)
 			if protocol.SingleCheck == "Valid" {
 				finalStatus = "Valid"
 			}
@@ -791,110 +802,65 @@ func parseConfig_SRV(SRVResult *models.SRVResult) (*models.MethodConfig, error)
 					protocol.SingleCheck = "Invalid, unknown protocol type"
 				}
 			}
+			protocol.SingleCheck = checkSRVTarget(SendRecord, protocol.SingleCheck)
 			if protocol.SingleCheck == "Valid" {
 				finalStatus = "Valid"
 			}
 			protocols = append(protocols, protocol)
 		}
 	}
+	// 明确声明不提供的服务单独列出，不影响整体结果
+	for _, service := range SRVResult.NotOffered {
+		if getServiceType(service) == "Unknown" {
+			continue
+		}
+		protocols = append(protocols, models.ProtocolInfo{
+			Type:        getServiceType(service),
+			Server:      ".",
+			SingleCheck: "NotOffered",
+		})
+	}
 	result := &models.MethodConfig{
 		Method:       "SRV",
 		Protocols:    protocols,
 		OverallCheck: finalStatus,
+		Settings:     settingsFromSRV(*SRVResult),
 	}
 	return result, nil
 }
 
 func Check() {
-	file, err := os.Open("/home/wzq/scan-website/cmd/init.jsonl") // 这里修改为 jsonl
-	if err != nil {
-		log.Fatalf("Failed to open file: %v", err)
-	}
-	defer file.Close()
-
-	// 使用 bufio.Reader 逐行读取，避免 bufio.Scanner 的 64KB 限制
-	reader := bufio.NewReader(file)
+	cfg := config.Get()
 	//outputFile := "check_results320.jsonl" //3.26原
-	outputFile := "/home/wzq/scan-website/cmd/check_results914_onlysome.jsonl" //3.26
-
-	sem := make(chan struct{}, 10) // 控制并发数
-	var id int64 = 0
-	var wg sync.WaitGroup
-
-	for {
-		// 读取一整行
-		line, err := reader.ReadString('\n')
-		if err != nil {
-			if err == io.EOF {
-				break // 读取到文件结尾
-			}
-			log.Fatalf("Error reading line from file: %v", err)
-		}
-
-		// 解析 JSON
-		var obj models.DomainResult
-		if err := json.Unmarshal([]byte(line), &obj); err != nil {
-			log.Printf("Skipping invalid JSON line: %v", err)
-			continue
-		}
+	outputFile := cfg.Paths.CheckResults //3.26
 
-		sem <- struct{}{} // 先占位
-		wg.Add(1)
-		go func(obj models.DomainResult) {
-			defer wg.Done()
-			defer func() { <-sem }() // 释放占位
-
-			data := processDomainResult(obj)
-			curID := atomic.AddInt64(&id, 1)
-			fmt.Printf("%d\n", curID)
-
-			if data != nil {
-				if err := saveCheckResultAsJSONL(data, outputFile); err != nil {
-					log.Printf("Error saving check result for %v: %v", obj.Domain, err)
-				}
-			}
-		}(obj)
-	}
-
-	wg.Wait()
-}
-
-func saveCheckResultAsJSONL(result *models.DomainCheckResult, outputFile string) error {
-	// 如果 result 为空，返回错误，避免崩溃
-	if result == nil {
-		return fmt.Errorf("received nil result")
-	}
-
-	// 将结果转换为 JSON 字符串
-	jsonData, err := json.Marshal(result)
+	writer, err := pipeline.NewWriter(outputFile, true)
 	if err != nil {
-		return fmt.Errorf("failed to marshal check result to JSON: %v", err)
+		log.Fatalf("Failed to open output file: %v", err)
 	}
-
-	// 打开文件，如果文件不存在则创建
-	file, err := os.OpenFile(outputFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	defer writer.Close()
+	db, err := store.OpenConfigured() // 可选，未配置时为 nil
 	if err != nil {
-		return fmt.Errorf("failed to open file for appending: %v", err)
+		log.Fatalf("Failed to open result db: %v", err)
 	}
-	defer file.Close()
+	defer db.Close()
 
-	// 写入 JSON 数据
-	writer := bufio.NewWriter(file)
-	_, err = writer.Write(jsonData)
-	if err != nil {
-		return fmt.Errorf("failed to write to file: %v", err)
+	opts := pipeline.Options{
+		Workers:  cfg.Concurrency.Check, // 控制并发数
+		Ordered:  true,
+		Label:    "check",
+		Progress: cfg.ProgressInterval.Std(),
 	}
-
-	// 换行
-	_, err = writer.Write([]byte("\n"))
+	err = pipeline.Map(cfg.Paths.InitJSONL, writer, opts, func(obj models.DomainResult) (*models.DomainCheckResult, bool) {
+		data := processDomainResult(obj)
+		if err := db.AddCheckResult(data); err != nil {
+			log.Printf("Error saving check result for %v to result db: %v", obj.Domain, err)
+		}
+		return data, data != nil
+	})
 	if err != nil {
-		return fmt.Errorf("failed to write newline to file: %v", err)
+		log.Fatalf("Check failed: %v", err)
 	}
-
-	// 刷新缓冲区确保写入
-	writer.Flush()
-
-	return nil
 }
 
 // func check()之后
@@ -1219,46 +1185,99 @@ func saveCheckResultAsJSONL(result *models.DomainCheckResult, outputFile string)
 //		// }
 //		return protocolCount, Autodiscover_total
 //	}
-func Countsettings_Autodiscover_auto() (map[string]int, int) { //9.14
-	file, err := os.Open("/home/wzq/scan-website/cmd/check_results914_onlysome.jsonl")
-	if err != nil {
-		log.Fatalf("Failed to open file: %v", err)
-	}
-	defer file.Close()
 
-	reader := bufio.NewReader(file)
-	protocolCount := map[string]int{
-		"IMAP_143": 0, "IMAP_993": 0, "IMAP_unexp": 0,
-		"POP3_110": 0, "POP3_995": 0, "POP3_unexp": 0,
-		"SMTP_465": 0, "SMTP_587": 0, "SMTP_25": 0, "SMTP_2525": 0, "SMTP_unexp": 0,
-		"not_any_enc": 0, "enc_ssl": 0, "enc_tls": 0, "enc_auto": 0,
-		"enc_not_valid": 0, "ssl_not_valid": 0,
-		"ssl_on": 0, "ssl_off": 0, "ssl_default_on": 0,
-		"protocol_count":                         0,
-		"Error_root element <Autodiscover> lost": 0,
-		"Error_missing <Response> element":       0,
-		"Error_missing <User> element":           0,
-		"Error_missing <DisplayName> in <User>":  0,
-		"Error_missing <Account> element":        0,
-		"Error_<AccountType> must be 'email'":    0,
-		"Error_<Action> must be 'settings'":      0,
-		"OverallCheck_not_valid":                 0,
+// 统计中各协议的常见端口，其余端口计入 <协议>_unexp
+var countedPorts = map[string][]int{
+	"imap": {143, 993},
+	"pop3": {110, 995},
+	"smtp": {465, 587, 25, 2525},
+}
+
+// 端口和 TLS 方式按规范化后的 Settings 统计：label 给出计数键的前缀（如 IMAP、SRV 的 IMAPS），
+// 同一份配置中重复出现的端口/方式只记一次；返回本份配置中的邮件协议条目数
+func countSettings(flags map[string]bool, settings []models.ServerSetting, label func(models.ServerSetting) string, unexpFile, domain string) int {
+	for _, s := range settings {
+		prefix := label(s)
+		known := false
+		for _, port := range countedPorts[s.Protocol] {
+			if s.Port == port {
+				flags[fmt.Sprintf("%s_%d", prefix, port)] = true
+				known = true
+			}
+		}
+		if !known {
+			flags[prefix+"_unexp"] = true
+			save_content_tofile(unexpFile, strconv.Itoa(s.Port), "unexp "+strings.ToLower(prefix)+" port in domain "+domain+","+s.Host+",")
+		}
+		security := s.Security
+		if security == "" {
+			security = "unknown"
+		}
+		flags["security_"+security] = true
 	}
+	return len(settings)
+}
 
-	Autodiscover_total := 0
+func upperProtocol(s models.ServerSetting) string {
+	return strings.ToUpper(s.Protocol)
+}
 
-	for {
-		line, err := reader.ReadString('\n')
-		if err != nil {
-			break
-		}
+// SRV 的隐式 TLS 服务（_imaps、_pop3s、_submissions）单独计为 IMAPS/POP3S/SMTPS
+func srvProtocolLabel(s models.ServerSetting) string {
+	if s.Security == models.SecurityTLS {
+		return strings.ToUpper(s.Protocol) + "S"
+	}
+	return strings.ToUpper(s.Protocol)
+}
 
-		var domainCheckResult models.DomainCheckResult
-		if err := json.Unmarshal([]byte(line), &domainCheckResult); err != nil {
-			log.Printf("Error unmarshalling line: %v", err)
-			continue
+// 各项统计的计数键都预先置 0，输出中能看到没有出现过的项
+func newPortCount(labels ...string) map[string]int {
+	count := map[string]int{
+		"security_plain": 0, "security_starttls": 0, "security_tls": 0, "security_auto": 0, "security_unknown": 0,
+		"protocol_count": 0,
+	}
+	for _, label := range labels {
+		for _, port := range countedPorts[strings.ToLower(strings.TrimSuffix(label, "S"))] {
+			count[fmt.Sprintf("%s_%d", label, port)] = 0
 		}
+		count[label+"_unexp"] = 0
+	}
+	return count
+}
+
+func readCheckResults(fn func(models.DomainCheckResult)) {
+	path := config.Get().Paths.CheckResults // 可以是压缩文件
+	err := pipeline.ReadAll(path, func(r models.DomainCheckResult) error {
+		fn(r)
+		return nil
+	})
+	if err != nil {
+		log.Fatalf("Failed to read %s: %v", path, err)
+	}
+}
+
+func Countsettings_Autodiscover_auto() (map[string]int, int) { //9.14
+	protocolCount := newPortCount("IMAP", "POP3", "SMTP")
+	// 以下是原始字段的取值和规范检查，TLS 方式以 security_* 为准
+	for _, k := range []string{
+		"not_any_enc", "enc_ssl", "enc_tls", "enc_auto",
+		"enc_not_valid", "ssl_not_valid",
+		"ssl_on", "ssl_off", "ssl_default_on",
+		"Error_root element <Autodiscover> lost",
+		"Error_missing <Response> element",
+		"Error_missing <User> element",
+		"Error_missing <DisplayName> in <User>",
+		"Error_missing <Account> element",
+		"Error_<AccountType> must be 'email'",
+		"Error_<Action> must be 'settings'",
+		"OverallCheck_not_valid",
+	} {
+		protocolCount[k] = 0
+	}
+
+	Autodiscover_total := 0
 
+	readCheckResults(func(domainCheckResult models.DomainCheckResult) {
 		domain := domainCheckResult.Domain
 
 		if domainCheckResult.AutodiscoverCheckResult != nil {
@@ -1295,128 +1314,72 @@ func Countsettings_Autodiscover_auto() (map[string]int, int) { //9.14
 				}
 			}
 
-			// ---- Protocols 检查 ----
-			if methodResult.Protocols != nil {
-				flags := make(map[string]bool)
+			flags := make(map[string]bool)
 
-				for _, protocol := range methodResult.Protocols {
-					if protocol.SingleCheck != "Valid" {
-						save_content_tofile("./Autodiscover_Count_results.txt", domain, "protocol among Protocols is invalid: ")
-					}
+			// ---- 原始字段检查 ----
+			for _, protocol := range methodResult.Protocols {
+				if protocol.SingleCheck != "Valid" {
+					save_content_tofile("./Autodiscover_Count_results.txt", domain, "protocol among Protocols is invalid: ")
+				}
 
-					// 检查加密方式
-					if protocol.Encryption != "" {
-						switch protocol.Encryption {
-						case "SSL":
-							flags["enc_ssl"] = true
-						case "TLS":
-							flags["enc_tls"] = true
-						case "Auto":
-							flags["enc_auto"] = true
-						case "None":
-							flags["not_any_enc"] = true
-						default:
-							flags["enc_not_valid"] = true
-							protocolCount["enc_not_valid"]++
-							save_content_tofile("./Autodiscover_Count_results.txt", protocol.Encryption, "Not valid <Encryption> value in domain "+domain+":")
-						}
-					} else {
-						switch protocol.SSL {
-						case "off":
-							flags["ssl_off"] = true
-						case "on":
-							flags["ssl_on"] = true
-						case "default(on)":
-							flags["ssl_default_on"] = true
-						default:
-							flags["ssl_not_valid"] = true
-							save_content_tofile("./Autodiscover_Count_results.txt", protocol.SSL, "Not valid <SSL> value in domain "+domain+":")
-						}
+				if protocol.Encryption != "" {
+					switch protocol.Encryption {
+					case "SSL":
+						flags["enc_ssl"] = true
+					case "TLS":
+						flags["enc_tls"] = true
+					case "Auto":
+						flags["enc_auto"] = true
+					case "None":
+						flags["not_any_enc"] = true
+					default:
+						flags["enc_not_valid"] = true
+						protocolCount["enc_not_valid"]++
+						save_content_tofile("./Autodiscover_Count_results.txt", protocol.Encryption, "Not valid <Encryption> value in domain "+domain+":")
 					}
-
-					// 检查端口
-					switch protocol.Type {
-					case "IMAP":
-						protocolCount["protocol_count"]++
-						if protocol.Port == "143" {
-							flags["IMAP_143"] = true
-						} else if protocol.Port == "993" {
-							flags["IMAP_993"] = true
-						} else {
-							flags["IMAP_unexp"] = true
-							save_content_tofile("./Autodiscover_unexp_port_results.txt", protocol.Port, "unexp imap port in domain "+domain+","+protocol.Server+",")
-						}
-					case "POP3":
-						protocolCount["protocol_count"]++
-						if protocol.Port == "110" {
-							flags["POP3_110"] = true
-						} else if protocol.Port == "995" {
-							flags["POP3_995"] = true
-						} else {
-							flags["POP3_unexp"] = true
-							save_content_tofile("./Autodiscover_unexp_port_results.txt", protocol.Port, "unexp pop3 port in domain "+domain+","+protocol.Server+",")
-						}
-					case "SMTP":
-						protocolCount["protocol_count"]++
-						if protocol.Port == "465" {
-							flags["SMTP_465"] = true
-						} else if protocol.Port == "587" {
-							flags["SMTP_587"] = true
-						} else if protocol.Port == "25" {
-							flags["SMTP_25"] = true
-						} else if protocol.Port == "2525" {
-							flags["SMTP_2525"] = true
-						} else {
-							flags["SMTP_unexp"] = true
-							save_content_tofile("./Autodiscover_unexp_port_results.txt", protocol.Port, "unexp smtp port in domain "+domain+","+protocol.Server+",")
-						}
+				} else {
+					switch protocol.SSL {
+					case "off":
+						flags["ssl_off"] = true
+					case "on":
+						flags["ssl_on"] = true
+					case "default(on)":
+						flags["ssl_default_on"] = true
+					default:
+						flags["ssl_not_valid"] = true
+						save_content_tofile("./Autodiscover_Count_results.txt", protocol.SSL, "Not valid <SSL> value in domain "+domain+":")
 					}
 				}
+			}
 
-				// 最后统一加计数器
-				for k := range flags {
-					protocolCount[k]++
-				}
+			// ---- 端口和 TLS 方式 ----
+			protocolCount["protocol_count"] += countSettings(flags, methodResult.Settings, upperProtocol, "./Autodiscover_unexp_port_results.txt", domain)
+
+			// 最后统一加计数器
+			for k := range flags {
+				protocolCount[k]++
 			}
 		}
-	}
+	})
 
 	return protocolCount, Autodiscover_total
 }
 
 func Countsettings_Autoconfig_auto() (map[string]int, int) {
-	file, err := os.Open("/home/wzq/scan-website/cmd/check_results914_onlysome.jsonl")
-	if err != nil {
-		log.Fatalf("Failed to open file: %v", err)
-	}
-	defer file.Close()
-
-	reader := bufio.NewReader(file)
-	protocolCount := map[string]int{
-		"IMAP_143": 0, "IMAP_993": 0, "IMAP_unexp": 0,
-		"POP3_110": 0, "POP3_995": 0, "POP3_unexp": 0,
-		"SMTP_465": 0, "SMTP_587": 0, "SMTP_25": 0, "SMTP_2525": 0, "SMTP_unexp": 0,
-		"SSL": 0, "TLS": 0, "STARTTLS": 0, "plain": 0,
-		"ssl_not_valid": 0, "protocol_count": 0,
-		"Error_missing root element <clientConfig>": 0,
-		"Error_missing <emailProvider> element":     0,
-		"finalStatus_not_valid":                     0,
+	protocolCount := newPortCount("IMAP", "POP3", "SMTP")
+	// socketType 的原始取值和规范检查，TLS 方式以 security_* 为准
+	for _, k := range []string{
+		"SSL", "TLS", "STARTTLS", "plain", "ssl_not_valid",
+		"Error_missing root element <clientConfig>",
+		"Error_missing <emailProvider> element",
+		"finalStatus_not_valid",
+	} {
+		protocolCount[k] = 0
 	}
 
 	Autoconfig_total := 0
 
-	for {
-		line, err := reader.ReadString('\n')
-		if err != nil {
-			break
-		}
-
-		var domainCheckResult models.DomainCheckResult
-		if err := json.Unmarshal([]byte(line), &domainCheckResult); err != nil {
-			log.Printf("Error unmarshalling line: %v", err)
-			continue
-		}
-
+	readCheckResults(func(domainCheckResult models.DomainCheckResult) {
 		domain := domainCheckResult.Domain
 		if domainCheckResult.AutoconfigCheckResult != nil {
 			Autoconfig_total++
@@ -1438,316 +1401,71 @@ func Countsettings_Autoconfig_auto() (map[string]int, int) {
 				continue
 			}
 
-			// ---- Protocols 检查 ----
-			if methodResult.Protocols != nil {
-				flags := make(map[string]bool)
-
-				for _, protocol := range methodResult.Protocols {
-					if protocol.SingleCheck != "Valid" {
-						save_content_tofile("./Autoconfig_Count_results.txt", domain, "protocol among Protocols is invalid: ")
-					}
-
-					// ---- SSL / TLS / STARTTLS / plain ----
-					switch strings.ToLower(protocol.SSL) {
-					case "ssl":
-						flags["SSL"] = true
-					case "tls":
-						flags["TLS"] = true
-					case "starttls":
-						flags["STARTTLS"] = true
-					case "plain":
-						flags["plain"] = true
-					default:
-						flags["ssl_not_valid"] = true
-						save_content_tofile("./Autoconfig_Count_results.txt", protocol.SSL, "Not valid <SSL> value in domain "+domain+":")
-					}
+			flags := make(map[string]bool)
 
-					// ---- 端口检查 ----
-					switch strings.ToLower(protocol.Type) {
-					case "imap":
-						protocolCount["protocol_count"]++
-						if protocol.Port == "143" {
-							flags["IMAP_143"] = true
-						} else if protocol.Port == "993" {
-							flags["IMAP_993"] = true
-						} else {
-							flags["IMAP_unexp"] = true
-							save_content_tofile("./Autoconfig_unexp_port_results.txt", protocol.Port, "unexp imap port in domain "+domain+","+protocol.Server+",")
-						}
-					case "pop3":
-						protocolCount["protocol_count"]++
-						if protocol.Port == "110" {
-							flags["POP3_110"] = true
-						} else if protocol.Port == "995" {
-							flags["POP3_995"] = true
-						} else {
-							flags["POP3_unexp"] = true
-							save_content_tofile("./Autoconfig_unexp_port_results.txt", protocol.Port, "unexp pop3 port in domain "+domain+","+protocol.Server+",")
-						}
-					case "smtp":
-						protocolCount["protocol_count"]++
-						if protocol.Port == "465" {
-							flags["SMTP_465"] = true
-						} else if protocol.Port == "587" {
-							flags["SMTP_587"] = true
-						} else if protocol.Port == "25" {
-							flags["SMTP_25"] = true
-						} else if protocol.Port == "2525" {
-							flags["SMTP_2525"] = true
-						} else {
-							flags["SMTP_unexp"] = true
-							save_content_tofile("./Autoconfig_unexp_port_results.txt", protocol.Port, "unexp smtp port in domain "+domain+","+protocol.Server+",")
-						}
-					}
+			// ---- 原始字段检查 ----
+			for _, protocol := range methodResult.Protocols {
+				if protocol.SingleCheck != "Valid" {
+					save_content_tofile("./Autoconfig_Count_results.txt", domain, "protocol among Protocols is invalid: ")
 				}
 
-				// ---- 统一累计 ----
-				for k := range flags {
-					protocolCount[k]++
+				switch strings.ToLower(protocol.SSL) {
+				case "ssl":
+					flags["SSL"] = true
+				case "tls":
+					flags["TLS"] = true
+				case "starttls":
+					flags["STARTTLS"] = true
+				case "plain":
+					flags["plain"] = true
+				default:
+					flags["ssl_not_valid"] = true
+					save_content_tofile("./Autoconfig_Count_results.txt", protocol.SSL, "Not valid <SSL> value in domain "+domain+":")
 				}
 			}
+
+			// ---- 端口和 TLS 方式 ----
+			protocolCount["protocol_count"] += countSettings(flags, methodResult.Settings, upperProtocol, "./Autoconfig_unexp_port_results.txt", domain)
+
+			// ---- 统一累计 ----
+			for k := range flags {
+				protocolCount[k]++
+			}
 		}
-	}
+	})
 
 	return protocolCount, Autoconfig_total
 }
 
 func Countsettings_SRV() (map[string]int, int) {
-	file, err := os.Open("/home/wzq/scan-website/cmd/check_results914_onlysome.jsonl")
-	if err != nil {
-		log.Fatalf("Failed to open file: %v", err)
-	}
-	defer file.Close()
-	reader := bufio.NewReader(file)
-	protocolCount := map[string]int{
-		"IMAP_143":    0,
-		"IMAP_993":    0,
-		"IMAP_unexp":  0,
-		"IMAPS_143":   0,
-		"IMAPS_993":   0,
-		"IMAPS_unexp": 0,
-		"POP3_110":    0,
-		"POP3_995":    0,
-		"POP3_unexp":  0,
-		"POP3S_110":   0,
-		"POP3S_995":   0,
-		"POP3S_unexp": 0,
-		"SMTP_465":    0,
-		"SMTP_587":    0,
-		"SMTP_25":     0,
-		"SMTP_2525":   0,
-		"SMTP_unexp":  0,
-		"SMTPS_465":   0,
-		"SMTPS_587":   0,
-		"SMTPS_25":    0,
-		"SMTPS_2525":  0,
-		"SMTPS_unexp": 0,
-		// "SSL":            0,
-		// "TLS":            0,
-		// "STARTTLS":       0,
-		// "plain":          0,
-		// "ssl_not_valid":  0,
-		"protocol_count": 0,
-		// "Error_missing root element <clientConfig>": 0,
-		// "Error_missing <emailProvider> element":     0,
-		"OverallCheck_not_valid": 0,
-	}
+	protocolCount := newPortCount("IMAP", "IMAPS", "POP3", "POP3S", "SMTP", "SMTPS")
+	protocolCount["OverallCheck_not_valid"] = 0
 	SRV_total := 0
 
-	for {
-		line, err := reader.ReadString('\n') // ✅ 按行读取
-		if err != nil {
-			break // 读完所有数据后退出
-		}
-		var domainCheckResult models.DomainCheckResult
-		// 解析每一行的 JSON
-		err = json.Unmarshal([]byte(line), &domainCheckResult)
-		if err != nil {
-			log.Printf("Error unmarshalling line: %v", err)
-			continue
+	readCheckResults(func(domainCheckResult models.DomainCheckResult) {
+		srv := domainCheckResult.SRVCheckResult
+		if srv == nil {
+			return
 		}
 		domain := domainCheckResult.Domain
-		if domainCheckResult.SRVCheckResult != nil {
-			SRV_total += 1
-		}
-		if domainCheckResult.SRVCheckResult != nil && domainCheckResult.SRVCheckResult.OverallCheck != "Valid" {
+		SRV_total += 1
+		if srv.OverallCheck != "Valid" {
 			protocolCount["OverallCheck_not_valid"]++
-			//continue
-		}
-		if domainCheckResult.SRVCheckResult != nil && domainCheckResult.SRVCheckResult.Protocols != nil {
-			flag_imap_143 := false
-			flag_imap_993 := false
-			flag_imap_unexp := false
-			flag_imaps_143 := false
-			flag_imaps_993 := false
-			flag_imaps_unexp := false
-			flag_pop3_110 := false
-			flag_pop3_995 := false
-			flag_pop3_unexp := false
-			flag_pop3s_110 := false
-			flag_pop3s_995 := false
-			flag_pop3s_unexp := false
-			flag_smtp_587 := false
-			flag_smtp_465 := false
-			flag_smtp_unexp := false
-			flag_smtp_25 := false
-			flag_smtp_2525 := false
-			flag_smtps_587 := false
-			flag_smtps_465 := false
-			flag_smtps_unexp := false
-			flag_smtps_25 := false
-			flag_smtps_2525 := false
-			for _, protocol := range domainCheckResult.SRVCheckResult.Protocols {
-				if protocol.SingleCheck != "Valid" {
-					save_content_tofile("./SRV_Count_results.txt", domain, "protocol among Protocols is invalid': ")
-				}
-				switch protocol.Type {
-				case "IMAP":
-					protocolCount["protocol_count"]++
-					if protocol.Port == "143" {
-						flag_imap_143 = true
-					} else if protocol.Port == "993" {
-						flag_imap_993 = true
-					} else {
-						save_content_tofile("./SRV_unexp_port_results.txt", protocol.Port, "unexp imap port in domain "+domain+","+protocol.Server+",")
-						flag_imap_unexp = true
-					}
-				case "IMAPS":
-					protocolCount["protocol_count"]++
-					if protocol.Port == "143" {
-						flag_imaps_143 = true
-					} else if protocol.Port == "993" {
-						flag_imaps_993 = true
-					} else {
-						save_content_tofile("./SRV_unexp_port_results.txt", protocol.Port, "unexp imaps port in domain "+domain+","+protocol.Server+",")
-						flag_imaps_unexp = true
-					}
-				case "POP3":
-					protocolCount["protocol_count"]++
-					if protocol.Port == "110" {
-						flag_pop3_110 = true
-					} else if protocol.Port == "995" {
-						flag_pop3_995 = true
-					} else {
-						save_content_tofile("./SRV_unexp_port_results.txt", protocol.Port, "unexp pop3 port in domain "+domain+","+protocol.Server+",")
-						flag_pop3_unexp = true
-					}
-				case "POP3S":
-					protocolCount["protocol_count"]++
-					if protocol.Port == "110" {
-						flag_pop3s_110 = true
-					} else if protocol.Port == "995" {
-						flag_pop3s_995 = true
-					} else {
-						save_content_tofile("./SRV_unexp_port_results.txt", protocol.Port, "unexp pop3s port in domain "+domain+","+protocol.Server+",")
-						flag_pop3s_unexp = true
-					}
-				case "SMTP":
-					protocolCount["protocol_count"]++
-					if protocol.Port == "465" {
-						flag_smtp_465 = true
-					} else if protocol.Port == "587" {
-						flag_smtp_587 = true
-					} else if protocol.Port == "25" {
-						flag_smtp_25 = true
-					} else if protocol.Port == "2525" {
-						flag_smtp_2525 = true
-					} else {
-						save_content_tofile("./SRV_unexp_port_results.txt", protocol.Port, "unexp smtp port in domain "+domain+","+protocol.Server+",")
-						flag_smtp_unexp = true
-					}
-				case "SMTPS":
-					protocolCount["protocol_count"]++
-					if protocol.Port == "465" {
-						flag_smtps_465 = true
-					} else if protocol.Port == "587" {
-						flag_smtps_587 = true
-					} else if protocol.Port == "25" {
-						flag_smtps_25 = true
-					} else if protocol.Port == "2525" {
-						flag_smtps_2525 = true
-					} else {
-						save_content_tofile("./SRV_unexp_port_results.txt", protocol.Port, "unexp smtps port in domain "+domain+","+protocol.Server+",")
-						flag_smtps_unexp = true
-					}
-				}
-			}
-
-			if flag_imap_unexp {
-				protocolCount["IMAP_unexp"]++
-			}
-			if flag_imap_143 {
-				protocolCount["IMAP_143"]++
-			}
-			if flag_imap_993 {
-				protocolCount["IMAP_993"]++
-			}
-			if flag_imaps_unexp {
-				protocolCount["IMAPS_unexp"]++
-			}
-			if flag_imaps_143 {
-				protocolCount["IMAPS_143"]++
-			}
-			if flag_imaps_993 {
-				protocolCount["IMAPS_993"]++
-			}
-
-			if flag_pop3_unexp {
-				protocolCount["POP3_unexp"]++
-			}
-			if flag_pop3_110 {
-				protocolCount["POP3_110"]++
-			}
-			if flag_pop3_995 {
-				protocolCount["POP3_995"]++
-			}
-			if flag_pop3s_unexp {
-				protocolCount["POP3S_unexp"]++
-			}
-			if flag_pop3s_110 {
-				protocolCount["POP3S_110"]++
-			}
-			if flag_pop3s_995 {
-				protocolCount["POP3S_995"]++
-			}
-
-			if flag_smtp_unexp {
-				protocolCount["SMTP_unexp"]++
-			}
-			if flag_smtp_465 {
-				protocolCount["SMTP_465"]++
-			}
-			if flag_smtp_587 {
-				protocolCount["SMTP_587"]++
-			}
-			if flag_smtp_25 {
-				protocolCount["SMTP_25"]++
-			}
-			if flag_smtp_2525 {
-				protocolCount["SMTP_2525"]++
-			}
-			if flag_smtps_unexp {
-				protocolCount["SMTPS_unexp"]++
-			}
-			if flag_smtps_465 {
-				protocolCount["SMTPS_465"]++
-			}
-			if flag_smtps_587 {
-				protocolCount["SMTPS_587"]++
-			}
-			if flag_smtps_25 {
-				protocolCount["SMTPS_25"]++
-			}
-			if flag_smtps_2525 {
-				protocolCount["SMTPS_2525"]++
+		}
+		for _, protocol := range srv.Protocols {
+			if protocol.SingleCheck != "Valid" {
+				save_content_tofile("./SRV_Count_results.txt", domain, "protocol among Protocols is invalid': ")
 			}
-
 		}
 
-	}
+		flags := make(map[string]bool)
+		protocolCount["protocol_count"] += countSettings(flags, srv.Settings, srvProtocolLabel, "./SRV_unexp_port_results.txt", domain)
+		for k := range flags {
+			protocolCount[k]++
+		}
+	})
 
 	return protocolCount, SRV_total
-
 }
 
 func save_content_tofile(fileName string, content string, inputFile string) { //记录数据统计结果到文件的函数
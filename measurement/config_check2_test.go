@@ -0,0 +1,113 @@
+package measurement
+
+import (
+	"reflect"
+	"scan-website/models"
+	"testing"
+)
+
+func autoconfigXML(servers string) string {
+	return `<clientConfig version="1.1"><emailProvider id="example.com">` + servers + `</emailProvider></clientConfig>`
+}
+
+func TestProcessDomainResult2Diffs(t *testing.T) {
+	const (
+		postURI = "https://autodiscover.example.com/autodiscover/autodiscover.xml"
+		getURI  = "http://example.com/autodiscover/autodiscover.xml"
+	)
+	imapTLS := `<Protocol><Type>IMAP</Type><Server>mail.example.com</Server><Port>993</Port></Protocol>`
+	imapPlain := `<Protocol><Type>IMAP</Type><Server>mail.example.com</Server><Port>993</Port><SSL>off</SSL></Protocol>`
+	smtp := `<Protocol><Type>SMTP</Type><Server>smtp.example.com</Server><Port>587</Port></Protocol>`
+
+	tests := []struct {
+		desc  string
+		obj   models.DomainResult
+		diffs []ConsistencyDiff
+		// AutodiscoverInconsistent, AutoconfigInconsistent, MechanismDiff
+		autodiscover, autoconfig, mechanism bool
+	}{
+		{
+			desc: "consistent",
+			obj: models.DomainResult{
+				Domain: "example.com",
+				Autodiscover: []models.AutodiscoverResult{
+					{Index: 1, Method: "POST", URI: postURI, Config: autodiscoverXML(imapTLS + smtp)},
+					{Index: 2, Method: "GET", URI: getURI, Config: autodiscoverXML(smtp + imapTLS)},
+				},
+				SRV: models.SRVResult{
+					RecvRecords: []models.SRVRecord{{Service: "_imaps._tcp.example.com.", Port: 993, Target: "mail.example.com."}},
+				},
+			},
+		},
+		{
+			desc: "path and mechanism differences",
+			obj: models.DomainResult{
+				Domain: "example.com",
+				Autodiscover: []models.AutodiscoverResult{
+					{Index: 1, Method: "POST", URI: postURI, Config: autodiscoverXML(imapTLS + smtp)},
+					// 同一 imap-993 的 TLS 方式不同，且缺少 smtp-587
+					{Index: 2, Method: "GET", URI: getURI, Config: autodiscoverXML(imapPlain)},
+				},
+				Autoconfig: []models.AutoconfigResult{
+					{Index: 1, Method: "ISP", URI: "https://autoconfig.example.com/mail/config-v1.1.xml",
+						Config: autoconfigXML(`<incomingServer type="imap"><hostname>imap.example.com</hostname><port>993</port><socketType>SSL</socketType></incomingServer>`)},
+				},
+				SRV: models.SRVResult{
+					RecvRecords: []models.SRVRecord{{Service: "_imaps._tcp.example.com.", Port: 993, Target: "mail.example.com."}},
+					SendRecords: []models.SRVRecord{{Service: "_submission._tcp.example.com.", Port: 587, Target: "smtp.example.com."}},
+				},
+			},
+			diffs: []ConsistencyDiff{
+				{Scope: "internal", Key: "imap-993", Field: "settings",
+					Base: "autodiscover#1 POST " + postURI, Other: "autodiscover#2 GET " + getURI,
+					Old: []string{"imap mail.example.com:993 (tls)"}, New: []string{"imap mail.example.com:993 (plain)"}},
+				{Scope: "internal", Key: "smtp-587", Field: "missing",
+					Base: "autodiscover#1 POST " + postURI, Other: "autodiscover#2 GET " + getURI,
+					Old: []string{"smtp smtp.example.com:587 (starttls)"}},
+				{Scope: "cross", Key: "imap-993", Field: "host", Base: "autodiscover", Other: "autoconfig",
+					Old: []string{"mail.example.com"}, New: []string{"imap.example.com"}},
+				{Scope: "cross", Key: "imap-993", Field: "security", Base: "autodiscover", Other: "autoconfig",
+					Old: []string{"plain", "tls"}, New: []string{"tls"}},
+				{Scope: "cross", Key: "imap-993", Field: "security", Base: "autodiscover", Other: "srv",
+					Old: []string{"plain", "tls"}, New: []string{"tls"}},
+			},
+			autodiscover: true, mechanism: true,
+		},
+		{
+			desc: "autoconfig paths differ only",
+			obj: models.DomainResult{
+				Domain: "example.com",
+				Autoconfig: []models.AutoconfigResult{
+					{Index: 1, Method: "ISP", URI: "https://a/config",
+						Config: autoconfigXML(`<incomingServer type="imap"><hostname>imap.example.com</hostname><port>993</port><socketType>SSL</socketType></incomingServer>`)},
+					{Index: 2, Method: "ISPDB", URI: "https://b/config",
+						Config: autoconfigXML(`<incomingServer type="imap"><hostname>imap2.example.com</hostname><port>993</port><socketType>SSL</socketType></incomingServer>`)},
+				},
+			},
+			diffs: []ConsistencyDiff{
+				{Scope: "internal", Key: "imap-993", Field: "settings",
+					Base: "autoconfig#1 ISP https://a/config", Other: "autoconfig#2 ISPDB https://b/config",
+					Old: []string{"imap imap.example.com:993 (tls)"}, New: []string{"imap imap2.example.com:993 (tls)"}},
+			},
+			autoconfig: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			got := processDomainResult2(tt.obj)
+			if got == nil {
+				t.Fatal("processDomainResult2 returned nil")
+			}
+			if !reflect.DeepEqual(got.Diffs, tt.diffs) {
+				t.Errorf("Diffs =\n%+v\nwant\n%+v", got.Diffs, tt.diffs)
+			}
+			if got.AutodiscoverInconsistent != tt.autodiscover || got.AutoconfigInconsistent != tt.autoconfig || got.MechanismDiff != tt.mechanism {
+				t.Errorf("flags = autodiscover %v, autoconfig %v, mechanism %v; want %v, %v, %v",
+					got.AutodiscoverInconsistent, got.AutoconfigInconsistent, got.MechanismDiff, tt.autodiscover, tt.autoconfig, tt.mechanism)
+			}
+			if want := len(tt.diffs) > 0; got.Inconsistent != want {
+				t.Errorf("Inconsistent = %v, want %v", got.Inconsistent, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,135 @@
+package measurement
+
+import (
+	"log"
+	"net"
+	"scan-website/config"
+	"scan-website/models"
+	"scan-website/pipeline"
+	"scan-website/store"
+	"scan-website/utils"
+	"strconv"
+	"strings"
+)
+
+// CheckDANE 对 init.jsonl 中每个域名发现的邮件服务器（SRV/Autodiscover/Autoconfig/GUESS/MX）
+// 建立连接取得证书链，查询 _port._tcp.host 的 TLSA 并匹配，结果写入 Paths.DANEResults
+func CheckDANE() {
+	cfg := config.Get()
+	writer, err := pipeline.NewWriter(cfg.Paths.DANEResults, true)
+	if err != nil {
+		log.Fatalf("Failed to open output file: %v", err)
+	}
+	defer writer.Close()
+	db, err := store.OpenConfigured() // 可选，未配置时为 nil
+	if err != nil {
+		log.Fatalf("Failed to open result db: %v", err)
+	}
+	defer db.Close()
+
+	opts := pipeline.Options{
+		Workers:  cfg.Concurrency.Check,
+		Ordered:  true,
+		Label:    "dane",
+		Progress: cfg.ProgressInterval.Std(),
+	}
+	err = pipeline.Map(cfg.Paths.InitJSONL, writer, opts, func(obj models.DomainResult) (*models.DANECheckResult, bool) {
+		data := processDomainDANE(obj)
+		if len(data.Hosts) == 0 {
+			return data, false
+		}
+		if err := db.AddDANEResult(data); err != nil {
+			log.Printf("Error saving DANE result for %v to result db: %v", obj.Domain, err)
+		}
+		return data, true
+	})
+	if err != nil {
+		log.Fatalf("CheckDANE failed: %v", err)
+	}
+}
+
+func processDomainDANE(obj models.DomainResult) *models.DANECheckResult {
+	result := &models.DANECheckResult{Domain: obj.Domain}
+	timeout := config.Get().Timeouts.TLSProbe.Std()
+	for _, h := range collectMailHosts(obj) {
+		state, err := utils.MailTLSHandshake(h.Host, h.Port, h.Protocol, h.Mode, timeout)
+		if err != nil {
+			h.Error = err.Error()
+			h.DANE = utils.CheckDANE(h.Host, h.Port, nil)
+		} else {
+			h.CertInfo = utils.BuildCertInfo(state, h.Host)
+			h.DANE = utils.CheckDANE(h.Host, h.Port, state.PeerCertificates)
+		}
+		result.Hosts = append(result.Hosts, h)
+	}
+	return result
+}
+
+// 汇总各途径得到的邮件服务器，同一 host:port 只连接一次，Sources 记录出处
+func collectMailHosts(obj models.DomainResult) []*models.MailHostTLS {
+	index := make(map[string]*models.MailHostTLS)
+	var hosts []*models.MailHostTLS
+	add := func(source, host string, port int, protocol, mode string) {
+		host = strings.ToLower(strings.TrimSuffix(strings.TrimSpace(host), "."))
+		// 占位符（%EMAILDOMAIN% 等）、未知协议或明文端口跳过
+		if host == "" || strings.Contains(host, "%") || port <= 0 || protocol == "" || mode == "" {
+			return
+		}
+		key := net.JoinHostPort(host, strconv.Itoa(port))
+		if h, ok := index[key]; ok {
+			for _, s := range h.Sources {
+				if s == source {
+					return
+				}
+			}
+			h.Sources = append(h.Sources, source)
+			return
+		}
+		h := &models.MailHostTLS{Host: host, Port: port, Protocol: protocol, Mode: mode, Sources: []string{source}}
+		index[key] = h
+		hosts = append(hosts, h)
+	}
+
+	// plain 不做 TLS 测试；auto 时客户端先尝试 STARTTLS
+	addSetting := func(st models.ServerSetting) {
+		mode := st.Security
+		switch mode {
+		case models.SecurityAuto:
+			mode = models.SecurityStartTLS
+		case models.SecurityPlain:
+			return
+		}
+		add(st.Source, st.Host, st.Port, st.Protocol, mode)
+	}
+	for _, st := range settingsFromSRV(obj.SRV) {
+		addSetting(st)
+	}
+	for _, r := range obj.Autodiscover {
+		for _, st := range settingsFromAutodiscover(r.Config) {
+			addSetting(st)
+		}
+	}
+	for _, r := range obj.Autoconfig {
+		for _, st := range settingsFromAutoconfig(r.Config) {
+			addSetting(st)
+		}
+	}
+	// 与 provider、families 一致，只用已确认且不是通配符命中的猜测结果
+	for _, g := range obj.GUESS {
+		if !g.Confirmed || g.ViaWildcard {
+			continue
+		}
+		if st, ok := settingFromGuess(g); ok {
+			addSetting(st)
+		}
+	}
+	// 较早的扫描结果中没有 MX 字段，此时现查优先级最高的一个
+	if obj.MX != nil {
+		for _, r := range obj.MX.Records {
+			add("mx", r.Host, 25, "smtp", "starttls")
+		}
+	} else if mx, err := utils.ResolveMXRecord(obj.Domain); err == nil {
+		add("mx", mx, 25, "smtp", "starttls")
+	}
+	return hosts
+}
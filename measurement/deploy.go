@@ -1,13 +1,13 @@
 package measurement
 
 import (
-	"bufio"
 	"encoding/json"
 	"fmt"
-	"io"
 	"log"
 	"os"
+	"scan-website/config"
 	"scan-website/models"
+	"scan-website/pipeline"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -18,16 +18,6 @@ import (
 //各机制使用情况（输入结果JSONL文件，统计各机制使用情况，这里暂不考虑GUESS）
 
 func CountDomainsWithValidConfig(inputFile string) {
-	file, err := os.Open(inputFile)
-	if err != nil {
-		log.Fatalf("Failed to open file: %v", err)
-	}
-	defer file.Close()
-
-	reader := bufio.NewReader(file)
-	sem := make(chan struct{}, 50) // 控制并发数
-	var wg sync.WaitGroup
-
 	// 统计变量
 	var (
 		domainProcessed                int64
@@ -54,7 +44,15 @@ func CountDomainsWithValidConfig(inputFile string) {
 		validThreeAll                  = make(map[string]struct{})
 		validNone                      = make(map[string]struct{})
 		validGuessDomains              = make(map[string]struct{}) //9.22
+		guessReachDomains              = make(map[string]struct{})
+		guessWildcardDomains           = make(map[string]struct{})
 		validNoneFour                  = make(map[string]struct{}) //9.22
+		// 三种机制都没有时，模拟客户端猜测能得到收件/发件服务器的域名
+		noneTBIncoming      = make(map[string]struct{})
+		noneTBOutgoing      = make(map[string]struct{})
+		noneOutlookIncoming = make(map[string]struct{})
+		noneOutlookOutgoing = make(map[string]struct{})
+		noneClientSettings  = make(map[string]int) // "thunderbird incoming imap 993 SSL" -> 域名数
 		// 定义 SRV 协议分类统计
 		srvIMAPDomains        = make(map[string]struct{})
 		srvIMAPSUDomains      = make(map[string]struct{})
@@ -66,220 +64,226 @@ func CountDomainsWithValidConfig(inputFile string) {
 
 	// 互斥锁保护共享变量
 	var mu sync.Mutex
+	providers := newProviderIndex()
 
-	for {
-		line, err := reader.ReadString('\n')
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			log.Fatalf("Error reading line: %v", err)
-		}
-
-		var obj models.DomainResult
-		if err := json.Unmarshal([]byte(line), &obj); err != nil {
-			log.Printf("❌ JSON 解析失败，跳过此行: %v", err)
-			continue
-		}
-
-		sem <- struct{}{} // 占位
-		wg.Add(1)
-		go func(obj models.DomainResult) {
-			defer wg.Done()
-			defer func() { <-sem }()
-
-			domain := obj.Domain
-			atomic.AddInt64(&domainProcessed, 1)
-
-			// Autoconfig 统计
-			for _, entry := range obj.Autoconfig {
-				if entry.Config != "" {
-					doc := etree.NewDocument()
-					if err := doc.ReadFromString(entry.Config); err == nil {
-						if doc.SelectElement("clientConfig") != nil {
-							mu.Lock()
-							validAutoconfigDomains[domain] = struct{}{}
-							switch entry.Method {
-							case "directurl":
-								autoconfigFromDirecturl[domain] = struct{}{}
-							case "ISPDB":
-								autoconfigFromISPDB[domain] = struct{}{}
-							case "MX_samedomain":
-								autoconfigFromMXSameDomain[domain] = struct{}{}
-							case "MX":
-								autoconfigFromMX[domain] = struct{}{}
-							}
-							mu.Unlock()
-						}
-					}
-				}
-			}
-
-			// Autodiscover 统计
-			for _, entry := range obj.Autodiscover {
-				if len(entry.AutodiscoverCNAME) > 0 {
-					mu.Lock()
-					autodiscover_has_cname[domain] = struct{}{}
-					mu.Unlock()
-				}
-				if entry.Config != "" && !strings.HasPrefix(entry.Config, "Bad") && !strings.HasPrefix(entry.Config, "Errorcode") && !strings.HasPrefix(entry.Config, "Non-valid") {
-					doc := etree.NewDocument()
-					if err := doc.ReadFromString(entry.Config); err == nil { //&& doc.SelectElement("Autodiscover") != nil { //可以加一个select Error==nil
+	opts := pipeline.Options{
+		Workers:  config.Get().Concurrency.Stats, // 控制并发数
+		Label:    "deploy",
+		Progress: config.Get().ProgressInterval.Std(),
+	}
+	err := pipeline.ForEach(inputFile, opts, func(obj models.DomainResult) {
+		domain := obj.Domain
+		atomic.AddInt64(&domainProcessed, 1)
+		providers.add(obj)
+
+		// Autoconfig 统计
+		for _, entry := range obj.Autoconfig {
+			if entry.Config != "" {
+				doc := etree.NewDocument()
+				if err := doc.ReadFromString(entry.Config); err == nil {
+					if doc.SelectElement("clientConfig") != nil {
 						mu.Lock()
-						validAutodiscoverDomains[domain] = struct{}{}
-						if len(entry.AutodiscoverCNAME) > 0 {
-							autodiscover_cname_and_config[domain] = struct{}{}
-						}
+						validAutoconfigDomains[domain] = struct{}{}
 						switch entry.Method {
-						case "POST":
-							autodiscoverFromPost[domain] = struct{}{}
-						case "srv-post":
-							autodiscoverFromSrvpost[domain] = struct{}{}
-						case "get-post":
-							autodiscoverFromGetpost[domain] = struct{}{}
-						case "direct_get":
-							autodiscoverFromDirectGet[domain] = struct{}{}
+						case "directurl":
+							autoconfigFromDirecturl[domain] = struct{}{}
+						case "ISPDB":
+							autoconfigFromISPDB[domain] = struct{}{}
+						case "MX_samedomain":
+							autoconfigFromMXSameDomain[domain] = struct{}{}
+						case "MX":
+							autoconfigFromMX[domain] = struct{}{}
 						}
 						mu.Unlock()
 					}
 				}
 			}
+		}
 
-			// SRV 统计
-			if len(obj.SRV.RecvRecords) > 0 || len(obj.SRV.SendRecords) > 0 {
+		// Autodiscover 统计
+		for _, entry := range obj.Autodiscover {
+			if len(entry.AutodiscoverCNAME) > 0 {
 				mu.Lock()
-				validSRVDomains[domain] = struct{}{}
+				autodiscover_has_cname[domain] = struct{}{}
 				mu.Unlock()
-
-				// 遍历 RecvRecords (IMAP/POP3)
-				for _, record := range obj.SRV.RecvRecords {
-					service := strings.ToLower(record.Service)
+			}
+			if entry.Config != "" && !strings.HasPrefix(entry.Config, "Bad") && !strings.HasPrefix(entry.Config, "Errorcode") && !strings.HasPrefix(entry.Config, "Non-valid") {
+				doc := etree.NewDocument()
+				if err := doc.ReadFromString(entry.Config); err == nil { //&& doc.SelectElement("Autodiscover") != nil { //可以加一个select Error==nil
 					mu.Lock()
-					if strings.HasPrefix(service, "_imap.") {
-						srvIMAPDomains[domain] = struct{}{}
-					}
-					if strings.HasPrefix(service, "_imaps.") {
-						srvIMAPSUDomains[domain] = struct{}{}
+					validAutodiscoverDomains[domain] = struct{}{}
+					if len(entry.AutodiscoverCNAME) > 0 {
+						autodiscover_cname_and_config[domain] = struct{}{}
 					}
-					if strings.HasPrefix(service, "_pop3.") {
-						srvPOP3Domains[domain] = struct{}{}
-					}
-					if strings.HasPrefix(service, "_pop3s.") {
-						srvPOP3SDomains[domain] = struct{}{}
+					switch entry.Method {
+					case "POST":
+						autodiscoverFromPost[domain] = struct{}{}
+					case "srv-post":
+						autodiscoverFromSrvpost[domain] = struct{}{}
+					case "get-post":
+						autodiscoverFromGetpost[domain] = struct{}{}
+					case "direct_get":
+						autodiscoverFromDirectGet[domain] = struct{}{}
 					}
 					mu.Unlock()
 				}
+			}
+		}
 
-				// 遍历 SendRecords (SMTP)
-				for _, record := range obj.SRV.SendRecords {
-					service := strings.ToLower(record.Service)
-					mu.Lock()
-					if strings.HasPrefix(service, "_submission.") {
-						srvSubmissionDomains[domain] = struct{}{}
-					}
-					if strings.HasPrefix(service, "_submissions.") {
-						srvSubmissionsDomains[domain] = struct{}{}
-					}
-					mu.Unlock()
+		// SRV 统计
+		if len(obj.SRV.RecvRecords) > 0 || len(obj.SRV.SendRecords) > 0 {
+			mu.Lock()
+			validSRVDomains[domain] = struct{}{}
+			mu.Unlock()
+
+			// 遍历 RecvRecords (IMAP/POP3)
+			for _, record := range obj.SRV.RecvRecords {
+				service := strings.ToLower(record.Service)
+				mu.Lock()
+				if strings.HasPrefix(service, "_imap.") {
+					srvIMAPDomains[domain] = struct{}{}
+				}
+				if strings.HasPrefix(service, "_imaps.") {
+					srvIMAPSUDomains[domain] = struct{}{}
+				}
+				if strings.HasPrefix(service, "_pop3.") {
+					srvPOP3Domains[domain] = struct{}{}
+				}
+				if strings.HasPrefix(service, "_pop3s.") {
+					srvPOP3SDomains[domain] = struct{}{}
+				}
+				mu.Unlock()
+			}
+
+			// 遍历 SendRecords (SMTP)
+			for _, record := range obj.SRV.SendRecords {
+				service := strings.ToLower(record.Service)
+				mu.Lock()
+				if strings.HasPrefix(service, "_submission.") {
+					srvSubmissionDomains[domain] = struct{}{}
+				}
+				if strings.HasPrefix(service, "_submissions.") {
+					srvSubmissionsDomains[domain] = struct{}{}
 				}
+				mu.Unlock()
+			}
 
-				// 检查 DNSSEC
-				if obj.SRV.DNSRecord != nil {
-					dnssecPassed := true
-					dnsRecord := obj.SRV.DNSRecord
+			// 检查 DNSSEC
+			if obj.SRV.DNSRecord != nil {
+				dnssecPassed := true
+				dnsRecord := obj.SRV.DNSRecord
 
-					// 只检查存在的 ADbit_ 字段是否全部为 true
-					existingFields := []*bool{
-						dnsRecord.ADbit_imap, dnsRecord.ADbit_imaps,
-						dnsRecord.ADbit_pop3, dnsRecord.ADbit_pop3s,
-						dnsRecord.ADbit_smtp, dnsRecord.ADbit_smtps,
-					}
+				// 只检查存在的 ADbit_ 字段是否全部为 true
+				existingFields := []*bool{
+					dnsRecord.ADbit_imap, dnsRecord.ADbit_imaps,
+					dnsRecord.ADbit_pop3, dnsRecord.ADbit_pop3s,
+					dnsRecord.ADbit_smtp, dnsRecord.ADbit_smtps,
+				}
 
-					hasCheckedFields := false
-					for _, field := range existingFields {
-						if field != nil { // 只检查存在的字段
-							hasCheckedFields = true
-							if !*field { // 只要有一个 false，就不通过
-								dnssecPassed = false
-								break
-							}
+				hasCheckedFields := false
+				for _, field := range existingFields {
+					if field != nil { // 只检查存在的字段
+						hasCheckedFields = true
+						if !*field { // 只要有一个 false，就不通过
+							dnssecPassed = false
+							break
 						}
 					}
-					// 如果 DNSSEC 检查通过，添加到 srvDNSSECPassed
-					if dnssecPassed && hasCheckedFields {
-						mu.Lock()
-						srvDNSSECPassed[domain] = struct{}{}
-						mu.Unlock()
-					}
 				}
-			}
-
-			//GUESS统计
-			for _, entry := range obj.GUESS {
-				if len(entry) != 0 {
+				// 如果 DNSSEC 检查通过，添加到 srvDNSSECPassed
+				if dnssecPassed && hasCheckedFields {
 					mu.Lock()
-					validGuessDomains[domain] = struct{}{}
+					srvDNSSECPassed[domain] = struct{}{}
 					mu.Unlock()
 				}
 			}
+		}
 
-			// 分类统计
+		//GUESS统计：确认了协议且不是通配符命中的才算可用，只能连上的单独统计（旧版结果只有连通信息）
+		for _, entry := range obj.GUESS {
 			mu.Lock()
-			_, hasAutoconfig := validAutoconfigDomains[domain]
-			_, hasAutodiscover := validAutodiscoverDomains[domain]
-			_, hasSRV := validSRVDomains[domain]
-			_, hasGUESS := validGuessDomains[domain]
-
-			// switch {
-			// case hasAutoconfig && hasAutodiscover && hasSRV:
-			// 	validThreeAll[domain] = struct{}{}
-			// case hasAutoconfig && hasAutodiscover:
-			// 	validAutodiscoverAndAutoconfig[domain] = struct{}{}
-			// case hasAutoconfig && hasSRV:
-			// 	validAutoconfigAndSRV[domain] = struct{}{}
-			// case hasAutodiscover && hasSRV:
-			// 	validAutodiscoverAndSRV[domain] = struct{}{}
-			// case hasAutoconfig:
-			// 	validOnlyAutoconfig[domain] = struct{}{}
-			// case hasAutodiscover:
-			// 	validOnlyAutodiscover[domain] = struct{}{}
-			// case hasSRV:
-			// 	validOnlySRV[domain] = struct{}{}
-			// default:
-			// 	validNone[domain] = struct{}{}
-			// }
-			if hasAutoconfig && hasAutodiscover && hasSRV {
-				validThreeAll[domain] = struct{}{}
-			}
-			if hasAutoconfig && hasAutodiscover {
-				validAutodiscoverAndAutoconfig[domain] = struct{}{}
-			}
-			if hasAutoconfig && hasSRV {
-				validAutoconfigAndSRV[domain] = struct{}{}
-			}
-			if hasAutodiscover && hasSRV {
-				validAutodiscoverAndSRV[domain] = struct{}{}
-			}
-			if hasAutoconfig && !hasAutodiscover && !hasSRV {
-				validOnlyAutoconfig[domain] = struct{}{}
-			}
-			if hasAutodiscover && !hasAutoconfig && !hasSRV {
-				validOnlyAutodiscover[domain] = struct{}{}
+			if entry.Reach {
+				guessReachDomains[domain] = struct{}{}
 			}
-			if hasSRV && !hasAutoconfig && !hasAutodiscover {
-				validOnlySRV[domain] = struct{}{}
-			}
-			if !hasAutoconfig && !hasAutodiscover && !hasSRV {
-				validNone[domain] = struct{}{}
-			}
-			if !hasGUESS && !hasAutoconfig && !hasAutodiscover && !hasSRV {
-				validNoneFour[domain] = struct{}{}
+			if entry.ViaWildcard {
+				guessWildcardDomains[domain] = struct{}{}
+			} else if entry.Confirmed {
+				validGuessDomains[domain] = struct{}{}
 			}
 			mu.Unlock()
-		}(obj)
-	}
+		}
 
-	wg.Wait()
+		// 分类统计
+		mu.Lock()
+		_, hasAutoconfig := validAutoconfigDomains[domain]
+		_, hasAutodiscover := validAutodiscoverDomains[domain]
+		_, hasSRV := validSRVDomains[domain]
+		_, hasGUESS := validGuessDomains[domain]
+
+		// switch {
+		// case hasAutoconfig && hasAutodiscover && hasSRV:
+		// 	validThreeAll[domain] = struct{}{}
+		// case hasAutoconfig && hasAutodiscover:
+		// 	validAutodiscoverAndAutoconfig[domain] = struct{}{}
+		// case hasAutoconfig && hasSRV:
+		// 	validAutoconfigAndSRV[domain] = struct{}{}
+		// case hasAutodiscover && hasSRV:
+		// 	validAutodiscoverAndSRV[domain] = struct{}{}
+		// case hasAutoconfig:
+		// 	validOnlyAutoconfig[domain] = struct{}{}
+		// case hasAutodiscover:
+		// 	validOnlyAutodiscover[domain] = struct{}{}
+		// case hasSRV:
+		// 	validOnlySRV[domain] = struct{}{}
+		// default:
+		// 	validNone[domain] = struct{}{}
+		// }
+		if hasAutoconfig && hasAutodiscover && hasSRV {
+			validThreeAll[domain] = struct{}{}
+		}
+		if hasAutoconfig && hasAutodiscover {
+			validAutodiscoverAndAutoconfig[domain] = struct{}{}
+		}
+		if hasAutoconfig && hasSRV {
+			validAutoconfigAndSRV[domain] = struct{}{}
+		}
+		if hasAutodiscover && hasSRV {
+			validAutodiscoverAndSRV[domain] = struct{}{}
+		}
+		if hasAutoconfig && !hasAutodiscover && !hasSRV {
+			validOnlyAutoconfig[domain] = struct{}{}
+		}
+		if hasAutodiscover && !hasAutoconfig && !hasSRV {
+			validOnlyAutodiscover[domain] = struct{}{}
+		}
+		if hasSRV && !hasAutoconfig && !hasAutodiscover {
+			validOnlySRV[domain] = struct{}{}
+		}
+		if !hasAutoconfig && !hasAutodiscover && !hasSRV {
+			validNone[domain] = struct{}{}
+			for _, cg := range obj.ClientGuess {
+				incoming, outgoing := noneTBIncoming, noneTBOutgoing
+				if cg.Client == "outlook" {
+					incoming, outgoing = noneOutlookIncoming, noneOutlookOutgoing
+				}
+				if cg.Incoming != nil {
+					incoming[domain] = struct{}{}
+					noneClientSettings[fmt.Sprintf("%s incoming %s %d %s", cg.Client, cg.Incoming.Protocol, cg.Incoming.Port, cg.Incoming.SocketType)]++
+				}
+				if cg.Outgoing != nil {
+					outgoing[domain] = struct{}{}
+					noneClientSettings[fmt.Sprintf("%s outgoing %s %d %s", cg.Client, cg.Outgoing.Protocol, cg.Outgoing.Port, cg.Outgoing.SocketType)]++
+				}
+			}
+		}
+		if !hasGUESS && !hasAutoconfig && !hasAutodiscover && !hasSRV {
+			validNoneFour[domain] = struct{}{}
+		}
+		mu.Unlock()
+	})
+	if err != nil {
+		log.Fatalf("Error reading %s: %v", inputFile, err)
+	}
 
 	// 输出统计结果
 	fmt.Printf("✅ 通过 Autodiscover 可以获取配置信息的域名数量: %d\n", len(validAutodiscoverDomains))
@@ -309,6 +313,13 @@ func CountDomainsWithValidConfig(inputFile string) {
 	fmt.Printf("✅ 无法通过前三种任意方法获取配置信息的域名数量: %d\n", len(validNone))
 	fmt.Printf("✅ 无法通过四种任意方法获取配置信息的域名数量: %d\n", len(validNoneFour))
 	fmt.Printf("✅ 可以通过GUESS获取配置信息的域名数量: %d\n", len(validGuessDomains))
+	fmt.Printf("✅ GUESS能连上（含未确认协议）的域名数量: %d\n", len(guessReachDomains))
+	fmt.Printf("✅ GUESS有通配符命中结果（不计入可用）的域名数量: %d\n", len(guessWildcardDomains))
+	fmt.Printf("📌 无配置机制时 Thunderbird 猜到收件/发件服务器的域名数量: %d / %d\n", len(noneTBIncoming), len(noneTBOutgoing))
+	fmt.Printf("📌 无配置机制时 Outlook 猜到收件/发件服务器的域名数量: %d / %d\n", len(noneOutlookIncoming), len(noneOutlookOutgoing))
+	for setting, count := range noneClientSettings {
+		fmt.Printf("   %s: %d\n", setting, count)
+	}
 	fmt.Printf("📌 SRV(IMAP) 域名数量: %d\n", len(srvIMAPDomains))
 	fmt.Printf("📌 SRV(IMAPS) 域名数量: %d\n", len(srvIMAPSUDomains))
 	fmt.Printf("📌 SRV(POP3) 域名数量: %d\n", len(srvPOP3Domains))
@@ -348,11 +359,19 @@ func CountDomainsWithValidConfig(inputFile string) {
 		"valid_none":                        mapToSlice(validNone),
 		"valid_none_four":                   mapToSlice(validNoneFour),
 		"valid_guess":                       mapToSlice(validGuessDomains),
+		"guess_reach":                       mapToSlice(guessReachDomains),
+		"guess_via_wildcard":                mapToSlice(guessWildcardDomains),
+		"valid_none_thunderbird_incoming":   mapToSlice(noneTBIncoming),
+		"valid_none_thunderbird_outgoing":   mapToSlice(noneTBOutgoing),
+		"valid_none_outlook_incoming":       mapToSlice(noneOutlookIncoming),
+		"valid_none_outlook_outgoing":       mapToSlice(noneOutlookOutgoing),
+		"valid_none_client_settings":        noneClientSettings,
 	}
 
 	if err := saveToJSON("domain_stats.json", dataToSave); err != nil {
 		log.Fatalf("Error saving domain_stats: %v", err)
 	}
+	providers.save("domain_stats.json", dataToSave)
 }
 
 func mapToSlice(m map[string]struct{}) []string {
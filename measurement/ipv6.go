@@ -0,0 +1,155 @@
+package measurement
+
+import (
+	"fmt"
+	"log"
+	"scan-website/config"
+	"scan-website/models"
+	"scan-website/pipeline"
+	"sync"
+	"sync/atomic"
+)
+
+// 邮件配置的 IPv6 就绪情况（输入结果JSONL文件，依赖 ResolveTargets 写入的 targets 字段）
+
+func CountIPv6Readiness(inputFile string) {
+	sources := []string{"autodiscover", "autoconfig", "srv", "guess"}
+	// 统计变量
+	var (
+		domainProcessed  int64
+		withTargets      = make(map[string]struct{})
+		anyAAAA          = make(map[string]struct{}) // 至少一个目标有 AAAA
+		allAAAA          = make(map[string]struct{}) // 所有目标都有 AAAA
+		ipv4Only         = make(map[string]struct{}) // 没有任何目标有 AAAA
+		ipv6Only         = make(map[string]struct{}) // 有目标只有 AAAA
+		probed           = make(map[string]struct{}) // 做过分地址族连通性测试
+		anyIPv6Reachable = make(map[string]struct{})
+		allIPv6Ready     = make(map[string]struct{}) // 所有目标都有 AAAA 且 IPv6 能连上
+		ipv6Broken       = make(map[string]struct{}) // 有 AAAA 但 IPv6 连不上而 IPv4 能连上
+		sourceTargets    = make(map[string]map[string]struct{})
+		sourceAAAA       = make(map[string]map[string]struct{})
+	)
+	for _, s := range sources {
+		sourceTargets[s] = make(map[string]struct{})
+		sourceAAAA[s] = make(map[string]struct{})
+	}
+
+	var mu sync.Mutex
+	providers := newProviderIndex()
+
+	opts := pipeline.Options{
+		Workers:  config.Get().Concurrency.Stats, // 控制并发数
+		Label:    "ipv6",
+		Progress: config.Get().ProgressInterval.Std(),
+	}
+	err := pipeline.ForEach(inputFile, opts, func(obj models.DomainResult) {
+		domain := obj.Domain
+		atomic.AddInt64(&domainProcessed, 1)
+		providers.add(obj)
+		if len(obj.Targets) == 0 {
+			return
+		}
+
+		hasAny, hasAll, hasV6Only := false, true, false
+		wasProbed, v6Any, v6All, broken := false, false, true, false
+		for _, t := range obj.Targets {
+			if len(t.AAAA) > 0 {
+				hasAny = true
+				if len(t.A) == 0 {
+					hasV6Only = true
+				}
+			} else {
+				hasAll = false
+			}
+			if t.IPv4 != nil || t.IPv6 != nil {
+				wasProbed = true
+			}
+			if t.IPv6 != nil && t.IPv6.Reachable {
+				v6Any = true
+			} else {
+				v6All = false
+				if t.IPv6 != nil && t.IPv4 != nil && t.IPv4.Reachable {
+					broken = true
+				}
+			}
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		withTargets[domain] = struct{}{}
+		if hasAny {
+			anyAAAA[domain] = struct{}{}
+		} else {
+			ipv4Only[domain] = struct{}{}
+		}
+		if hasAll {
+			allAAAA[domain] = struct{}{}
+		}
+		if hasV6Only {
+			ipv6Only[domain] = struct{}{}
+		}
+		if wasProbed {
+			probed[domain] = struct{}{}
+			if v6Any {
+				anyIPv6Reachable[domain] = struct{}{}
+			}
+			if v6All {
+				allIPv6Ready[domain] = struct{}{}
+			}
+			if broken {
+				ipv6Broken[domain] = struct{}{}
+			}
+		}
+		for _, t := range obj.Targets {
+			for _, s := range t.Sources {
+				if _, ok := sourceTargets[s]; !ok {
+					continue
+				}
+				sourceTargets[s][domain] = struct{}{}
+				if len(t.AAAA) > 0 {
+					sourceAAAA[s][domain] = struct{}{}
+				}
+			}
+		}
+	})
+	if err != nil {
+		log.Fatalf("Error reading %s: %v", inputFile, err)
+	}
+
+	// 输出统计结果
+	fmt.Printf("✅ 有可解析配置目标的域名数量: %d\n", len(withTargets))
+	fmt.Printf("✅ 至少一个目标有 AAAA 的域名数量: %d\n", len(anyAAAA))
+	fmt.Printf("✅ 所有目标都有 AAAA 的域名数量: %d\n", len(allAAAA))
+	fmt.Printf("✅ 只有 IPv4 的域名数量: %d\n", len(ipv4Only))
+	fmt.Printf("✅ 有只支持 IPv6 的目标的域名数量: %d\n", len(ipv6Only))
+	fmt.Printf("✅ 做过分地址族连通性测试的域名数量: %d\n", len(probed))
+	fmt.Printf("✅ 至少一个目标 IPv6 可连的域名数量: %d\n", len(anyIPv6Reachable))
+	fmt.Printf("✅ 所有目标 IPv6 可连（IPv6 就绪）的域名数量: %d\n", len(allIPv6Ready))
+	fmt.Printf("✅ 有 AAAA 但 IPv6 不通、IPv4 可连的域名数量: %d\n", len(ipv6Broken))
+	for _, s := range sources {
+		fmt.Printf("📌 %s 目标有 AAAA 的域名数量: %d / %d\n", s, len(sourceAAAA[s]), len(sourceTargets[s]))
+	}
+	fmt.Printf("✅ 一共处理了域名数量: %d\n", domainProcessed)
+
+	dataToSave := map[string]interface{}{
+		"with_targets":       mapToSlice(withTargets),
+		"any_aaaa":           mapToSlice(anyAAAA),
+		"all_aaaa":           mapToSlice(allAAAA),
+		"ipv4_only":          mapToSlice(ipv4Only),
+		"ipv6_only_target":   mapToSlice(ipv6Only),
+		"family_probed":      mapToSlice(probed),
+		"any_ipv6_reachable": mapToSlice(anyIPv6Reachable),
+		"all_ipv6_ready":     mapToSlice(allIPv6Ready),
+		"ipv6_broken":        mapToSlice(ipv6Broken),
+	}
+	for _, s := range sources {
+		dataToSave[s+"_targets"] = mapToSlice(sourceTargets[s])
+		dataToSave[s+"_aaaa"] = mapToSlice(sourceAAAA[s])
+	}
+
+	statsFile := config.Get().Paths.IPv6Stats
+	if err := saveToJSON(statsFile, dataToSave); err != nil {
+		log.Fatalf("Error saving ipv6_stats: %v", err)
+	}
+	providers.save(statsFile, dataToSave)
+}
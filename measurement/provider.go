@@ -0,0 +1,74 @@
+package measurement
+
+import (
+	"log"
+	"scan-website/models"
+	"scan-website/provider"
+	"strings"
+	"sync"
+)
+
+// 统计结果按邮件服务商拆分：各统计函数处理每个域名时调用 add，结束后把域名集合换算成每个服务商的计数
+
+const unknownProvider = "unknown"
+
+type providerIndex struct {
+	mu        sync.Mutex
+	providers map[string]string // 域名 -> 服务商
+}
+
+func newProviderIndex() *providerIndex {
+	return &providerIndex{providers: make(map[string]string)}
+}
+
+// 较早的扫描结果中没有 provider 字段，此时用规则现场识别（没有 SPF 信号）
+func (p *providerIndex) add(obj models.DomainResult) {
+	name := ""
+	if obj.Provider != nil {
+		name = obj.Provider.Name
+	} else {
+		name = provider.DefaultRules().Identify(&obj, nil).Name
+	}
+	if name == "" {
+		name = unknownProvider
+	}
+	p.mu.Lock()
+	p.providers[obj.Domain] = name
+	p.mu.Unlock()
+}
+
+// breakdown 把 统计项 -> 域名列表 换算为 统计项 -> 服务商 -> 域名数，另附 "_total" 为各服务商的域名总数
+func (p *providerIndex) breakdown(stats map[string]interface{}) map[string]map[string]int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	result := make(map[string]map[string]int)
+	totals := make(map[string]int)
+	for _, name := range p.providers {
+		totals[name]++
+	}
+	result["_total"] = totals
+	for key, value := range stats {
+		domains, ok := value.([]string)
+		if !ok {
+			continue
+		}
+		counts := make(map[string]int)
+		for _, domain := range domains {
+			name, ok := p.providers[domain]
+			if !ok {
+				name = unknownProvider
+			}
+			counts[name]++
+		}
+		result[key] = counts
+	}
+	return result
+}
+
+// save 写入 <原文件名>_by_provider.json
+func (p *providerIndex) save(statsFile string, stats map[string]interface{}) {
+	fileName := strings.TrimSuffix(statsFile, ".json") + "_by_provider.json"
+	if err := saveToJSON(fileName, p.breakdown(stats)); err != nil {
+		log.Printf("Error saving %s: %v", fileName, err)
+	}
+}
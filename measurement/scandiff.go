@@ -0,0 +1,381 @@
+package measurement
+
+import (
+	"fmt"
+	"log"
+	"scan-website/config"
+	"scan-website/models"
+	"scan-website/pipeline"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// 两次扫描之间的纵向比较：按域名匹配新旧快照（init.jsonl 或 check_dif_results.jsonl），
+// 记录各机制的出现/消失、服务器/端口/TLS 方式的变化以及证书和证书有效性的变化
+
+var diffMechanisms = []string{"autodiscover", "autoconfig", "srv", "guess"}
+
+// DomainDiff 是变化日志中的一行
+type DomainDiff struct {
+	Domain  string       `json:"domain"`
+	Status  string       `json:"status"` // added / removed / changed
+	Changes []ScanChange `json:"changes,omitempty"`
+}
+
+type ScanChange struct {
+	Mechanism string   `json:"mechanism"`
+	Protocol  string   `json:"protocol,omitempty"`
+	Field     string   `json:"field"`  // mechanism / protocol / server / port / security / cert / cert_trusted / cert_hostname_match / cert_expired
+	Change    string   `json:"change"` // appeared / disappeared / changed
+	Old       []string `json:"old,omitempty"`
+	New       []string `json:"new,omitempty"`
+}
+
+// ScanDiffStats 是整体的变动统计，机制的出现/消失和各字段的变化只统计两次都有的域名
+type ScanDiffStats struct {
+	OldFile              string         `json:"old_file"`
+	NewFile              string         `json:"new_file"`
+	OldDomains           int            `json:"old_domains"`
+	NewDomains           int            `json:"new_domains"`
+	DomainsAdded         int            `json:"domains_added"`
+	DomainsRemoved       int            `json:"domains_removed"`
+	Common               int            `json:"common"`
+	Changed              int            `json:"changed"`
+	ChurnRate            float64        `json:"churn_rate"` // Changed / Common
+	MechanismsOld        map[string]int `json:"mechanisms_old"`
+	MechanismsNew        map[string]int `json:"mechanisms_new"`
+	MechanismAppeared    map[string]int `json:"mechanism_appeared"`
+	MechanismDisappeared map[string]int `json:"mechanism_disappeared"`
+	FieldChanges         map[string]int `json:"field_changes"` // "机制.字段" -> 发生变化的域名数
+}
+
+// 一次扫描中某个域名的摘要，只保留比较需要的内容
+type scanSummary map[string]*mechanismSummary
+
+type mechanismSummary struct {
+	protocols map[string]*protocolSummary
+	certs     map[string]struct{} // leaf 指纹
+	certCount int
+	trusted   int
+	hostMatch int
+	expired   int
+}
+
+type protocolSummary struct {
+	servers  map[string]struct{}
+	ports    map[string]struct{}
+	security map[string]struct{}
+}
+
+func (s scanSummary) add(mechanism string, settings []models.ServerSetting, ci *models.CertInfo) {
+	m := s[mechanism]
+	if m == nil {
+		m = &mechanismSummary{protocols: make(map[string]*protocolSummary), certs: make(map[string]struct{})}
+		s[mechanism] = m
+	}
+	for _, st := range settings {
+		p := m.protocols[st.Protocol]
+		if p == nil {
+			p = &protocolSummary{servers: make(map[string]struct{}), ports: make(map[string]struct{}), security: make(map[string]struct{})}
+			m.protocols[st.Protocol] = p
+		}
+		p.servers[st.Host] = struct{}{}
+		p.ports[strconv.Itoa(st.Port)] = struct{}{}
+		p.security[st.Security] = struct{}{}
+	}
+	if ci != nil {
+		m.certCount++
+		if ci.Fingerprint != "" {
+			m.certs[ci.Fingerprint] = struct{}{}
+		}
+		if ci.IsTrusted {
+			m.trusted++
+		}
+		if ci.IsHostnameMatch {
+			m.hostMatch++
+		}
+		if ci.IsExpired {
+			m.expired++
+		}
+	}
+}
+
+func hasXMLConfig(cfg string) bool {
+	return strings.HasPrefix(strings.TrimSpace(cfg), "<")
+}
+
+func summarizeDomainResult(r *models.DomainResult) scanSummary {
+	s := make(scanSummary)
+	for _, a := range r.Autodiscover {
+		if hasXMLConfig(a.Config) {
+			s.add("autodiscover", settingsFromAutodiscover(a.Config), a.CertInfo)
+		}
+	}
+	for _, a := range r.Autoconfig {
+		if hasXMLConfig(a.Config) {
+			s.add("autoconfig", settingsFromAutoconfig(a.Config), a.CertInfo)
+		}
+	}
+	if len(r.SRV.RecvRecords) > 0 || len(r.SRV.SendRecords) > 0 {
+		s.add("srv", settingsFromSRV(r.SRV), nil)
+	}
+	for _, g := range r.GUESS {
+		if !g.Confirmed || g.ViaWildcard {
+			continue
+		}
+		if st, ok := settingFromGuess(g); ok {
+			s.add("guess", []models.ServerSetting{st}, nil)
+		}
+	}
+	return s
+}
+
+func summarizeCheckDif(r *DomainCheckDifResult) scanSummary {
+	s := make(scanSummary)
+	for _, p := range r.AutodiscoverPortUsage {
+		s.add("autodiscover", usageSettings(p.PortsUsage), p.CertInfo)
+	}
+	for _, p := range r.AutoconfigPortUsage {
+		s.add("autoconfig", usageSettings(p.PortsUsage), p.CertInfo)
+	}
+	if r.SRVPortUsage != nil {
+		s.add("srv", usageSettings(r.SRVPortUsage.PortsUsage), nil)
+	}
+	return s
+}
+
+func usageSettings(usage []PortUsageDetail) []models.ServerSetting {
+	settings := make([]models.ServerSetting, len(usage))
+	for i, u := range usage {
+		settings[i] = u.ServerSetting
+	}
+	return settings
+}
+
+// 证书有效性按机制汇总：全部满足为 yes，全不满足为 no，否则 mixed
+func certState(n, total int) string {
+	switch {
+	case total == 0:
+		return ""
+	case n == total:
+		return "yes"
+	case n == 0:
+		return "no"
+	}
+	return "mixed"
+}
+
+func sortedSet(m map[string]struct{}) []string {
+	s := mapToSlice(m)
+	sort.Strings(s)
+	return s
+}
+
+func sameSet(a, b map[string]struct{}) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k := range a {
+		if _, ok := b[k]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// 比较同一域名的两份摘要，before 或 after 为空时所有机制记为出现/消失
+func diffSummaries(before, after scanSummary) []ScanChange {
+	var changes []ScanChange
+	for _, mech := range diffMechanisms {
+		o, n := before[mech], after[mech]
+		switch {
+		case o == nil && n == nil:
+			continue
+		case o == nil:
+			changes = append(changes, ScanChange{Mechanism: mech, Field: "mechanism", Change: "appeared"})
+			continue
+		case n == nil:
+			changes = append(changes, ScanChange{Mechanism: mech, Field: "mechanism", Change: "disappeared"})
+			continue
+		}
+
+		names := make(map[string]struct{})
+		for p := range o.protocols {
+			names[p] = struct{}{}
+		}
+		for p := range n.protocols {
+			names[p] = struct{}{}
+		}
+		for _, name := range sortedSet(names) {
+			op, np := o.protocols[name], n.protocols[name]
+			switch {
+			case op == nil:
+				changes = append(changes, ScanChange{Mechanism: mech, Protocol: name, Field: "protocol", Change: "appeared", New: sortedSet(np.servers)})
+				continue
+			case np == nil:
+				changes = append(changes, ScanChange{Mechanism: mech, Protocol: name, Field: "protocol", Change: "disappeared", Old: sortedSet(op.servers)})
+				continue
+			}
+			for _, f := range []struct {
+				field    string
+				old, new map[string]struct{}
+			}{{"server", op.servers, np.servers}, {"port", op.ports, np.ports}, {"security", op.security, np.security}} {
+				if !sameSet(f.old, f.new) {
+					changes = append(changes, ScanChange{Mechanism: mech, Protocol: name, Field: f.field, Change: "changed", Old: sortedSet(f.old), New: sortedSet(f.new)})
+				}
+			}
+		}
+
+		// 任一侧没有指纹（旧结果或 SRV/guess）时不比较证书
+		if len(o.certs) > 0 && len(n.certs) > 0 && !sameSet(o.certs, n.certs) {
+			changes = append(changes, ScanChange{Mechanism: mech, Field: "cert", Change: "changed", Old: sortedSet(o.certs), New: sortedSet(n.certs)})
+		}
+		for _, f := range []struct {
+			field    string
+			old, new string
+		}{
+			{"cert_trusted", certState(o.trusted, o.certCount), certState(n.trusted, n.certCount)},
+			{"cert_hostname_match", certState(o.hostMatch, o.certCount), certState(n.hostMatch, n.certCount)},
+			{"cert_expired", certState(o.expired, o.certCount), certState(n.expired, n.certCount)},
+		} {
+			if f.old != "" && f.new != "" && f.old != f.new {
+				changes = append(changes, ScanChange{Mechanism: mech, Field: f.field, Change: "changed", Old: []string{f.old}, New: []string{f.new}})
+			}
+		}
+	}
+	return changes
+}
+
+// 按 kind 读取快照，对每个域名的摘要调用 fn
+func forEachSnapshot(input, kind string, opts pipeline.Options, fn func(domain string, s scanSummary)) error {
+	switch kind {
+	case "init":
+		return pipeline.ForEach(input, opts, func(r models.DomainResult) { fn(r.Domain, summarizeDomainResult(&r)) })
+	case "check_dif":
+		return pipeline.ForEach(input, opts, func(r DomainCheckDifResult) { fn(r.Domain, summarizeCheckDif(&r)) })
+	}
+	return fmt.Errorf("unknown snapshot kind %q, want init or check_dif", kind)
+}
+
+func mapSnapshot(input, kind string, w *pipeline.Writer, opts pipeline.Options, fn func(domain string, s scanSummary) (*DomainDiff, bool)) error {
+	switch kind {
+	case "init":
+		return pipeline.Map(input, w, opts, func(r models.DomainResult) (*DomainDiff, bool) { return fn(r.Domain, summarizeDomainResult(&r)) })
+	case "check_dif":
+		return pipeline.Map(input, w, opts, func(r DomainCheckDifResult) (*DomainDiff, bool) { return fn(r.Domain, summarizeCheckDif(&r)) })
+	}
+	return fmt.Errorf("unknown snapshot kind %q, want init or check_dif", kind)
+}
+
+// DiffScans 比较两次扫描的结果（kind 为 init 或 check_dif），把有变化的域名写入 outputFile，
+// 整体统计写入 statsFile。旧快照只以摘要形式留在内存中，新快照流式处理
+func DiffScans(oldFile, newFile, kind, outputFile, statsFile string) (*ScanDiffStats, error) {
+	cfg := config.Get()
+	opts := pipeline.Options{
+		Workers:  cfg.Concurrency.Check,
+		Progress: cfg.ProgressInterval.Std(),
+	}
+
+	stats := &ScanDiffStats{
+		OldFile:              oldFile,
+		NewFile:              newFile,
+		MechanismsOld:        make(map[string]int),
+		MechanismsNew:        make(map[string]int),
+		MechanismAppeared:    make(map[string]int),
+		MechanismDisappeared: make(map[string]int),
+		FieldChanges:         make(map[string]int),
+	}
+	var mu sync.Mutex
+
+	old := make(map[string]scanSummary)
+	opts.Label = "diff_old"
+	err := forEachSnapshot(oldFile, kind, opts, func(domain string, s scanSummary) {
+		mu.Lock()
+		defer mu.Unlock()
+		old[domain] = s
+		for mech := range s {
+			stats.MechanismsOld[mech]++
+		}
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read old snapshot: %v", err)
+	}
+	stats.OldDomains = len(old)
+
+	writer, err := pipeline.NewWriter(outputFile, false)
+	if err != nil {
+		return nil, err
+	}
+	defer writer.Close()
+
+	seen := make(map[string]bool)
+	opts.Label = "diff_new"
+	opts.Ordered = true
+	err = mapSnapshot(newFile, kind, writer, opts, func(domain string, s scanSummary) (*DomainDiff, bool) {
+		mu.Lock()
+		defer mu.Unlock()
+		seen[domain] = true
+		stats.NewDomains++
+		for mech := range s {
+			stats.MechanismsNew[mech]++
+		}
+
+		prev, ok := old[domain]
+		if !ok {
+			stats.DomainsAdded++
+			return &DomainDiff{Domain: domain, Status: "added", Changes: diffSummaries(nil, s)}, true
+		}
+		stats.Common++
+		changes := diffSummaries(prev, s)
+		if len(changes) == 0 {
+			return nil, false
+		}
+		stats.Changed++
+		counted := make(map[string]bool)
+		for _, c := range changes {
+			key := c.Mechanism + "." + c.Field
+			switch {
+			case c.Field == "mechanism" && c.Change == "appeared":
+				stats.MechanismAppeared[c.Mechanism]++
+			case c.Field == "mechanism":
+				stats.MechanismDisappeared[c.Mechanism]++
+			case !counted[key]:
+				counted[key] = true
+				stats.FieldChanges[key]++
+			}
+		}
+		return &DomainDiff{Domain: domain, Status: "changed", Changes: changes}, true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to diff new snapshot: %v", err)
+	}
+
+	// 新快照中没有的域名按名称顺序写在最后
+	var removed []string
+	for domain := range old {
+		if !seen[domain] {
+			removed = append(removed, domain)
+		}
+	}
+	sort.Strings(removed)
+	for _, domain := range removed {
+		if err := writer.Write(&DomainDiff{Domain: domain, Status: "removed", Changes: diffSummaries(old[domain], nil)}); err != nil {
+			return nil, err
+		}
+	}
+	stats.DomainsRemoved = len(removed)
+	if stats.Common > 0 {
+		stats.ChurnRate = float64(stats.Changed) / float64(stats.Common)
+	}
+
+	fmt.Printf("📊 %d old / %d new domains: %d added, %d removed, %d of %d common changed (%.2f%%)\n",
+		stats.OldDomains, stats.NewDomains, stats.DomainsAdded, stats.DomainsRemoved, stats.Changed, stats.Common, stats.ChurnRate*100)
+	if statsFile != "" {
+		if err := saveToJSON(statsFile, stats); err != nil {
+			log.Printf("❌ Failed to save diff stats: %v", err)
+		}
+	}
+	return stats, nil
+}
@@ -0,0 +1,212 @@
+package measurement
+
+import (
+	"fmt"
+	"scan-website/models"
+	"scan-website/utils"
+	"strconv"
+	"strings"
+
+	"github.com/beevik/etree"
+)
+
+// 各机制的配置统一解析成 models.ServerSetting，只保留 IMAP/POP3/SMTP 三种邮件协议
+
+func normalizeHost(host string) string {
+	return strings.ToLower(strings.TrimSuffix(strings.TrimSpace(host), "."))
+}
+
+func newSetting(source, protocol, host string, port int, security string) models.ServerSetting {
+	return models.ServerSetting{
+		Protocol: protocol,
+		Role:     models.RoleOf(protocol),
+		Host:     normalizeHost(host),
+		Port:     port,
+		Security: security,
+		Source:   source,
+	}
+}
+
+func mailProtocol(name string) string {
+	switch p := strings.ToLower(strings.TrimSpace(name)); p {
+	case "imap", "pop3", "smtp":
+		return p
+	case "pop":
+		return "pop3"
+	}
+	return ""
+}
+
+func elemText(parent *etree.Element, tag string) string {
+	if e := parent.SelectElement(tag); e != nil {
+		return strings.TrimSpace(e.Text())
+	}
+	return ""
+}
+
+// settingsFromAutodiscover 解析 Autodiscover 响应中的 <Protocol>，不是 email/settings 的响应返回空
+func settingsFromAutodiscover(config string) []models.ServerSetting {
+	doc := etree.NewDocument()
+	if err := doc.ReadFromString(config); err != nil {
+		return nil
+	}
+	account := doc.FindElement("/Autodiscover/Response/Account")
+	if account == nil || elemText(account, "AccountType") != "email" || elemText(account, "Action") != "settings" {
+		return nil
+	}
+	var settings []models.ServerSetting
+	for _, p := range account.SelectElements("Protocol") {
+		typ := elemText(p, "Type")
+		if attr := p.SelectAttr("Type"); typ == "" && attr != nil {
+			typ = attr.Value
+		}
+		protocol := mailProtocol(typ)
+		if protocol == "" {
+			continue
+		}
+		port, _ := strconv.Atoi(elemText(p, "Port"))
+		s := newSetting("autodiscover", protocol, elemText(p, "Server"), port, autodiscoverSecurity(elemText(p, "Encryption"), elemText(p, "SSL"), port))
+		// SPA 缺省为 on；AuthRequired=off 时不需要认证
+		if !strings.EqualFold(elemText(p, "AuthRequired"), "off") {
+			if strings.EqualFold(elemText(p, "SPA"), "off") {
+				s.Auth = []string{"password-cleartext"}
+			} else {
+				s.Auth = []string{"spa"}
+			}
+		}
+		s.Username = elemText(p, "LoginName")
+		settings = append(settings, s)
+	}
+	return settings
+}
+
+// Encryption 优先（None/SSL/TLS/Auto，其中 TLS 指 STARTTLS）；否则 SSL=off 为明文，
+// SSL=on 或缺省时按端口的惯例决定，未知端口按隐式 TLS
+func autodiscoverSecurity(encryption, ssl string, port int) string {
+	switch strings.ToUpper(encryption) {
+	case "NONE":
+		return models.SecurityPlain
+	case "SSL":
+		return models.SecurityTLS
+	case "TLS", "STARTTLS":
+		return models.SecurityStartTLS
+	case "AUTO":
+		return models.SecurityAuto
+	case "":
+	default:
+		return ""
+	}
+	switch strings.ToLower(ssl) {
+	case "off":
+		return models.SecurityPlain
+	case "on", "":
+		if _, mode := utils.MailPortDefaults(port); mode != "" {
+			return mode
+		}
+		return models.SecurityTLS
+	}
+	return ""
+}
+
+// settingsFromAutoconfig 解析 Autoconfig 中的 incomingServer 和 outgoingServer
+func settingsFromAutoconfig(config string) []models.ServerSetting {
+	doc := etree.NewDocument()
+	if err := doc.ReadFromString(config); err != nil {
+		return nil
+	}
+	provider := doc.FindElement("/clientConfig/emailProvider")
+	if provider == nil {
+		return nil
+	}
+	var settings []models.ServerSetting
+	for _, tag := range []string{"incomingServer", "outgoingServer"} {
+		for _, e := range provider.SelectElements(tag) {
+			protocol := mailProtocol(e.SelectAttrValue("type", ""))
+			if protocol == "" {
+				continue
+			}
+			port, _ := strconv.Atoi(elemText(e, "port"))
+			s := newSetting("autoconfig", protocol, elemText(e, "hostname"), port, autoconfigSecurity(elemText(e, "socketType")))
+			for _, a := range e.SelectElements("authentication") {
+				s.Auth = append(s.Auth, strings.TrimSpace(a.Text()))
+			}
+			s.Username = elemText(e, "username")
+			settings = append(settings, s)
+		}
+	}
+	return settings
+}
+
+func autoconfigSecurity(socketType string) string {
+	switch strings.ToUpper(socketType) {
+	case "SSL", "TLS":
+		return models.SecurityTLS
+	case "STARTTLS":
+		return models.SecurityStartTLS
+	case "PLAIN":
+		return models.SecurityPlain
+	}
+	return ""
+}
+
+// settingsFromSRV 按 RFC 6186/8314 的服务名得到协议和 TLS 方式，目标为 "." 的记录表示不提供，跳过
+func settingsFromSRV(result models.SRVResult) []models.ServerSetting {
+	var settings []models.ServerSetting
+	for _, records := range [][]models.SRVRecord{result.RecvRecords, result.SendRecords} {
+		for _, r := range records {
+			if s, ok := settingFromSRVRecord(r); ok {
+				settings = append(settings, s)
+			}
+		}
+	}
+	return settings
+}
+
+func settingFromSRVRecord(r models.SRVRecord) (models.ServerSetting, bool) {
+	protocol, security := srvMailMode(r.Service)
+	if protocol == "" || normalizeHost(r.Target) == "" {
+		return models.ServerSetting{}, false
+	}
+	return newSetting("srv", protocol, r.Target, int(r.Port), security), true
+}
+
+func srvMailMode(service string) (protocol, security string) {
+	switch getServiceType(service) {
+	case "IMAPS":
+		return "imap", models.SecurityTLS
+	case "IMAP":
+		return "imap", models.SecurityStartTLS
+	case "POP3S":
+		return "pop3", models.SecurityTLS
+	case "POP3":
+		return "pop3", models.SecurityStartTLS
+	case "SMTPS":
+		return "smtp", models.SecurityTLS
+	case "SMTP":
+		return "smtp", models.SecurityStartTLS
+	}
+	return "", ""
+}
+
+// settingFromGuess 把一次端口猜测转换成设置：隐式 TLS 端口为 tls，其余按端口惯例
+func settingFromGuess(g models.GuessResult) (models.ServerSetting, bool) {
+	protocol := mailProtocol(g.Protocol)
+	if protocol == "" {
+		protocol, _ = utils.MailPortDefaults(g.Port)
+	}
+	if protocol == "" {
+		return models.ServerSetting{}, false
+	}
+	security := models.SecurityTLS
+	if !g.TLS {
+		if _, security = utils.MailPortDefaults(g.Port); security == "" {
+			security = models.SecurityPlain
+		}
+	}
+	return newSetting("guess", protocol, g.Host, g.Port, security), true
+}
+
+// 设置的比较键：协议、主机、端口和 TLS 方式
+func settingKey(s models.ServerSetting) string {
+	return fmt.Sprintf("%s %s:%d (%s)", s.Protocol, s.Host, s.Port, s.Security)
+}
@@ -0,0 +1,172 @@
+package measurement
+
+import (
+	"encoding/json"
+	"reflect"
+	"scan-website/models"
+	"testing"
+)
+
+func autodiscoverXML(protocols string) string {
+	return `<?xml version="1.0" encoding="utf-8"?>
+<Autodiscover xmlns="http://schemas.microsoft.com/exchange/autodiscover/responseschema/2006">
+<Response xmlns="http://schemas.microsoft.com/exchange/autodiscover/outlook/responseschema/2006a">
+<Account><AccountType>email</AccountType><Action>settings</Action>` + protocols + `</Account>
+</Response>
+</Autodiscover>`
+}
+
+func TestAutodiscoverSecurity(t *testing.T) {
+	tests := []struct {
+		encryption, ssl string
+		port            int
+		want            string
+	}{
+		// Encryption 优先于 SSL
+		{"SSL", "off", 143, models.SecurityTLS},
+		{"TLS", "", 993, models.SecurityStartTLS},
+		{"None", "on", 993, models.SecurityPlain},
+		{"Auto", "", 587, models.SecurityAuto},
+		{"bogus", "on", 993, ""},
+		// SSL=on 或缺省时按端口惯例，未知端口按隐式 TLS
+		{"", "off", 993, models.SecurityPlain},
+		{"", "on", 143, models.SecurityStartTLS},
+		{"", "", 993, models.SecurityTLS},
+		{"", "", 587, models.SecurityStartTLS},
+		{"", "", 2525, models.SecurityTLS},
+		{"", "maybe", 993, ""},
+	}
+	for _, tt := range tests {
+		if got := autodiscoverSecurity(tt.encryption, tt.ssl, tt.port); got != tt.want {
+			t.Errorf("autodiscoverSecurity(%q, %q, %d) = %q, want %q", tt.encryption, tt.ssl, tt.port, got, tt.want)
+		}
+	}
+}
+
+func TestSettingsFromAutodiscover(t *testing.T) {
+	config := autodiscoverXML(`
+<Protocol><Type>IMAP</Type><Server>Mail.Example.com.</Server><Port>993</Port><LoginName>%EMAILADDRESS%</LoginName></Protocol>
+<Protocol><Type>SMTP</Type><Server>smtp.example.com</Server><Port>587</Port><SSL>on</SSL><SPA>off</SPA></Protocol>
+<Protocol Type="POP3"><Server>pop.example.com</Server><Port>110</Port><SSL>off</SSL><AuthRequired>off</AuthRequired></Protocol>
+<Protocol><Type>EXCH</Type><Server>ews.example.com</Server></Protocol>`)
+	want := []models.ServerSetting{
+		{Protocol: "imap", Role: "incoming", Host: "mail.example.com", Port: 993, Security: models.SecurityTLS, Auth: []string{"spa"}, Username: "%EMAILADDRESS%", Source: "autodiscover"},
+		{Protocol: "smtp", Role: "outgoing", Host: "smtp.example.com", Port: 587, Security: models.SecurityStartTLS, Auth: []string{"password-cleartext"}, Source: "autodiscover"},
+		{Protocol: "pop3", Role: "incoming", Host: "pop.example.com", Port: 110, Security: models.SecurityPlain, Source: "autodiscover"},
+	}
+	if got := settingsFromAutodiscover(config); !reflect.DeepEqual(got, want) {
+		t.Errorf("settingsFromAutodiscover() =\n%+v\nwant\n%+v", got, want)
+	}
+
+	// 不是 email/settings 的响应没有设置
+	redirect := `<Autodiscover><Response><Account><AccountType>email</AccountType><Action>redirectAddr</Action></Account></Response></Autodiscover>`
+	if got := settingsFromAutodiscover(redirect); got != nil {
+		t.Errorf("settingsFromAutodiscover(redirect) = %+v, want nil", got)
+	}
+}
+
+func TestSettingsFromAutoconfig(t *testing.T) {
+	config := `<clientConfig version="1.1"><emailProvider id="example.com">
+<incomingServer type="imap"><hostname>imap.example.com</hostname><port>993</port><socketType>SSL</socketType><username>%EMAILADDRESS%</username><authentication>password-cleartext</authentication></incomingServer>
+<incomingServer type="pop3"><hostname>pop.example.com</hostname><port>110</port><socketType>plain</socketType></incomingServer>
+<incomingServer type="exchange"><hostname>ews.example.com</hostname></incomingServer>
+<outgoingServer type="smtp"><hostname>smtp.example.com</hostname><port>465</port><socketType>TLS</socketType><authentication>OAuth2</authentication><authentication>password-encrypted</authentication></outgoingServer>
+<outgoingServer type="smtp"><hostname>smtp.example.com</hostname><port>587</port><socketType>STARTTLS</socketType></outgoingServer>
+<outgoingServer type="smtp"><hostname>smtp.example.com</hostname><port>25</port><socketType>none</socketType></outgoingServer>
+</emailProvider></clientConfig>`
+	want := []models.ServerSetting{
+		{Protocol: "imap", Role: "incoming", Host: "imap.example.com", Port: 993, Security: models.SecurityTLS, Auth: []string{"password-cleartext"}, Username: "%EMAILADDRESS%", Source: "autoconfig"},
+		{Protocol: "pop3", Role: "incoming", Host: "pop.example.com", Port: 110, Security: models.SecurityPlain, Source: "autoconfig"},
+		{Protocol: "smtp", Role: "outgoing", Host: "smtp.example.com", Port: 465, Security: models.SecurityTLS, Auth: []string{"OAuth2", "password-encrypted"}, Source: "autoconfig"},
+		{Protocol: "smtp", Role: "outgoing", Host: "smtp.example.com", Port: 587, Security: models.SecurityStartTLS, Source: "autoconfig"},
+		{Protocol: "smtp", Role: "outgoing", Host: "smtp.example.com", Port: 25, Security: "", Source: "autoconfig"},
+	}
+	if got := settingsFromAutoconfig(config); !reflect.DeepEqual(got, want) {
+		t.Errorf("settingsFromAutoconfig() =\n%+v\nwant\n%+v", got, want)
+	}
+}
+
+func TestSettingsFromSRV(t *testing.T) {
+	result := models.SRVResult{
+		RecvRecords: []models.SRVRecord{
+			{Service: "_imaps._tcp.example.com.", Port: 993, Target: "Mail.Example.com."},
+			{Service: "_imap._tcp.example.com.", Port: 143, Target: "mail.example.com."},
+			{Service: "_pop3s._tcp.example.com.", Port: 995, Target: "mail.example.com."},
+			{Service: "_pop3._tcp.example.com.", Port: 0, Target: "."},
+		},
+		SendRecords: []models.SRVRecord{
+			{Service: "_submissions._tcp.example.com.", Port: 465, Target: "smtp.example.com."},
+			{Service: "_submission._tcp.example.com.", Port: 587, Target: "smtp.example.com."},
+		},
+		OtherRecords: []models.SRVRecord{
+			{Service: "_autodiscover._tcp.example.com.", Port: 443, Target: "autodiscover.example.com."},
+		},
+	}
+	want := []models.ServerSetting{
+		{Protocol: "imap", Role: "incoming", Host: "mail.example.com", Port: 993, Security: models.SecurityTLS, Source: "srv"},
+		{Protocol: "imap", Role: "incoming", Host: "mail.example.com", Port: 143, Security: models.SecurityStartTLS, Source: "srv"},
+		{Protocol: "pop3", Role: "incoming", Host: "mail.example.com", Port: 995, Security: models.SecurityTLS, Source: "srv"},
+		{Protocol: "smtp", Role: "outgoing", Host: "smtp.example.com", Port: 465, Security: models.SecurityTLS, Source: "srv"},
+		{Protocol: "smtp", Role: "outgoing", Host: "smtp.example.com", Port: 587, Security: models.SecurityStartTLS, Source: "srv"},
+	}
+	if got := settingsFromSRV(result); !reflect.DeepEqual(got, want) {
+		t.Errorf("settingsFromSRV() =\n%+v\nwant\n%+v", got, want)
+	}
+}
+
+func TestSettingFromGuess(t *testing.T) {
+	tests := []struct {
+		guess models.GuessResult
+		want  models.ServerSetting
+		ok    bool
+	}{
+		{models.GuessResult{Host: "imap.example.com", Port: 993, Protocol: "IMAP", TLS: true},
+			models.ServerSetting{Protocol: "imap", Role: "incoming", Host: "imap.example.com", Port: 993, Security: models.SecurityTLS, Source: "guess"}, true},
+		{models.GuessResult{Host: "smtp.example.com", Port: 587, Protocol: "SMTP"},
+			models.ServerSetting{Protocol: "smtp", Role: "outgoing", Host: "smtp.example.com", Port: 587, Security: models.SecurityStartTLS, Source: "guess"}, true},
+		// 旧版结果没有协议名，按端口推断
+		{models.GuessResult{Host: "pop.example.com", Port: 110},
+			models.ServerSetting{Protocol: "pop3", Role: "incoming", Host: "pop.example.com", Port: 110, Security: models.SecurityStartTLS, Source: "guess"}, true},
+		{models.GuessResult{Host: "smtp.example.com", Port: 2525, Protocol: "SMTP"},
+			models.ServerSetting{Protocol: "smtp", Role: "outgoing", Host: "smtp.example.com", Port: 2525, Security: models.SecurityPlain, Source: "guess"}, true},
+		{models.GuessResult{Host: "www.example.com", Port: 8080}, models.ServerSetting{}, false},
+	}
+	for _, tt := range tests {
+		got, ok := settingFromGuess(tt.guess)
+		if ok != tt.ok || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("settingFromGuess(%+v) = %+v, %v, want %+v, %v", tt.guess, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+// check_dif_results.jsonl 新旧两种格式的 PortUsageDetail
+func TestPortUsageDetailUnmarshal(t *testing.T) {
+	tests := []struct {
+		desc string
+		json string
+		want PortUsageDetail
+	}{
+		{"legacy autodiscover ssl=on", `{"protocol":"IMAP","port":"993","status":"standard","host":"Mail.Example.com.","ssl":"on"}`,
+			PortUsageDetail{ServerSetting: models.ServerSetting{Protocol: "imap", Role: "incoming", Host: "mail.example.com", Port: 993, Security: models.SecurityTLS}, Status: "standard"}},
+		{"legacy autodiscover ssl=on on 143", `{"protocol":"IMAP","port":"143","host":"mail.example.com","ssl":"on"}`,
+			PortUsageDetail{ServerSetting: models.ServerSetting{Protocol: "imap", Role: "incoming", Host: "mail.example.com", Port: 143, Security: models.SecurityStartTLS}}},
+		{"legacy autodiscover ssl=off", `{"protocol":"POP3","port":"110","host":"pop.example.com","ssl":"off"}`,
+			PortUsageDetail{ServerSetting: models.ServerSetting{Protocol: "pop3", Role: "incoming", Host: "pop.example.com", Port: 110, Security: models.SecurityPlain}}},
+		{"legacy autoconfig socketType", `{"protocol":"smtp","port":" 587 ","host":"smtp.example.com","ssl":"STARTTLS"}`,
+			PortUsageDetail{ServerSetting: models.ServerSetting{Protocol: "smtp", Role: "outgoing", Host: "smtp.example.com", Port: 587, Security: models.SecurityStartTLS}}},
+		{"legacy SRV", `{"protocol":"SMTP","port":"465","status":"secure","host":"smtp.example.com","ssl":"SSL"}`,
+			PortUsageDetail{ServerSetting: models.ServerSetting{Protocol: "smtp", Role: "outgoing", Host: "smtp.example.com", Port: 465, Security: models.SecurityTLS}, Status: "secure"}},
+		{"current", `{"protocol":"imap","role":"incoming","host":"mail.example.com","port":993,"security":"tls","source":"autodiscover","status":"standard"}`,
+			PortUsageDetail{ServerSetting: models.ServerSetting{Protocol: "imap", Role: "incoming", Host: "mail.example.com", Port: 993, Security: models.SecurityTLS, Source: "autodiscover"}, Status: "standard"}},
+	}
+	for _, tt := range tests {
+		var got PortUsageDetail
+		if err := json.Unmarshal([]byte(tt.json), &got); err != nil {
+			t.Errorf("%s: %v", tt.desc, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %+v, want %+v", tt.desc, got, tt.want)
+		}
+	}
+}
@@ -1,8 +1,12 @@
 package models
 
 import (
+	"encoding/json"
 	"encoding/xml"
+	"fmt"
+	"net"
 	"net/http"
+	"strconv"
 	"sync"
 	"time"
 
@@ -32,6 +36,34 @@ type ConnectInfo struct {
 	Error   string   `json:"error,omitempty"`
 }
 
+// ServerSetting 是一条邮件服务器设置。Autodiscover、Autoconfig、SRV 和 Guess 解析后都统一成这个结构，
+// 机制内外的比较以及端口、TLS 方式的统计都基于它；ProtocolInfo 只保留各机制的原始字段和规范检查结果
+type ServerSetting struct {
+	Protocol string   `json:"protocol"`           // imap / pop3 / smtp
+	Role     string   `json:"role"`               // incoming / outgoing
+	Host     string   `json:"host"`               // 小写、去掉末尾的点，可能含 %EMAILDOMAIN% 等占位符
+	Port     int      `json:"port"`               // 0 表示配置中没有给出
+	Security string   `json:"security"`           // plain / starttls / tls / auto，无法识别时为空
+	Auth     []string `json:"auth,omitempty"`     // 认证方式，如 password-cleartext、OAuth2、spa
+	Username string   `json:"username,omitempty"` // 用户名模板，如 %EMAILADDRESS%
+	Source   string   `json:"source"`             // autodiscover / autoconfig / srv / guess
+}
+
+const (
+	SecurityPlain    = "plain"
+	SecurityStartTLS = "starttls"
+	SecurityTLS      = "tls"
+	SecurityAuto     = "auto"
+)
+
+// RoleOf 返回协议对应的角色，smtp 为 outgoing，其余为 incoming
+func RoleOf(protocol string) string {
+	if protocol == "smtp" {
+		return "outgoing"
+	}
+	return "incoming"
+}
+
 type ProtocolInfo struct {
 	Type           string `json:"Type"`
 	Server         string `json:"Server"`
@@ -55,7 +87,15 @@ type DomainResult struct {
 	Autodiscover  []AutodiscoverResult `json:"autodiscover"`
 	Autoconfig    []AutoconfigResult   `json:"autoconfig"`
 	SRV           SRVResult            `json:"srv"`
-	GUESS         []string             `json:"guess"` //9.13
+	GUESS         []GuessResult        `json:"guess"`                  //9.13
+	ClientGuess   []ClientGuessResult  `json:"client_guess,omitempty"` // 模拟客户端猜测得到的配置
+	Wildcard      *WildcardInfo        `json:"wildcard,omitempty"`     // 域名下存在通配符解析时不为空
+	Targets       []*TargetFamilies    `json:"targets,omitempty"`      // 各配置目标的 A/AAAA 及分地址族连通性
+	TLSVersions   []TLSVersionProbe    `json:"tls_versions,omitempty"`
+	DNSSEC        []*DNSSECResult      `json:"dnssec,omitempty"` // 本地 DNSSEC 验证结果（SRV/MX/TLSA）
+	MX            *MXResult            `json:"mx,omitempty"`
+	MTASTS        *MTASTSResult        `json:"mta_sts,omitempty"`
+	Provider      *ProviderResult      `json:"provider,omitempty"`
 	Timestamp     string               `json:"timestamp"`
 	ErrorMessages []string             `json:"errors"`
 }
@@ -104,18 +144,180 @@ type Error struct {
 
 type CertInfo struct {
 	IsTrusted       bool
+	TrustedStores   []string // 信任该证书链的信任库（mozilla/microsoft/system）
 	VerifyError     string
 	IsHostnameMatch bool
 	IsInOrder       string
+	ChainReport     *ChainReport
 	IsExpired       bool
 	IsSelfSigned    bool
 	SignatureAlg    string
-	AlgWarning      string
+	AlgWarning      string        // AlgWarnings 拼接后的文本
+	AlgWarnings     []CertWarning // 链上所有证书的告警
 	TLSVersion      uint16
+	TLSSession      *TLSSessionInfo
 	Subject         string
 	Issuer          string
 	RawCert         []byte
 	RawCerts        []string //8.15
+
+	NotBefore             time.Time
+	NotAfter              time.Time
+	DaysToExpiry          int      // 负数表示已过期的天数
+	SANs                  []string // DNS/IP/邮箱/URI
+	PublicKeyType         string   // RSA / ECDSA / DSA / Ed25519
+	PublicKeySize         int
+	Fingerprint           string   // leaf 的 SHA-256
+	ChainFingerprints     []string // 服务端发送的整条链，顺序与 RawCerts 一致，同时也是证书库中的 ID
+	SCTCount              int      // 证书内嵌的 SCT 数量
+	OCSPServers           []string
+	CRLDistributionPoints []string
+	MustStaple            bool
+	ValidationType        string // EV / OV / DV / IV / unknown
+}
+
+// 证书库（certs.jsonl）中的一条记录，ID 为 DER 的 SHA-256
+type CertRecord struct {
+	ID       string    `json:"id"`
+	Subject  string    `json:"subject"`
+	Issuer   string    `json:"issuer"`
+	NotAfter time.Time `json:"not_after"`
+	Raw      string    `json:"raw"` // base64 DER
+}
+
+// 对一个 name/type 应答的本地 DNSSEC 验证结果
+type DNSSECResult struct {
+	Name   string `json:"name"`
+	Type   string `json:"type"`
+	Status string `json:"status"` // secure / insecure / bogus / indeterminate
+	Reason string `json:"reason,omitempty"`
+}
+
+// 一个邮件服务器端口的 TLSA 记录及与证书链的匹配结果（RFC 6698/7671/7672）
+type DANEResult struct {
+	Name         string        `json:"name"`              // _port._tcp.host
+	Records      []string      `json:"records,omitempty"` // TLSA 记录文本
+	DNSSEC       *DNSSECResult `json:"dnssec,omitempty"`
+	Verdict      string        `json:"verdict"`                 // none / unusable / valid / mismatch / no-chain / error
+	MatchedUsage string        `json:"matched_usage,omitempty"` // DANE-EE / DANE-TA / PKIX-EE / PKIX-TA
+	Error        string        `json:"error,omitempty"`
+}
+
+// 一个邮件服务器（主机+端口）的连接证书与 DANE 结果
+type MailHostTLS struct {
+	Host     string      `json:"host"`
+	Port     int         `json:"port"`
+	Protocol string      `json:"protocol"` // smtp / imap / pop3
+	Mode     string      `json:"mode"`     // tls / starttls
+	Sources  []string    `json:"sources"`  // srv / autodiscover / autoconfig / guess / mx
+	CertInfo *CertInfo   `json:"cert_info,omitempty"`
+	DANE     *DANEResult `json:"dane,omitempty"`
+	Error    string      `json:"error,omitempty"`
+}
+
+type DANECheckResult struct {
+	Domain string         `json:"domain"`
+	Hosts  []*MailHostTLS `json:"hosts"`
+}
+
+// 域名的全部 MX 记录
+type MXResult struct {
+	Records []MXRecord `json:"records,omitempty"` // 按优先级排序
+	NullMX  bool       `json:"null_mx,omitempty"` // RFC 7505 "0 ."，明确不接收邮件
+	Error   string     `json:"error,omitempty"`
+}
+
+type MXRecord struct {
+	Host       string   `json:"host"`
+	Preference uint16   `json:"preference"`
+	A          []string `json:"a,omitempty"`
+	AAAA       []string `json:"aaaa,omitempty"`
+	Provider   string   `json:"provider,omitempty"` // provider.Annotate 按 provider_rules 中的 MX 规则标注，未识别时为空
+}
+
+// 邮件服务商识别结果，Name 为得分最高的服务商，无法识别时为空
+type ProviderResult struct {
+	Name        string          `json:"name,omitempty"`
+	Matches     []ProviderMatch `json:"matches,omitempty"`
+	SPFIncludes []string        `json:"spf_includes,omitempty"` // SPF 记录中的 include/redirect 域名
+}
+
+// 一条命中规则的证据
+type ProviderMatch struct {
+	Provider string `json:"provider"`
+	Signal   string `json:"signal"` // mx / host / spf / cert_subject / cert_issuer / microsoft_online
+	Value    string `json:"value"`
+}
+
+// 域名的入站传输安全：MTA-STS（RFC 8461）与 TLS-RPT（RFC 8460）
+type MTASTSResult struct {
+	TXTRecord     string         `json:"txt_record,omitempty"` // _mta-sts.<domain> 中 v=STSv1 的记录
+	ID            string         `json:"id,omitempty"`
+	PolicyURL     string         `json:"policy_url,omitempty"`
+	Policy        *MTASTSPolicy  `json:"policy,omitempty"`
+	CertInfo      *CertInfo      `json:"cert_info,omitempty"` // 策略服务器 mta-sts.<domain> 的证书
+	TLSRPT        *TLSRPTRecord  `json:"tls_rpt,omitempty"`
+	PolicyIgnored bool           `json:"policy_ignored,omitempty"` // 策略服务器证书不可信或主机名不匹配，发送方必须忽略该策略（RFC 8461 3.3）
+	MXHosts       []MTASTSMXHost `json:"mx_hosts,omitempty"`       // 每个 MX 主机与策略的匹配结果
+	MXMatch       string         `json:"mx_match,omitempty"`       // 全部匹配为 match，任一不匹配为 mismatch，策略或 MX 缺失时为空
+	Errors        []string       `json:"errors,omitempty"`
+}
+
+// 策略对每个 MX 都适用（RFC 8461 4.1）
+type MTASTSMXHost struct {
+	Host  string `json:"host"`
+	Match bool   `json:"match"`
+}
+
+type MTASTSPolicy struct {
+	Version string   `json:"version"`
+	Mode    string   `json:"mode"` // enforce / testing / none
+	MX      []string `json:"mx"`   // 允许的 MX 模式，可含 "*." 前缀
+	MaxAge  int      `json:"max_age"`
+}
+
+type TLSRPTRecord struct {
+	Record string   `json:"record"`
+	RUA    []string `json:"rua,omitempty"` // mailto: / https: 报告地址
+}
+
+// 获取配置时协商得到的 TLS 会话参数
+type TLSSessionInfo struct {
+	Version     string `json:"version"`
+	CipherSuite string `json:"cipher_suite"`
+	ALPN        string `json:"alpn,omitempty"`
+	DidResume   bool   `json:"did_resume"`
+	OCSPStapled bool   `json:"ocsp_stapled"`
+	SNI         string `json:"sni"` // 客户端发送的 SNI，IP 地址访问时为空
+}
+
+// 配置服务器接受的 TLS 版本
+type TLSVersionProbe struct {
+	Host     string          `json:"host"`
+	Port     string          `json:"port"`
+	Versions map[string]bool `json:"versions"` // "TLS 1.0" -> 是否接受
+	Error    string          `json:"error,omitempty"`
+}
+
+// 证书告警，CertIndex 是在服务端发送的链中的下标
+type CertWarning struct {
+	CertIndex int    `json:"cert_index"`
+	Code      string `json:"code"` // weak_key / dsa_key / weak_curve / weak_signature / long_validity / missing_san / key_usage / ext_key_usage ...
+	Message   string `json:"message"`
+}
+
+// 服务端发送的证书链结构分析
+type ChainReport struct {
+	Length              int    `json:"length"`
+	LeafPresent         bool   `json:"leaf_present"`          // 第一张是终端实体证书而不是 CA
+	Order               string `json:"order"`                 // empty / single / yes / not / no_issuer
+	InOrder             bool   `json:"in_order"`              // 路径上每张证书都紧跟在被签发者之后
+	Path                []int  `json:"path"`                  // 从 leaf 沿颁发关系走过的下标
+	MissingIntermediate bool   `json:"missing_intermediate"`  // 没有走到自签名根，且缺少下一级颁发者
+	ExtraCerts          []int  `json:"extra_certs,omitempty"` // 不在路径上的多余/无关证书下标
+	RootSent            bool   `json:"root_sent"`
+	Duplicates          []int  `json:"duplicates,omitempty"`
+	CrossSigned         bool   `json:"cross_signed"` // 存在多个可选颁发者或同一 CA 的交叉签名证书
 }
 
 // AutodiscoverResult 保存每次Autodiscover查询的结果
@@ -128,27 +330,31 @@ type AutodiscoverResult struct {
 	Redirects         []map[string]interface{} `json:"redirects"` // 重定向链
 	Config            string                   `json:"config"`    // 配置信息
 	CertInfo          *CertInfo                `json:"cert_info"`
-	Error             string                   `json:"error"` // 错误信息（如果有）
+	ViaWildcard       bool                     `json:"via_wildcard,omitempty"` // URI 主机只通过通配符解析（autodiscover./autoconfig. 子域名）
+	Error             string                   `json:"error"`                  // 错误信息（如果有）
 }
 
 // AutoconfigResult 保存每次Autoconfig查询的结果
 type AutoconfigResult struct {
-	Domain    string                   `json:"domain"`
-	Method    string                   `json:"method"`
-	Index     int                      `json:"index"`
-	URI       string                   `json:"uri"`
-	Redirects []map[string]interface{} `json:"redirects"`
-	Config    string                   `json:"config"`
-	CertInfo  *CertInfo                `json:"cert_info"`
-	Error     string                   `json:"error"`
+	Domain      string                   `json:"domain"`
+	Method      string                   `json:"method"`
+	Index       int                      `json:"index"`
+	MXHost      string                   `json:"mx_host,omitempty"` // MX/MX_samedomain 方法所用的 MX 主机
+	URI         string                   `json:"uri"`
+	Redirects   []map[string]interface{} `json:"redirects"`
+	Config      string                   `json:"config"`
+	CertInfo    *CertInfo                `json:"cert_info"`
+	ViaWildcard bool                     `json:"via_wildcard,omitempty"` // URI 主机只通过通配符解析（autodiscover./autoconfig. 子域名）
+	Error       string                   `json:"error"`
 }
 
 type SRVRecord struct {
-	Service  string
-	Priority uint16
-	Weight   uint16
-	Port     uint16
-	Target   string
+	Service     string
+	Priority    uint16
+	Weight      uint16
+	Port        uint16
+	Target      string
+	TargetCNAME []string `json:"TargetCNAME,omitempty"` // 目标是别名时的 CNAME 链，RFC 2782 不允许
 }
 
 type DNSRecord struct {
@@ -164,19 +370,89 @@ type DNSRecord struct {
 }
 
 type SRVResult struct {
-	Domain      string      `json:"domain"`
-	RecvRecords []SRVRecord `json:"recv_records,omitempty"` // 收件服务 (IMAP/POP3)
-	SendRecords []SRVRecord `json:"send_records,omitempty"` // 发件服务 (SMTP)
-	DNSRecord   *DNSRecord  `json:"dns_record,omitempty"`
+	Domain       string      `json:"domain"`
+	RecvRecords  []SRVRecord `json:"recv_records,omitempty"`  // 收件服务 (IMAP/POP3)
+	SendRecords  []SRVRecord `json:"send_records,omitempty"`  // 发件服务 (SMTP)
+	OtherRecords []SRVRecord `json:"other_records,omitempty"` // Autodiscover、CalDAV/CardDAV、JMAP
+	NotOffered   []string    `json:"not_offered,omitempty"`   // 唯一记录的目标为 "."，即明确声明不提供该服务（RFC 2782）
+	DNSRecord    *DNSRecord  `json:"dns_record,omitempty"`
 }
 
 // 8.10
 type GuessResult struct {
-	Host     string   `json:"host"`
-	Port     int      `json:"port"`
-	Protocol string   `json:"protocol"`
-	IPs      []string `json:"ips"`
-	Reach    bool     `json:"reach"`
+	Host        string   `json:"host"`
+	Port        int      `json:"port"`
+	Protocol    string   `json:"protocol"` // SMTP / IMAP / POP
+	IPs         []string `json:"ips"`
+	Reach       bool     `json:"reach"`                  // TCP 连接成功
+	Confirmed   bool     `json:"confirmed"`              // 读到协议问候语或完成隐式 TLS 握手
+	TLS         bool     `json:"tls,omitempty"`          // 隐式 TLS 端口上完成了握手
+	Banner      string   `json:"banner,omitempty"`       // 问候语第一行
+	Error       string   `json:"error,omitempty"`        // 连上后确认失败的原因
+	ViaWildcard bool     `json:"via_wildcard,omitempty"` // 地址与通配符解析结果相同，很可能不是真正的邮件服务器
+}
+
+// 一个配置目标（主机+端口）的 A/AAAA 解析结果，开启分地址族探测时记录 IPv4/IPv6 各自能否连上
+type TargetFamilies struct {
+	Host    string       `json:"host"`
+	Port    int          `json:"port"`
+	Sources []string     `json:"sources"` // autodiscover / autoconfig / srv / guess
+	A       []string     `json:"a,omitempty"`
+	AAAA    []string     `json:"aaaa,omitempty"`
+	IPv4    *FamilyProbe `json:"ipv4,omitempty"`
+	IPv6    *FamilyProbe `json:"ipv6,omitempty"`
+}
+
+type FamilyProbe struct {
+	Addr      string `json:"addr"` // 实际连接的地址
+	Reachable bool   `json:"reachable"`
+	Error     string `json:"error,omitempty"`
+}
+
+// 通配符探测：随机子域名能解析时记录其地址
+type WildcardInfo struct {
+	Probe string   `json:"probe"` // 用于探测的随机子域名
+	IPs   []string `json:"ips"`
+}
+
+// 模拟 Thunderbird/Outlook 在没有任何配置机制时猜测得到的服务器
+type ClientGuessResult struct {
+	Client   string         `json:"client"` // thunderbird / outlook
+	Incoming *GuessedServer `json:"incoming,omitempty"`
+	Outgoing *GuessedServer `json:"outgoing,omitempty"`
+	Tried    int            `json:"tried"` // 按该客户端顺序走到的候选数，与其他客户端是否已探测过无关
+}
+
+type GuessedServer struct {
+	Protocol   string `json:"protocol"` // imap / pop3 / smtp
+	Host       string `json:"host"`
+	Port       int    `json:"port"`
+	SocketType string `json:"socket_type"` // SSL / STARTTLS，与 Autoconfig 的 socketType 一致
+}
+
+// 旧版结果中 guess 是 "host:port" 字符串，只代表 TCP 可达，按未确认处理
+func (g *GuessResult) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		host, portStr, err := net.SplitHostPort(s)
+		if err != nil {
+			return fmt.Errorf("invalid guess entry %q: %v", s, err)
+		}
+		port, err := strconv.Atoi(portStr)
+		if err != nil {
+			return fmt.Errorf("invalid guess entry %q: %v", s, err)
+		}
+		*g = GuessResult{Host: host, Port: port, Protocol: guessPortProtocols[port], Reach: true}
+		return nil
+	}
+	type plain GuessResult
+	return json.Unmarshal(b, (*plain)(g))
+}
+
+var guessPortProtocols = map[int]string{
+	465: "SMTP", 587: "SMTP",
+	143: "IMAP", 993: "IMAP",
+	110: "POP", 995: "POP",
 }
 
 // 尝试在界面展示Recently Seen 5.19
@@ -213,9 +489,10 @@ type ProgressUpdate struct {
 }
 
 type MethodConfig struct {
-	Method       string         `json:"Method"`
-	Protocols    []ProtocolInfo `json:"Protocols"`
-	OverallCheck string         `json:"OverallCheck"`
+	Method       string          `json:"Method"`
+	Protocols    []ProtocolInfo  `json:"Protocols"`
+	OverallCheck string          `json:"OverallCheck"`
+	Settings     []ServerSetting `json:"Settings,omitempty"` // 规范化后的邮件服务器设置
 }
 
 //	type ProtocolInfo struct {
@@ -244,12 +521,6 @@ type DomainCheckResult struct {
 	Inconsistent             bool            `json:"Inconsistent,omitempty"`             // 记录是否有不一致的情况
 } //9.14
 
-var (
-	//msg       = new(dns.Msg)
-	DnsServer = "8.8.8.8:53"
-	//client    = new(dns.Client)
-)
-
 var RecentScans []ScanHistory
 
 const MaxRecent = 20
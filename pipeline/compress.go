@@ -0,0 +1,168 @@
+package pipeline
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// 按文件名后缀透明地压缩/解压：.gz 用 gzip，.zst/.zstd 用 zstd，其余为明文。
+// 读取时还会检查文件头的魔数，后缀和内容不一致（如改过名的文件）也能读
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// Compression 文件使用的压缩格式
+type Compression int
+
+const (
+	None Compression = iota
+	Gzip
+	Zstd
+)
+
+// CompressionOf 按后缀判断压缩格式
+func CompressionOf(name string) Compression {
+	lower := strings.ToLower(name)
+	switch {
+	case strings.HasSuffix(lower, ".gz"):
+		return Gzip
+	case strings.HasSuffix(lower, ".zst"), strings.HasSuffix(lower, ".zstd"):
+		return Zstd
+	}
+	return None
+}
+
+// Open 打开文件用于读取，压缩文件返回解压后的流
+func Open(name string) (io.ReadCloser, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	br := bufio.NewReaderSize(file, 256*1024)
+	head, _ := br.Peek(len(zstdMagic))
+
+	switch {
+	case bytes.HasPrefix(head, gzipMagic):
+		// 追加写产生的多个 gzip 成员会被依次读出
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			file.Close()
+			return nil, fmt.Errorf("failed to open gzip stream %s: %v", name, err)
+		}
+		return &readCloser{Reader: gz, closers: []io.Closer{gz, file}}, nil
+	case bytes.HasPrefix(head, zstdMagic):
+		zr, err := zstd.NewReader(br)
+		if err != nil {
+			file.Close()
+			return nil, fmt.Errorf("failed to open zstd stream %s: %v", name, err)
+		}
+		return &readCloser{Reader: zr, closers: []io.Closer{zstdCloser{zr}, file}}, nil
+	}
+	return &readCloser{Reader: br, closers: []io.Closer{file}}, nil
+}
+
+// Create 打开文件用于写入，appendMode 为 true 时追加（压缩格式会追加一个新的 gzip 成员/zstd 帧），
+// 否则截断；压缩格式取决于后缀。返回值实现 Flusher
+func Create(name string, appendMode bool) (io.WriteCloser, error) {
+	flag := os.O_CREATE | os.O_WRONLY
+	if appendMode {
+		flag |= os.O_APPEND
+	} else {
+		flag |= os.O_TRUNC
+	}
+	file, err := os.OpenFile(name, flag, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	switch CompressionOf(name) {
+	case Gzip:
+		gz := gzip.NewWriter(file)
+		flush := func() error {
+			if err := gz.Close(); err != nil {
+				return err
+			}
+			gz.Reset(file)
+			return nil
+		}
+		return &writeCloser{Writer: gz, flush: flush, closers: []io.Closer{gz, file}}, nil
+	case Zstd:
+		zw, err := zstd.NewWriter(file)
+		if err != nil {
+			file.Close()
+			return nil, fmt.Errorf("failed to create zstd stream %s: %v", name, err)
+		}
+		flush := func() error {
+			if err := zw.Close(); err != nil {
+				return err
+			}
+			zw.Reset(file)
+			return nil
+		}
+		return &writeCloser{Writer: zw, flush: flush, closers: []io.Closer{zw, file}}, nil
+	}
+	return &writeCloser{Writer: file, closers: []io.Closer{file}}, nil
+}
+
+// Flusher 把已写入的数据完整地写到文件
+type Flusher interface {
+	Flush() error
+}
+
+// 按顺序关闭解压器和文件，返回第一个错误
+type readCloser struct {
+	io.Reader
+	closers []io.Closer
+}
+
+func (r *readCloser) Close() error {
+	return closeAll(r.closers)
+}
+
+type writeCloser struct {
+	io.Writer
+	flush   func() error // 压缩格式结束当前 gzip 成员/zstd 帧并开始新的一个，明文为 nil
+	closers []io.Closer
+}
+
+// Flush 之后文件本身就是完整可读的压缩流；只做 gzip/zstd 的同步刷新不写尾部，
+// 中途退出的文件读到末尾会报 unexpected EOF，续扫再追加的成员也读不出来
+func (w *writeCloser) Flush() error {
+	if w.flush == nil {
+		return nil
+	}
+	return w.flush()
+}
+
+func (w *writeCloser) Close() error {
+	return closeAll(w.closers)
+}
+
+func closeAll(closers []io.Closer) error {
+	var first error
+	for _, c := range closers {
+		if err := c.Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// zstd.Decoder 的 Close 没有返回值
+type zstdCloser struct {
+	d *zstd.Decoder
+}
+
+func (z zstdCloser) Close() error {
+	z.d.Close()
+	return nil
+}
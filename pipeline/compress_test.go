@@ -0,0 +1,79 @@
+package pipeline
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+type testRecord struct {
+	N int `json:"n"`
+}
+
+func writeRecords(t *testing.T, name string, appendMode bool, from, to int) *Writer {
+	t.Helper()
+	w, err := NewWriter(name, appendMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := from; i < to; i++ {
+		if err := w.Write(testRecord{N: i}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return w
+}
+
+// 读出全部记录，检查是 0..want-1 且没有错误
+func checkRecords(t *testing.T, name string, want int) {
+	t.Helper()
+	var got []int
+	if err := ReadAll(name, func(r testRecord) error {
+		got = append(got, r.N)
+		return nil
+	}); err != nil {
+		t.Fatalf("ReadAll(%s): %v", filepath.Base(name), err)
+	}
+	if len(got) != want {
+		t.Fatalf("ReadAll(%s) read %d records, want %d", filepath.Base(name), len(got), want)
+	}
+	for i, n := range got {
+		if n != i {
+			t.Fatalf("ReadAll(%s) record %d = %d", filepath.Base(name), i, n)
+		}
+	}
+}
+
+func TestAppendThenRead(t *testing.T) {
+	for _, file := range []string{"x.jsonl", "x.jsonl.gz", "x.jsonl.zst"} {
+		t.Run(file, func(t *testing.T) {
+			name := filepath.Join(t.TempDir(), file)
+			if err := writeRecords(t, name, false, 0, 3).Close(); err != nil {
+				t.Fatal(err)
+			}
+			if err := writeRecords(t, name, true, 3, 5).Close(); err != nil {
+				t.Fatal(err)
+			}
+			checkRecords(t, name, 5)
+		})
+	}
+}
+
+// Flush 之后不 Close（模拟中途退出），文件应完整可读，续扫追加的记录也能读出
+func TestFlushWithoutClose(t *testing.T) {
+	for _, file := range []string{"x.jsonl", "x.jsonl.gz", "x.jsonl.zst"} {
+		t.Run(file, func(t *testing.T) {
+			name := filepath.Join(t.TempDir(), file)
+			w := writeRecords(t, name, false, 0, 10)
+			defer w.Close()
+			if err := w.Flush(); err != nil {
+				t.Fatal(err)
+			}
+			checkRecords(t, name, 10)
+
+			if err := writeRecords(t, name, true, 10, 15).Close(); err != nil {
+				t.Fatal(err)
+			}
+			checkRecords(t, name, 15)
+		})
+	}
+}
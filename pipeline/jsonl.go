@@ -0,0 +1,170 @@
+package pipeline
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"sync"
+)
+
+// Reader 逐行读取 JSONL 并解析为 T。用 bufio.Reader 按行读取，没有 bufio.Scanner 的 64KB 行长限制；
+// 无法解析的行记录日志后跳过
+type Reader[T any] struct {
+	name    string
+	rc      io.ReadCloser
+	br      *bufio.Reader
+	line    int
+	skipped int
+}
+
+// NewReader 打开 JSONL 文件（可以是 .gz/.zst）
+func NewReader[T any](name string) (*Reader[T], error) {
+	rc, err := Open(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %v", name, err)
+	}
+	return &Reader[T]{name: name, rc: rc, br: bufio.NewReaderSize(rc, 256*1024)}, nil
+}
+
+// Next 返回下一条记录，读完时返回 io.EOF；最后一行没有换行符也会被读出
+func (r *Reader[T]) Next() (T, error) {
+	var zero T
+	for {
+		line, err := r.br.ReadBytes('\n')
+		if len(line) > 0 {
+			r.line++
+			if len(bytes.TrimSpace(line)) > 0 {
+				var v T
+				if jerr := json.Unmarshal(line, &v); jerr != nil {
+					r.skipped++
+					log.Printf("⚠️  Skipping invalid JSON line %d in %s: %v", r.line, r.name, jerr)
+				} else {
+					return v, nil
+				}
+			}
+		}
+		if err == io.EOF {
+			return zero, io.EOF
+		}
+		if err != nil {
+			return zero, fmt.Errorf("error reading %s: %v", r.name, err)
+		}
+	}
+}
+
+// Skipped 返回因 JSON 无效而跳过的行数
+func (r *Reader[T]) Skipped() int {
+	return r.skipped
+}
+
+func (r *Reader[T]) Close() error {
+	return r.rc.Close()
+}
+
+// ReadAll 依次对文件中的每条记录调用 fn，fn 返回错误时停止
+func ReadAll[T any](name string, fn func(T) error) error {
+	r, err := NewReader[T](name)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	for {
+		v, err := r.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := fn(v); err != nil {
+			return err
+		}
+	}
+}
+
+// Writer 把记录写成 JSONL。文件只打开一次，所有写入在锁内串行进行，多个 goroutine 同时写不会交错；
+// 写入有缓冲，结束时必须 Close
+type Writer struct {
+	name  string
+	mu    sync.Mutex
+	wc    io.WriteCloser
+	bw    *bufio.Writer
+	count int64
+}
+
+// NewWriter 创建 JSONL 写入器，压缩格式取决于后缀，appendMode 为 false 时覆盖已有文件
+func NewWriter(name string, appendMode bool) (*Writer, error) {
+	wc, err := Create(name, appendMode)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s for writing: %v", name, err)
+	}
+	return &Writer{name: name, wc: wc, bw: bufio.NewWriterSize(wc, 64*1024)}, nil
+}
+
+// Write 把 v 序列化为一行；序列化在锁外进行
+func (w *Writer) Write(v interface{}) error {
+	jsonData, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to marshal record to JSON: %v", err)
+	}
+	jsonData = append(jsonData, '\n')
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if _, err := w.bw.Write(jsonData); err != nil {
+		return fmt.Errorf("failed to write to %s: %v", w.name, err)
+	}
+	w.count++
+	return nil
+}
+
+// WriteRaw 原样写入一行已序列化的记录，不经过解析，缺少换行符时补上
+func (w *Writer) WriteRaw(line []byte) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if _, err := w.bw.Write(line); err != nil {
+		return fmt.Errorf("failed to write to %s: %v", w.name, err)
+	}
+	if len(line) == 0 || line[len(line)-1] != '\n' {
+		if err := w.bw.WriteByte('\n'); err != nil {
+			return fmt.Errorf("failed to write to %s: %v", w.name, err)
+		}
+	}
+	w.count++
+	return nil
+}
+
+// Flush 把缓冲写入文件；压缩格式同时结束当前的 gzip 成员/zstd 帧，此前写入的记录即使进程中途退出也能读出
+func (w *Writer) Flush() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err := w.bw.Flush(); err != nil {
+		return fmt.Errorf("failed to flush %s: %v", w.name, err)
+	}
+	if f, ok := w.wc.(Flusher); ok {
+		if err := f.Flush(); err != nil {
+			return fmt.Errorf("failed to flush %s: %v", w.name, err)
+		}
+	}
+	return nil
+}
+
+// Count 返回已写入的记录数
+func (w *Writer) Count() int64 {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.count
+}
+
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err := w.bw.Flush(); err != nil {
+		w.wc.Close()
+		return fmt.Errorf("failed to flush %s: %v", w.name, err)
+	}
+	return w.wc.Close()
+}
@@ -0,0 +1,74 @@
+package pipeline
+
+import (
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Progress 统计读入、处理完成、写出的记录数，按固定间隔打印一行进度
+type Progress struct {
+	label    string
+	interval time.Duration
+	start    time.Time
+	read     int64
+	done     int64
+	written  int64
+	stop     chan struct{}
+	once     sync.Once
+	wg       sync.WaitGroup
+}
+
+// StartProgress 开始计数；interval 为 0 时只计数不打印
+func StartProgress(label string, interval time.Duration) *Progress {
+	p := &Progress{label: label, interval: interval, start: time.Now(), stop: make(chan struct{})}
+	if interval > 0 {
+		p.wg.Add(1)
+		go func() {
+			defer p.wg.Done()
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ticker.C:
+					p.print("⏳")
+				case <-p.stop:
+					return
+				}
+			}
+		}()
+	}
+	return p
+}
+
+func (p *Progress) AddRead()    { atomic.AddInt64(&p.read, 1) }
+func (p *Progress) AddDone()    { atomic.AddInt64(&p.done, 1) }
+func (p *Progress) AddWritten() { atomic.AddInt64(&p.written, 1) }
+
+// Done 返回处理完成的记录数
+func (p *Progress) Done() int64 {
+	return atomic.LoadInt64(&p.done)
+}
+
+// Stop 停止定时打印并输出最终结果（没有名称且不打印进度时不输出），可以多次调用
+func (p *Progress) Stop() {
+	p.once.Do(func() {
+		close(p.stop)
+		p.wg.Wait()
+		if p.label != "" || p.interval > 0 {
+			p.print("✅")
+		}
+	})
+}
+
+func (p *Progress) print(mark string) {
+	elapsed := time.Since(p.start)
+	done := atomic.LoadInt64(&p.done)
+	rate := 0.0
+	if elapsed > 0 {
+		rate = float64(done) / elapsed.Seconds()
+	}
+	log.Printf("%s [%s] read %d, processed %d, written %d (%.1f/s, %s)",
+		mark, p.label, atomic.LoadInt64(&p.read), done, atomic.LoadInt64(&p.written), rate, elapsed.Round(time.Second))
+}
@@ -0,0 +1,164 @@
+package pipeline
+
+import (
+	"io"
+	"log"
+	"sync"
+	"time"
+)
+
+// 流式处理：一个 goroutine 读取输入，Workers 个 goroutine 并发处理，
+// 结果统一交给一个 goroutine 写出，可选按输入顺序输出
+
+type Options struct {
+	Workers  int           // 并发处理数，小于 1 时按 1
+	Ordered  bool          // Map 的输出是否保持输入顺序
+	Label    string        // 进度日志中的名称
+	Progress time.Duration // 进度打印间隔，0 表示不打印
+}
+
+func (o Options) workers() int {
+	if o.Workers < 1 {
+		return 1
+	}
+	return o.Workers
+}
+
+// ForEach 对 input 中的每条记录并发调用 fn，没有输出
+func ForEach[T any](input string, opts Options, fn func(T)) error {
+	r, err := NewReader[T](input)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	progress := StartProgress(opts.Label, opts.Progress)
+	defer progress.Stop()
+
+	jobs := make(chan T, opts.workers())
+	var wg sync.WaitGroup
+	for i := 0; i < opts.workers(); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for v := range jobs {
+				fn(v)
+				progress.AddDone()
+			}
+		}()
+	}
+
+	readErr := feed(r, progress, func(_ int, v T) { jobs <- v })
+	close(jobs)
+	wg.Wait()
+	return readErr
+}
+
+// Map 对 input 中的每条记录并发调用 fn，fn 返回 ok 为 true 的结果写入 w。
+// 写入只在一个 goroutine 中进行；Ordered 时先到的结果等前面的结果写完再写，
+// 同时在途的记录数有上限，慢记录不会让缓存无限增长。写入失败只记录日志，返回第一个错误
+func Map[T, R any](input string, w *Writer, opts Options, fn func(T) (R, bool)) error {
+	r, err := NewReader[T](input)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	progress := StartProgress(opts.Label, opts.Progress)
+	defer progress.Stop()
+
+	type job struct {
+		seq int
+		v   T
+	}
+	type result struct {
+		seq int
+		v   R
+		ok  bool
+	}
+
+	workers := opts.workers()
+	jobs := make(chan job, workers)
+	results := make(chan result, workers)
+	window := make(chan struct{}, workers*16) // 在途（已读入、未写出）的记录数
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				v, ok := fn(j.v)
+				progress.AddDone()
+				results <- result{seq: j.seq, v: v, ok: ok}
+			}
+		}()
+	}
+
+	var writeErr error
+	writerDone := make(chan struct{})
+	go func() {
+		defer close(writerDone)
+		emit := func(res result) {
+			<-window
+			if !res.ok {
+				return
+			}
+			if err := w.Write(res.v); err != nil {
+				log.Printf("❌ %v", err)
+				if writeErr == nil {
+					writeErr = err
+				}
+				return
+			}
+			progress.AddWritten()
+		}
+		pending := make(map[int]result)
+		next := 0
+		for res := range results {
+			if !opts.Ordered {
+				emit(res)
+				continue
+			}
+			pending[res.seq] = res
+			for {
+				p, found := pending[next]
+				if !found {
+					break
+				}
+				delete(pending, next)
+				emit(p)
+				next++
+			}
+		}
+	}()
+
+	readErr := feed(r, progress, func(seq int, v T) {
+		window <- struct{}{}
+		jobs <- job{seq: seq, v: v}
+	})
+	close(jobs)
+	wg.Wait()
+	close(results)
+	<-writerDone
+
+	if readErr != nil {
+		return readErr
+	}
+	return writeErr
+}
+
+// 读出所有记录交给 dispatch，seq 从 0 开始连续编号
+func feed[T any](r *Reader[T], progress *Progress, dispatch func(int, T)) error {
+	for seq := 0; ; seq++ {
+		v, err := r.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		progress.AddRead()
+		dispatch(seq, v)
+	}
+}
@@ -0,0 +1,89 @@
+package pipeline
+
+import (
+	"math/rand"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+)
+
+// 每条记录随机延迟处理，Ordered 时输出顺序必须与输入一致，fn 返回 false 的记录不输出
+func TestMapOrder(t *testing.T) {
+	const n = 300
+	for _, ordered := range []bool{true, false} {
+		dir := t.TempDir()
+		input := filepath.Join(dir, "in.jsonl")
+		if err := writeRecords(t, input, false, 0, n).Close(); err != nil {
+			t.Fatal(err)
+		}
+		output := filepath.Join(dir, "out.jsonl")
+		w, err := NewWriter(output, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = Map(input, w, Options{Workers: 8, Ordered: ordered}, func(r testRecord) (testRecord, bool) {
+			time.Sleep(time.Duration(rand.Intn(2000)) * time.Microsecond)
+			return r, r.N%3 != 0
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		var got []int
+		if err := ReadAll(output, func(r testRecord) error {
+			got = append(got, r.N)
+			return nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+		var want []int
+		for i := 0; i < n; i++ {
+			if i%3 != 0 {
+				want = append(want, i)
+			}
+		}
+		if !ordered {
+			sort.Ints(got)
+		}
+		if len(got) != len(want) {
+			t.Fatalf("ordered=%v: got %d records, want %d", ordered, len(got), len(want))
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Fatalf("ordered=%v: record %d = %d, want %d", ordered, i, got[i], want[i])
+			}
+		}
+	}
+}
+
+// 在途窗口满时读取会阻塞，第一条记录很慢也不能死锁或让输出乱序
+func TestMapOrderSlowHead(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "in.jsonl.gz")
+	const n = 100 // 大于 Workers*16
+	if err := writeRecords(t, input, false, 0, n).Close(); err != nil {
+		t.Fatal(err)
+	}
+	output := filepath.Join(dir, "out.jsonl.zst")
+	w, err := NewWriter(output, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = Map(input, w, Options{Workers: 2, Ordered: true}, func(r testRecord) (testRecord, bool) {
+		if r.N == 0 {
+			time.Sleep(50 * time.Millisecond)
+		}
+		return r, true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	checkRecords(t, output, n)
+}
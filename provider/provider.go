@@ -0,0 +1,292 @@
+package provider
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"scan-website/config"
+	"scan-website/models"
+	"scan-website/utils"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/beevik/etree"
+)
+
+// 邮件服务商识别：用规则文件把 MX、配置中的服务器主机名、SPF include、证书主题/颁发者
+// 以及 Autodiscover 的 MicrosoftOnline 标记对应到服务商，按信号加权打分
+
+// Rule 一个服务商的识别规则；主机名类字段按后缀匹配，证书字段按子串匹配（均不区分大小写）
+type Rule struct {
+	Name            string   `json:"name"`
+	MX              []string `json:"mx"`
+	Hosts           []string `json:"hosts"` // SRV 目标、Autodiscover/Autoconfig 服务器、GUESS 主机
+	SPF             []string `json:"spf"`
+	CertSubjects    []string `json:"cert_subjects"`
+	CertIssuers     []string `json:"cert_issuers"`
+	MicrosoftOnline bool     `json:"microsoft_online"` // Autodiscover 返回 <MicrosoftOnline>True</MicrosoftOnline>
+}
+
+type Rules struct {
+	Providers []Rule `json:"providers"`
+}
+
+// 各信号的权重，MX 最能说明收信服务由谁提供
+var signalWeights = map[string]int{
+	"mx":               4,
+	"microsoft_online": 3,
+	"host":             2,
+	"spf":              2,
+	"cert_subject":     1,
+	"cert_issuer":      1,
+}
+
+// LoadRules 读取规则文件
+func LoadRules(fileName string) (*Rules, error) {
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read provider rules: %v", err)
+	}
+	var rules Rules
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("failed to parse provider rules %s: %v", fileName, err)
+	}
+	return &rules, nil
+}
+
+var (
+	defaultRules     *Rules
+	defaultRulesOnce sync.Once
+)
+
+// DefaultRules 按配置中的 Paths.ProviderRules 加载一次；文件缺失时只打印日志，结果中不标注服务商
+func DefaultRules() *Rules {
+	defaultRulesOnce.Do(func() {
+		rules, err := LoadRules(config.Get().Paths.ProviderRules)
+		if err != nil {
+			log.Printf("Provider identification disabled: %v", err)
+			rules = &Rules{}
+		}
+		defaultRules = rules
+	})
+	return defaultRules
+}
+
+func matchSuffix(host string, suffixes []string) bool {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	for _, s := range suffixes {
+		s = strings.ToLower(s)
+		if host == s || strings.HasSuffix(host, "."+s) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchSubstring(text string, patterns []string) bool {
+	text = strings.ToLower(text)
+	for _, p := range patterns {
+		if p != "" && strings.Contains(text, strings.ToLower(p)) {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchMX 返回 MX 主机对应的服务商，未知时返回空
+func (r *Rules) MatchMX(host string) string {
+	for _, p := range r.Providers {
+		if matchSuffix(host, p.MX) {
+			return p.Name
+		}
+	}
+	return ""
+}
+
+// Annotate 查询 SPF 记录并识别服务商，结果写入 result.Provider，同时标注每条 MX 的服务商
+func Annotate(result *models.DomainResult) {
+	rules := DefaultRules()
+	if result.MX != nil {
+		for i := range result.MX.Records {
+			result.MX.Records[i].Provider = rules.MatchMX(result.MX.Records[i].Host)
+		}
+	}
+	includes, err := LookupSPFIncludes(result.Domain)
+	if err != nil {
+		result.ErrorMessages = append(result.ErrorMessages, fmt.Sprintf("SPF lookup error: %v", err))
+	}
+	result.Provider = rules.Identify(result, includes)
+}
+
+// Identify 收集域名结果中的各类信号并打分，spfIncludes 为 SPF 中 include/redirect 的域名
+func (r *Rules) Identify(result *models.DomainResult, spfIncludes []string) *models.ProviderResult {
+	identified := &models.ProviderResult{SPFIncludes: spfIncludes}
+	seen := make(map[models.ProviderMatch]bool)
+	add := func(provider, signal, value string) {
+		m := models.ProviderMatch{Provider: provider, Signal: signal, Value: value}
+		if !seen[m] {
+			seen[m] = true
+			identified.Matches = append(identified.Matches, m)
+		}
+	}
+
+	if result.MX != nil {
+		for _, mx := range result.MX.Records {
+			for _, p := range r.Providers {
+				if matchSuffix(mx.Host, p.MX) {
+					add(p.Name, "mx", mx.Host)
+				}
+			}
+		}
+	}
+	for _, host := range configHosts(result) {
+		for _, p := range r.Providers {
+			if matchSuffix(host, p.Hosts) {
+				add(p.Name, "host", host)
+			}
+		}
+	}
+	for _, include := range spfIncludes {
+		for _, p := range r.Providers {
+			if matchSuffix(include, p.SPF) {
+				add(p.Name, "spf", include)
+			}
+		}
+	}
+	for _, ci := range configCerts(result) {
+		for _, p := range r.Providers {
+			if ci.Subject != "" && matchSubstring(ci.Subject, p.CertSubjects) {
+				add(p.Name, "cert_subject", ci.Subject)
+			}
+			if ci.Issuer != "" && matchSubstring(ci.Issuer, p.CertIssuers) {
+				add(p.Name, "cert_issuer", ci.Issuer)
+			}
+		}
+	}
+	if microsoftOnline(result) {
+		for _, p := range r.Providers {
+			if p.MicrosoftOnline {
+				add(p.Name, "microsoft_online", "True")
+			}
+		}
+	}
+
+	// 同一服务商同一信号只计一次分，得分相同时按规则文件中的顺序
+	scores := make(map[string]int)
+	counted := make(map[[2]string]bool)
+	for _, m := range identified.Matches {
+		key := [2]string{m.Provider, m.Signal}
+		if !counted[key] {
+			counted[key] = true
+			scores[m.Provider] += signalWeights[m.Signal]
+		}
+	}
+	best := 0
+	for _, p := range r.Providers {
+		if scores[p.Name] > best {
+			best = scores[p.Name]
+			identified.Name = p.Name
+		}
+	}
+	return identified
+}
+
+// 配置中出现的邮件服务器主机名：SRV 目标、Autodiscover <Server>、Autoconfig <hostname>、GUESS
+func configHosts(result *models.DomainResult) []string {
+	var hosts []string
+	for _, records := range [][]models.SRVRecord{result.SRV.RecvRecords, result.SRV.SendRecords, result.SRV.OtherRecords} {
+		for _, r := range records {
+			hosts = append(hosts, r.Target)
+		}
+	}
+	for _, r := range result.Autodiscover {
+		hosts = append(hosts, xmlTexts(r.Config, "//Protocol/Server")...)
+	}
+	for _, r := range result.Autoconfig {
+		hosts = append(hosts, xmlTexts(r.Config, "//incomingServer/hostname")...)
+		hosts = append(hosts, xmlTexts(r.Config, "//outgoingServer/hostname")...)
+	}
+	for _, g := range result.GUESS {
+		if g.Confirmed && !g.ViaWildcard {
+			hosts = append(hosts, g.Host)
+		}
+	}
+	return hosts
+}
+
+// 获取到配置的 Autodiscover/Autoconfig 请求的证书；ISPDB 的证书属于 Thunderbird，跳过
+func configCerts(result *models.DomainResult) []*models.CertInfo {
+	var certs []*models.CertInfo
+	for _, r := range result.Autodiscover {
+		if r.Config != "" && r.CertInfo != nil {
+			certs = append(certs, r.CertInfo)
+		}
+	}
+	for _, r := range result.Autoconfig {
+		if r.Config != "" && r.CertInfo != nil && r.Method != "ISPDB" {
+			certs = append(certs, r.CertInfo)
+		}
+	}
+	return certs
+}
+
+func microsoftOnline(result *models.DomainResult) bool {
+	for _, r := range result.Autodiscover {
+		for _, v := range xmlTexts(r.Config, "//MicrosoftOnline") {
+			if strings.EqualFold(v, "true") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func xmlTexts(config, path string) []string {
+	if config == "" || !strings.HasPrefix(strings.TrimSpace(config), "<") {
+		return nil
+	}
+	doc := etree.NewDocument()
+	if err := doc.ReadFromString(config); err != nil {
+		return nil
+	}
+	var texts []string
+	for _, e := range doc.FindElements(path) {
+		if t := strings.TrimSpace(e.Text()); t != "" {
+			texts = append(texts, t)
+		}
+	}
+	return texts
+}
+
+// LookupSPFIncludes 查询域名的 SPF 记录，返回 include: 和 redirect= 指向的域名（去重排序）
+func LookupSPFIncludes(domain string) ([]string, error) {
+	records, err := utils.LookupTXT(domain)
+	if err != nil {
+		return nil, err
+	}
+	set := make(map[string]struct{})
+	for _, record := range records {
+		fields := strings.Fields(record)
+		if len(fields) == 0 || !strings.EqualFold(fields[0], "v=spf1") {
+			continue
+		}
+		for _, f := range fields[1:] {
+			lower := strings.ToLower(f)
+			// 限定符 +/-/~/? 可以出现在机制前面
+			lower = strings.TrimLeft(lower, "+-~?")
+			if v, ok := strings.CutPrefix(lower, "include:"); ok {
+				set[v] = struct{}{}
+			} else if v, ok := strings.CutPrefix(lower, "redirect="); ok {
+				set[v] = struct{}{}
+			}
+		}
+	}
+	includes := make([]string, 0, len(set))
+	for v := range set {
+		includes = append(includes, v)
+	}
+	sort.Strings(includes)
+	return includes, nil
+}
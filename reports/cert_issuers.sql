@@ -0,0 +1,7 @@
+-- 配置服务器证书的颁发者排名
+SELECT c.issuer, COUNT(DISTINCT p.domain) AS domains
+FROM probes p JOIN certs c ON c.fingerprint = p.cert_fingerprint
+WHERE p.has_config
+GROUP BY c.issuer
+ORDER BY domains DESC
+LIMIT 50;
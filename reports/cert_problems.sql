@@ -0,0 +1,9 @@
+-- 返回配置的请求中证书不受信任、主机名不匹配、已过期的域名数（对应 CountDomains_Certinfo）
+SELECT mechanism,
+	COUNT(DISTINCT domain) AS with_cert,
+	COUNT(DISTINCT CASE WHEN NOT cert_trusted THEN domain END) AS untrusted,
+	COUNT(DISTINCT CASE WHEN NOT cert_hostname_match THEN domain END) AS hostname_mismatch,
+	COUNT(DISTINCT CASE WHEN cert_expired THEN domain END) AS expired
+FROM probes
+WHERE has_config AND cert_fingerprint IS NOT NULL
+GROUP BY mechanism;
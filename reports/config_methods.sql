@@ -0,0 +1,6 @@
+-- 取得配置的域名数按机制和请求方法分布
+SELECT mechanism, method, COUNT(DISTINCT domain) AS domains
+FROM probes
+WHERE has_config AND mechanism IN ('autodiscover', 'autoconfig')
+GROUP BY mechanism, method
+ORDER BY mechanism, domains DESC;
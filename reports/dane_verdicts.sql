@@ -0,0 +1,6 @@
+-- 邮件服务器的 DANE 匹配结果（需要 measurement.CheckDANE）
+SELECT protocol, dane_verdict, COUNT(*) AS hosts, COUNT(DISTINCT domain) AS domains
+FROM connections
+WHERE source = 'dane'
+GROUP BY protocol, dane_verdict
+ORDER BY protocol, hosts DESC;
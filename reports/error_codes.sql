@@ -0,0 +1,6 @@
+-- 请求失败原因分布（Autodiscover ErrorCode、HTTP 状态码、请求失败等）
+SELECT mechanism, error_code, COUNT(*) AS probes, COUNT(DISTINCT domain) AS domains
+FROM probes
+WHERE error_code != ''
+GROUP BY mechanism, error_code
+ORDER BY probes DESC;
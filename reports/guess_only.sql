@@ -0,0 +1,8 @@
+-- 三种机制都没有配置、只能靠猜测得到已确认邮件服务器的域名
+SELECT d.domain, GROUP_CONCAT(DISTINCT c.host || ':' || c.port) AS guessed
+FROM domains d
+JOIN connections c ON c.domain = d.domain AND c.source = 'guess' AND c.confirmed AND NOT c.via_wildcard
+WHERE NOT EXISTS (SELECT 1 FROM probes p WHERE p.domain = d.domain AND p.has_config)
+	AND NOT EXISTS (SELECT 1 FROM probes p WHERE p.domain = d.domain AND p.mechanism = 'srv' AND p.error_code IS NOT 'not_offered')
+GROUP BY d.domain
+ORDER BY d.domain;
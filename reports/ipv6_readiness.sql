@@ -0,0 +1,5 @@
+-- 配置目标分地址族的可达性（需要开启 probe_ip_families）
+SELECT family, COUNT(*) AS targets, SUM(reachable) AS reachable, COUNT(DISTINCT domain) AS domains
+FROM connections
+WHERE source = 'family'
+GROUP BY family;
@@ -0,0 +1,12 @@
+-- 各机制取得配置的域名数及组合（对应 CountDomainsWithValidConfig，SRV 以存在记录计）
+WITH m AS (
+	SELECT d.domain,
+		EXISTS (SELECT 1 FROM probes p WHERE p.domain = d.domain AND p.mechanism = 'autodiscover' AND p.has_config) AS autodiscover,
+		EXISTS (SELECT 1 FROM probes p WHERE p.domain = d.domain AND p.mechanism = 'autoconfig' AND p.has_config) AS autoconfig,
+		EXISTS (SELECT 1 FROM probes p WHERE p.domain = d.domain AND p.mechanism = 'srv' AND p.error_code IS NOT 'not_offered') AS srv
+	FROM domains d
+)
+SELECT autodiscover, autoconfig, srv, COUNT(*) AS domains
+FROM m
+GROUP BY autodiscover, autoconfig, srv
+ORDER BY domains DESC;
@@ -0,0 +1,5 @@
+-- 配置中各协议使用的端口和加密方式（需要 measurement.Check 写入 protocols）
+SELECT mechanism, type, port, COALESCE(NULLIF(encryption, ''), ssl) AS encryption, COUNT(DISTINCT domain) AS domains
+FROM protocols
+GROUP BY mechanism, type, port, 4
+ORDER BY mechanism, domains DESC;
@@ -0,0 +1,7 @@
+-- 按邮件服务商统计域名数及其中取得配置的域名数
+SELECT COALESCE(NULLIF(d.provider, ''), '(unknown)') AS provider,
+	COUNT(*) AS domains,
+	SUM(EXISTS (SELECT 1 FROM probes p WHERE p.domain = d.domain AND p.has_config)) AS with_config
+FROM domains d
+GROUP BY 1
+ORDER BY domains DESC;
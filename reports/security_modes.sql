@@ -0,0 +1,5 @@
+-- 各机制给出的设置按协议、端口和 TLS 方式统计（需要 measurement.Check 写入 settings）
+SELECT mechanism, protocol, port, security, COUNT(DISTINCT domain) AS domains
+FROM settings
+GROUP BY mechanism, protocol, port, security
+ORDER BY mechanism, protocol, domains DESC;
@@ -0,0 +1,51 @@
+package store
+
+import (
+	"scan-website/models"
+	"scan-website/pipeline"
+)
+
+// 把已有的 JSONL 结果导入数据库，用于配置 sqlite_db 之前跑过的扫描
+
+const importBatch = 500
+
+// ImportDomainResults 导入 discover.Process 的输出，返回导入的域名数
+func (s *Store) ImportDomainResults(input string) (int, error) {
+	var batch []models.DomainResult
+	total := 0
+	err := pipeline.ReadAll(input, func(r models.DomainResult) error {
+		batch = append(batch, r)
+		if len(batch) < importBatch {
+			return nil
+		}
+		total += len(batch)
+		err := s.AddDomainResults(batch)
+		batch = batch[:0]
+		return err
+	})
+	if err != nil {
+		return total, err
+	}
+	total += len(batch)
+	return total, s.AddDomainResults(batch)
+}
+
+// ImportCheckResults 导入 measurement.Check 的输出
+func (s *Store) ImportCheckResults(input string) (int, error) {
+	total := 0
+	err := pipeline.ReadAll(input, func(r models.DomainCheckResult) error {
+		total++
+		return s.AddCheckResult(&r)
+	})
+	return total, err
+}
+
+// ImportDANEResults 导入 measurement.CheckDANE 的输出
+func (s *Store) ImportDANEResults(input string) (int, error) {
+	total := 0
+	err := pipeline.ReadAll(input, func(r models.DANECheckResult) error {
+		total++
+		return s.AddDANEResult(&r)
+	})
+	return total, err
+}
@@ -0,0 +1,86 @@
+package store
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// 保存的 SQL 报表：报表目录下每个 .sql 文件是一个报表，文件名（去掉后缀）即报表名，
+// 开头的 "-- " 注释行作为说明
+
+type Report struct {
+	Name        string
+	Description string
+	SQL         string
+}
+
+// LoadReports 读取目录下所有报表，按名称排序
+func LoadReports(dir string) ([]Report, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.sql"))
+	if err != nil {
+		return nil, err
+	}
+	var reports []Report
+	for _, f := range files {
+		data, err := os.ReadFile(f)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read report %s: %v", f, err)
+		}
+		r := Report{Name: strings.TrimSuffix(filepath.Base(f), ".sql"), SQL: string(data)}
+		var desc []string
+		scanner := bufio.NewScanner(strings.NewReader(r.SQL))
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			text, ok := strings.CutPrefix(line, "--")
+			if !ok {
+				break
+			}
+			desc = append(desc, strings.TrimSpace(text))
+		}
+		r.Description = strings.Join(desc, " ")
+		reports = append(reports, r)
+	}
+	sort.Slice(reports, func(i, j int) bool { return reports[i].Name < reports[j].Name })
+	return reports, nil
+}
+
+// Query 执行一条查询，返回列名和按文本格式化的各行（NULL 为空串）
+func (s *Store) Query(query string, args ...interface{}) ([]string, [][]string, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("query failed: %v", err)
+	}
+	defer rows.Close()
+
+	cols, err := rows.Columns()
+	if err != nil {
+		return nil, nil, err
+	}
+	var out [][]string
+	values := make([]interface{}, len(cols))
+	ptrs := make([]interface{}, len(cols))
+	for i := range values {
+		ptrs[i] = &values[i]
+	}
+	for rows.Next() {
+		if err := rows.Scan(ptrs...); err != nil {
+			return nil, nil, err
+		}
+		row := make([]string, len(cols))
+		for i, v := range values {
+			switch val := v.(type) {
+			case nil:
+			case []byte:
+				row[i] = string(val)
+			default:
+				row[i] = fmt.Sprint(val)
+			}
+		}
+		out = append(out, row)
+	}
+	return cols, out, rows.Err()
+}
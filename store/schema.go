@@ -0,0 +1,117 @@
+package store
+
+// 表结构。discover.Process 写 domains/probes/configs/certs 和 connections 中 guess/family 的行，
+// measurement.Check 写 protocols 和 settings，measurement.CheckDANE 写 connections 中 dane 的行；
+// 同一域名重新写入时先删除该来源的旧行
+const schema = `
+CREATE TABLE IF NOT EXISTS domains (
+	domain     TEXT PRIMARY KEY,
+	domain_id  INTEGER,
+	timestamp  TEXT,
+	cname      TEXT,    -- 逗号分隔的 CNAME 链
+	wildcard   INTEGER, -- 存在通配符解析
+	provider   TEXT,
+	mx         TEXT,    -- 优先级最高的 MX 主机
+	null_mx    INTEGER,
+	errors     TEXT
+);
+
+CREATE TABLE IF NOT EXISTS probes (
+	domain              TEXT NOT NULL,
+	mechanism           TEXT NOT NULL, -- autodiscover / autoconfig / srv / guess
+	method              TEXT,
+	idx                 INTEGER,
+	uri                 TEXT,
+	host                TEXT,
+	port                INTEGER,
+	final_url           TEXT,
+	redirects           INTEGER,
+	status              INTEGER,
+	error_code          TEXT,
+	error               TEXT,
+	has_config          INTEGER,
+	config_bytes        INTEGER,
+	via_wildcard        INTEGER,
+	cert_fingerprint    TEXT,
+	cert_trusted        INTEGER,
+	cert_hostname_match INTEGER,
+	cert_expired        INTEGER
+);
+CREATE INDEX IF NOT EXISTS probes_domain ON probes(domain);
+CREATE INDEX IF NOT EXISTS probes_mechanism ON probes(mechanism, has_config);
+
+CREATE TABLE IF NOT EXISTS configs (
+	domain    TEXT NOT NULL,
+	mechanism TEXT NOT NULL,
+	method    TEXT,
+	idx       INTEGER,
+	config    TEXT
+);
+CREATE INDEX IF NOT EXISTS configs_domain ON configs(domain);
+
+CREATE TABLE IF NOT EXISTS certs (
+	fingerprint     TEXT PRIMARY KEY, -- leaf 的 SHA-256
+	subject         TEXT,
+	issuer          TEXT,
+	not_before      TEXT,
+	not_after       TEXT,
+	self_signed     INTEGER,
+	public_key_type TEXT,
+	public_key_size INTEGER,
+	signature_alg   TEXT
+);
+
+CREATE TABLE IF NOT EXISTS connections (
+	domain           TEXT NOT NULL,
+	source           TEXT NOT NULL, -- guess / family / dane
+	host             TEXT,
+	port             INTEGER,
+	protocol         TEXT,
+	mode             TEXT,    -- tls / starttls
+	family           TEXT,    -- 4 / 6，只有 family 行有
+	addr             TEXT,
+	reachable        INTEGER,
+	confirmed        INTEGER, -- guess 行：读到协议问候语或完成隐式 TLS 握手
+	via_wildcard     INTEGER, -- guess 行：地址与通配符解析相同
+	tls              INTEGER, -- 完成了 TLS 握手
+	cert_fingerprint TEXT,
+	dane_verdict     TEXT,
+	error            TEXT
+);
+CREATE INDEX IF NOT EXISTS connections_domain ON connections(domain, source);
+
+CREATE TABLE IF NOT EXISTS protocols (
+	domain        TEXT NOT NULL,
+	mechanism     TEXT NOT NULL, -- autodiscover / autoconfig / srv
+	method        TEXT,
+	idx           INTEGER,       -- 同一机制内第几份配置
+	type          TEXT,
+	server        TEXT,
+	port          TEXT,
+	ssl           TEXT,
+	encryption    TEXT,
+	single_check  TEXT,
+	overall_check TEXT
+);
+CREATE INDEX IF NOT EXISTS protocols_domain ON protocols(domain);
+
+CREATE TABLE IF NOT EXISTS settings (
+	domain    TEXT NOT NULL,
+	mechanism TEXT NOT NULL, -- autodiscover / autoconfig / srv
+	idx       INTEGER,
+	protocol  TEXT,          -- imap / pop3 / smtp
+	role      TEXT,          -- incoming / outgoing
+	host      TEXT,
+	port      INTEGER,
+	security  TEXT,          -- plain / starttls / tls / auto
+	auth      TEXT,          -- 逗号分隔的认证方式
+	username  TEXT
+);
+CREATE INDEX IF NOT EXISTS settings_domain ON settings(domain);
+`
+
+// 旧库建表时还没有的列，打开时补上
+var addedColumns = []struct{ table, column, typ string }{
+	{"connections", "confirmed", "INTEGER"},
+	{"connections", "via_wildcard", "INTEGER"},
+}
@@ -0,0 +1,319 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"scan-website/config"
+	"scan-website/export"
+	"scan-website/models"
+	"strings"
+	"sync"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// 可选的 SQLite 结果库：扫描和 measurement 各轮在写 JSONL 的同时把结果拆成规范化的表，
+// 统计可以直接写 SQL（见 reports/ 和 cmd/query）。所有写入在一个连接上串行进行
+
+type Store struct {
+	db *sql.DB
+	mu sync.Mutex
+}
+
+// Open 打开（必要时创建）数据库并建表
+func Open(path string) (*Store, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open sqlite db %s: %v", path, err)
+	}
+	db.SetMaxOpenConns(1) // SQLite 同时只能有一个写入者
+	for _, pragma := range []string{"PRAGMA journal_mode=WAL", "PRAGMA synchronous=NORMAL", "PRAGMA busy_timeout=10000"} {
+		if _, err := db.Exec(pragma); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("failed to set %s: %v", pragma, err)
+		}
+	}
+	if _, err := db.Exec(schema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create schema: %v", err)
+	}
+	if err := addMissingColumns(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &Store{db: db}, nil
+}
+
+func addMissingColumns(db *sql.DB) error {
+	for _, c := range addedColumns {
+		var n int
+		err := db.QueryRow("SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?", c.table, c.column).Scan(&n)
+		if err != nil {
+			return fmt.Errorf("failed to inspect table %s: %v", c.table, err)
+		}
+		if n > 0 {
+			continue
+		}
+		if _, err := db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", c.table, c.column, c.typ)); err != nil {
+			return fmt.Errorf("failed to add column %s.%s: %v", c.table, c.column, err)
+		}
+	}
+	return nil
+}
+
+// OpenConfigured 按 Paths.SQLiteDB 打开数据库，未配置时返回 nil（nil 的 Store 上各写入方法什么也不做）
+func OpenConfigured() (*Store, error) {
+	path := config.Get().Paths.SQLiteDB
+	if path == "" {
+		return nil, nil
+	}
+	return Open(path)
+}
+
+func (s *Store) Close() error {
+	if s == nil {
+		return nil
+	}
+	return s.db.Close()
+}
+
+// DB 返回底层连接，供查询使用
+func (s *Store) DB() *sql.DB {
+	return s.db
+}
+
+// 在一个事务中执行 fn
+func (s *Store) withTx(fn func(tx *sql.Tx) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit: %v", err)
+	}
+	return nil
+}
+
+// AddDomainResults 写入一批扫描结果：domains、probes、configs、certs，以及 connections 中的 guess/family 行
+func (s *Store) AddDomainResults(results []models.DomainResult) error {
+	if s == nil || len(results) == 0 {
+		return nil
+	}
+	return s.withTx(func(tx *sql.Tx) error {
+		for i := range results {
+			if err := addDomainResult(tx, &results[i]); err != nil {
+				return fmt.Errorf("failed to store %s: %v", results[i].Domain, err)
+			}
+		}
+		return nil
+	})
+}
+
+func addDomainResult(tx *sql.Tx, r *models.DomainResult) error {
+	for _, stmt := range []string{
+		"DELETE FROM probes WHERE domain = ?",
+		"DELETE FROM configs WHERE domain = ?",
+		"DELETE FROM connections WHERE domain = ? AND source IN ('guess', 'family')",
+	} {
+		if _, err := tx.Exec(stmt, r.Domain); err != nil {
+			return err
+		}
+	}
+
+	var provider, mx string
+	var nullMX bool
+	if r.Provider != nil {
+		provider = r.Provider.Name
+	}
+	if r.MX != nil {
+		nullMX = r.MX.NullMX
+		if len(r.MX.Records) > 0 {
+			mx = r.MX.Records[0].Host
+		}
+	}
+	_, err := tx.Exec(`INSERT OR REPLACE INTO domains (domain, domain_id, timestamp, cname, wildcard, provider, mx, null_mx, errors)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		r.Domain, r.Domain_id, r.Timestamp, strings.Join(r.CNAME, ","), r.Wildcard != nil, provider, mx, nullMX, strings.Join(r.ErrorMessages, "\n"))
+	if err != nil {
+		return err
+	}
+
+	for _, p := range export.ProbeRows(r) {
+		_, err := tx.Exec(`INSERT INTO probes (domain, mechanism, method, idx, uri, host, port, final_url, redirects, status,
+			error_code, error, has_config, config_bytes, via_wildcard, cert_fingerprint, cert_trusted, cert_hostname_match, cert_expired)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			p.Domain, p.Mechanism, p.Method, p.Index, p.URI, p.Host, p.Port, p.FinalURL, p.Redirects, p.Status,
+			p.ErrorCode, p.Error, p.HasConfig, p.ConfigBytes, p.ViaWildcard, nullable(p.CertFingerprint), p.CertTrusted, p.CertHostMatch, p.CertExpired)
+		if err != nil {
+			return err
+		}
+	}
+
+	addConfig := func(mechanism, method string, idx int, cfg string) error {
+		if !strings.HasPrefix(strings.TrimSpace(cfg), "<") {
+			return nil
+		}
+		_, err := tx.Exec("INSERT INTO configs (domain, mechanism, method, idx, config) VALUES (?, ?, ?, ?, ?)",
+			r.Domain, mechanism, method, idx, cfg)
+		return err
+	}
+	for _, a := range r.Autodiscover {
+		if err := addConfig("autodiscover", a.Method, a.Index, a.Config); err != nil {
+			return err
+		}
+		if err := addCert(tx, a.CertInfo); err != nil {
+			return err
+		}
+	}
+	for _, a := range r.Autoconfig {
+		if err := addConfig("autoconfig", a.Method, a.Index, a.Config); err != nil {
+			return err
+		}
+		if err := addCert(tx, a.CertInfo); err != nil {
+			return err
+		}
+	}
+
+	for _, g := range r.GUESS {
+		addr := ""
+		if len(g.IPs) > 0 {
+			addr = g.IPs[0]
+		}
+		// TLS 只在隐式 TLS 握手成功后才会设置，这里再与 Confirmed 一起判断，避免把端口类型当作握手结果
+		_, err := tx.Exec(`INSERT INTO connections (domain, source, host, port, protocol, addr, reachable, confirmed, via_wildcard, tls, error)
+			VALUES (?, 'guess', ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			r.Domain, g.Host, g.Port, g.Protocol, addr, g.Reach, g.Confirmed, g.ViaWildcard, g.TLS && g.Confirmed, g.Error)
+		if err != nil {
+			return err
+		}
+	}
+	for _, t := range r.Targets {
+		for _, f := range []struct {
+			family string
+			probe  *models.FamilyProbe
+		}{{"4", t.IPv4}, {"6", t.IPv6}} {
+			if f.probe == nil {
+				continue
+			}
+			_, err := tx.Exec(`INSERT INTO connections (domain, source, host, port, protocol, family, addr, reachable, error)
+				VALUES (?, 'family', ?, ?, ?, ?, ?, ?, ?)`,
+				r.Domain, t.Host, t.Port, strings.Join(t.Sources, ","), f.family, f.probe.Addr, f.probe.Reachable, f.probe.Error)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// 证书按 leaf 指纹去重，只保存与连接无关的属性
+func addCert(tx *sql.Tx, ci *models.CertInfo) error {
+	if ci == nil || ci.Fingerprint == "" {
+		return nil
+	}
+	_, err := tx.Exec(`INSERT OR IGNORE INTO certs (fingerprint, subject, issuer, not_before, not_after, self_signed, public_key_type, public_key_size, signature_alg)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		ci.Fingerprint, ci.Subject, ci.Issuer, formatTime(ci.NotBefore), formatTime(ci.NotAfter), ci.IsSelfSigned, ci.PublicKeyType, ci.PublicKeySize, ci.SignatureAlg)
+	return err
+}
+
+// AddCheckResult 写入 measurement.Check 解析出的各机制协议配置和规范化后的设置
+func (s *Store) AddCheckResult(r *models.DomainCheckResult) error {
+	if s == nil || r == nil {
+		return nil
+	}
+	return s.withTx(func(tx *sql.Tx) error {
+		for _, stmt := range []string{"DELETE FROM protocols WHERE domain = ?", "DELETE FROM settings WHERE domain = ?"} {
+			if _, err := tx.Exec(stmt, r.Domain); err != nil {
+				return err
+			}
+		}
+		add := func(mechanism string, idx int, m *models.MethodConfig) error {
+			if m == nil {
+				return nil
+			}
+			for _, p := range m.Protocols {
+				_, err := tx.Exec(`INSERT INTO protocols (domain, mechanism, method, idx, type, server, port, ssl, encryption, single_check, overall_check)
+					VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+					r.Domain, mechanism, m.Method, idx, p.Type, p.Server, p.Port, p.SSL, p.Encryption, p.SingleCheck, m.OverallCheck)
+				if err != nil {
+					return err
+				}
+			}
+			for _, st := range m.Settings {
+				_, err := tx.Exec(`INSERT INTO settings (domain, mechanism, idx, protocol, role, host, port, security, auth, username)
+					VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+					r.Domain, mechanism, idx, st.Protocol, st.Role, st.Host, st.Port, st.Security, strings.Join(st.Auth, ","), st.Username)
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		for i, m := range r.AutodiscoverCheckResult {
+			if err := add("autodiscover", i, m); err != nil {
+				return err
+			}
+		}
+		for i, m := range r.AutoconfigCheckResult {
+			if err := add("autoconfig", i, m); err != nil {
+				return err
+			}
+		}
+		return add("srv", 0, r.SRVCheckResult)
+	})
+}
+
+// AddDANEResult 写入 measurement.CheckDANE 对各邮件服务器的连接结果
+func (s *Store) AddDANEResult(r *models.DANECheckResult) error {
+	if s == nil || r == nil {
+		return nil
+	}
+	return s.withTx(func(tx *sql.Tx) error {
+		if _, err := tx.Exec("DELETE FROM connections WHERE domain = ? AND source = 'dane'", r.Domain); err != nil {
+			return err
+		}
+		for _, h := range r.Hosts {
+			var fingerprint, verdict string
+			if h.CertInfo != nil {
+				fingerprint = h.CertInfo.Fingerprint
+				if err := addCert(tx, h.CertInfo); err != nil {
+					return err
+				}
+			}
+			if h.DANE != nil {
+				verdict = h.DANE.Verdict
+			}
+			_, err := tx.Exec(`INSERT INTO connections (domain, source, host, port, protocol, mode, reachable, tls, cert_fingerprint, dane_verdict, error)
+				VALUES (?, 'dane', ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+				r.Domain, h.Host, h.Port, h.Protocol, h.Mode, h.CertInfo != nil || h.Error == "", h.CertInfo != nil,
+				nullable(fingerprint), verdict, h.Error)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// 空字符串存为 NULL，便于 JOIN certs
+func nullable(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
@@ -4,56 +4,30 @@ import (
 	"bytes"
 	"crypto/dsa"
 	"crypto/ecdsa"
+	"crypto/ed25519"
 	"crypto/rsa"
 	"crypto/x509"
 	"fmt"
-	"os"
+	"scan-website/models"
 	"strconv"
-
-	"github.com/zakjan/cert-chain-resolver/certUtil"
+	"strings"
+	"time"
 )
 
 // 证书解析验证相关函数
 func VerifyCertificate(chain []*x509.Certificate, domain string) (bool, error) {
-	if len(chain) == 1 {
-		temp_chain, err := certUtil.FetchCertificateChain(chain[0])
-		if err != nil {
-			//log.Println("failed to fetch certificate chain")
-			return false, fmt.Errorf("failed to fetch certificate chain:%v", err)
-		}
-		chain = temp_chain
-	}
-
-	intermediates := x509.NewCertPool()
-	for i := 1; i < len(chain); i++ {
-		intermediates.AddCert(chain[i])
-	}
+	trustedBy, err := VerifyCertificateWithStores(chain, domain)
+	return len(trustedBy) > 0, err
+}
 
-	certPool := x509.NewCertPool()
-	pemFile := "/home/wzq/scan-website/IncludedRootsPEM313.txt" //修改获取roots的途径
-	pem, err := os.ReadFile(pemFile)
+// 使用默认验证器验证，返回信任该证书链的信任库名称
+func VerifyCertificateWithStores(chain []*x509.Certificate, domain string) ([]string, error) {
+	verifier, err := DefaultVerifier()
 	if err != nil {
-		//log.Println("failed to read root certificate")
-		return false, fmt.Errorf("failed to read root certificate:%v", err)
-	}
-	ok := certPool.AppendCertsFromPEM(pem)
-	if !ok {
-		//log.Println("failed to import root certificate")
-		return false, fmt.Errorf("failed to import root certificate:%v", err)
-	}
-
-	opts := x509.VerifyOptions{
-		Roots:         certPool,
-		Intermediates: intermediates,
-		DNSName:       domain,
-	}
-
-	if _, err := chain[0].Verify(opts); err != nil {
-		//fmt.Println(err)
-		return false, fmt.Errorf("certificate verify failed: %v", err)
+		return nil, err
 	}
-
-	return true, nil
+	result := verifier.Verify(chain, domain)
+	return result.TrustedBy, result.Error()
 }
 
 func VerifyHostname(cert *x509.Certificate, domain string) bool {
@@ -69,38 +43,137 @@ func IsSelfSigned(cert *x509.Certificate) bool {
 }
 
 // Ref to: https://github.com/google/certificate-transparency-go/blob/master/ctutil/sctcheck/sctcheck.go
+// 返回 AnalyzeChain 的 Order：empty / single / yes / not / no_issuer
 func IsChainInOrder(chain []*x509.Certificate) string {
-	// var issuer *x509.Certificate
+	return AnalyzeChain(chain).Order
+}
+
+// issuedBy 判断 child 是否由 parent 签发
+func issuedBy(child, parent *x509.Certificate) bool {
+	return bytes.Equal(child.RawIssuer, parent.RawSubject) && parent.CheckSignature(child.SignatureAlgorithm, child.RawTBSCertificate, child.Signature) == nil
+}
+
+// AnalyzeChain 分析服务端发送的证书链结构：leaf 是否存在、顺序、缺失的中间证书、
+// 多余或无关的证书、是否发送了根证书、重复证书以及交叉签名。
+// 不借助信任库时，只有路径停在 leaf 上才算缺少中间证书
+func AnalyzeChain(chain []*x509.Certificate) *models.ChainReport {
+	return AnalyzeChainWithRoots(chain, nil)
+}
+
+// AnalyzeChainWithRoots 用 issuedByRoot 判断路径末端的证书是否直接由受信根签发，
+// 不是则认为缺少中间证书
+func AnalyzeChainWithRoots(chain []*x509.Certificate, issuedByRoot func(*x509.Certificate) bool) *models.ChainReport {
+	report := &models.ChainReport{Length: len(chain)}
+	if len(chain) == 0 {
+		report.Order = "empty"
+		return report
+	}
 	leaf := chain[0]
-	for i := 1; i < len(chain); i++ {
-		c := chain[i]
-		if bytes.Equal(c.RawSubject, leaf.RawIssuer) && c.CheckSignature(leaf.SignatureAlgorithm, leaf.RawTBSCertificate, leaf.Signature) == nil {
-			// issuer = c
-			if i > 1 {
-				return "not"
+	report.LeafPresent = !(leaf.BasicConstraintsValid && leaf.IsCA)
+
+	// 重复证书只保留第一次出现的位置
+	seen := make(map[string]int)
+	var unique []int
+	for i, c := range chain {
+		fp := certFingerprint(c)
+		if _, ok := seen[fp]; ok {
+			report.Duplicates = append(report.Duplicates, i)
+			continue
+		}
+		seen[fp] = i
+		unique = append(unique, i)
+	}
+
+	// 从 leaf 出发沿颁发关系向上走，优先选择紧邻的下一张
+	report.Path = []int{0}
+	onPath := map[int]bool{0: true}
+	cur := 0
+	for {
+		if IsSelfSigned(chain[cur]) {
+			report.RootSent = cur != 0 || !report.LeafPresent
+			break
+		}
+		next := -1
+		candidates := 0
+		for _, j := range unique {
+			if onPath[j] || !issuedBy(chain[cur], chain[j]) {
+				continue
+			}
+			candidates++
+			if next == -1 || j == cur+1 {
+				next = j
+			}
+		}
+		if candidates > 1 {
+			report.CrossSigned = true
+		}
+		if next == -1 {
+			if issuedByRoot != nil {
+				report.MissingIntermediate = !issuedByRoot(chain[cur])
+			} else {
+				report.MissingIntermediate = cur == 0
 			}
 			break
 		}
+		report.Path = append(report.Path, next)
+		onPath[next] = true
+		cur = next
 	}
-	if len(chain) < 1 {
-		return "single"
+
+	for _, i := range unique {
+		if !onPath[i] {
+			report.ExtraCerts = append(report.ExtraCerts, i)
+		}
 	}
-	return "yes"
+
+	// 同一主体和公钥出现在多张由不同颁发者签发的证书中
+	for x := 0; x < len(unique) && !report.CrossSigned; x++ {
+		for y := x + 1; y < len(unique); y++ {
+			a, b := chain[unique[x]], chain[unique[y]]
+			if bytes.Equal(a.RawSubject, b.RawSubject) && bytes.Equal(a.RawSubjectPublicKeyInfo, b.RawSubjectPublicKeyInfo) && !bytes.Equal(a.RawIssuer, b.RawIssuer) {
+				report.CrossSigned = true
+				break
+			}
+		}
+	}
+
+	report.InOrder = true
+	for i, idx := range report.Path {
+		if idx != i {
+			report.InOrder = false
+			break
+		}
+	}
+	switch {
+	case len(chain) == 1:
+		report.Order = "single"
+	case len(report.Path) == 1 && report.MissingIntermediate:
+		report.Order = "no_issuer"
+	case report.InOrder:
+		report.Order = "yes"
+	default:
+		report.Order = "not"
+	}
+	return report
 }
 
 var algoName = [...]string{
-	x509.MD2WithRSA:      "MD2-RSA",
-	x509.MD5WithRSA:      "MD5-RSA",
-	x509.SHA1WithRSA:     "SHA1-RSA",
-	x509.SHA256WithRSA:   "SHA256-RSA",
-	x509.SHA384WithRSA:   "SHA384-RSA",
-	x509.SHA512WithRSA:   "SHA512-RSA",
-	x509.DSAWithSHA1:     "DSA-SHA1",
-	x509.DSAWithSHA256:   "DSA-SHA256",
-	x509.ECDSAWithSHA1:   "ECDSA-SHA1",
-	x509.ECDSAWithSHA256: "ECDSA-SHA256",
-	x509.ECDSAWithSHA384: "ECDSA-SHA384",
-	x509.ECDSAWithSHA512: "ECDSA-SHA512",
+	x509.MD2WithRSA:       "MD2-RSA",
+	x509.MD5WithRSA:       "MD5-RSA",
+	x509.SHA1WithRSA:      "SHA1-RSA",
+	x509.SHA256WithRSA:    "SHA256-RSA",
+	x509.SHA384WithRSA:    "SHA384-RSA",
+	x509.SHA512WithRSA:    "SHA512-RSA",
+	x509.DSAWithSHA1:      "DSA-SHA1",
+	x509.DSAWithSHA256:    "DSA-SHA256",
+	x509.ECDSAWithSHA1:    "ECDSA-SHA1",
+	x509.ECDSAWithSHA256:  "ECDSA-SHA256",
+	x509.ECDSAWithSHA384:  "ECDSA-SHA384",
+	x509.ECDSAWithSHA512:  "ECDSA-SHA512",
+	x509.SHA256WithRSAPSS: "SHA256-RSAPSS",
+	x509.SHA384WithRSAPSS: "SHA384-RSAPSS",
+	x509.SHA512WithRSAPSS: "SHA512-RSAPSS",
+	x509.PureEd25519:      "Ed25519",
 }
 
 var badSignatureAlgorithms = [...]x509.SignatureAlgorithm{
@@ -111,33 +184,93 @@ var badSignatureAlgorithms = [...]x509.SignatureAlgorithm{
 	x509.ECDSAWithSHA1,
 }
 
-func AlgWarnings(cert *x509.Certificate) (warning string) {
-	alg, size := decodeKey(cert.PublicKey)
-	if (alg == "RSA" || alg == "DSA") && size < 2048 {
-		// warnings = append(warnings, fmt.Sprintf("Size of %s key should be at least 2048 bits", alg))
-		warning = fmt.Sprintf("Size of %s key should be at least 2048 bits", alg)
-	}
-	if alg == "ECDSA" && size < 224 {
-		warning = fmt.Sprintf("Size of %s key should be at least 224 bits", alg)
+// CA/B Forum 对 2020-09-01 之后签发的 TLS 证书要求有效期不超过 398 天
+var maxValidityStart = time.Date(2020, 9, 1, 0, 0, 0, 0, time.UTC)
+
+const maxValidityDays = 398
+
+// AlgWarnings 检查整条链，返回所有告警：弱 RSA/DSA 密钥、弱曲线、链上任意位置的
+// SHA-1/MD5 签名，以及 leaf 的有效期、SAN、KeyUsage/EKU 问题
+func AlgWarnings(chain []*x509.Certificate) []models.CertWarning {
+	var warnings []models.CertWarning
+	add := func(index int, code, format string, args ...interface{}) {
+		warnings = append(warnings, models.CertWarning{CertIndex: index, Code: code, Message: fmt.Sprintf(format, args...)})
 	}
 
-	for _, alg := range badSignatureAlgorithms {
-		if cert.SignatureAlgorithm == alg {
-			warning = fmt.Sprintf("Signed with %s, which is an outdated signature algorithm", algString(alg))
+	for i, cert := range chain {
+		alg, size := decodeKey(cert.PublicKey)
+		if (alg == "RSA" || alg == "DSA") && size < 2048 {
+			add(i, "weak_key", "Size of %s key should be at least 2048 bits", alg)
+		}
+		if alg == "DSA" {
+			add(i, "dsa_key", "DSA keys are no longer accepted for TLS certificates")
+		}
+		if alg == "ECDSA" && size < 256 {
+			add(i, "weak_curve", "Curve of %s key should be at least P-256, got %d bits", alg, size)
+		}
+		if alg == "RSA" {
+			key := cert.PublicKey.(*rsa.PublicKey)
+			if key.E < 3 {
+				add(i, "rsa_exponent", "Public key exponent in RSA key is less than 3")
+			}
+			if key.N.Sign() != 1 {
+				add(i, "rsa_modulus", "Public key modulus in RSA key appears to be zero/negative")
+			}
+		}
+
+		// 根证书的自签名不参与验证，不算
+		if !(i > 0 && IsSelfSigned(cert)) {
+			for _, bad := range badSignatureAlgorithms {
+				if cert.SignatureAlgorithm == bad {
+					add(i, "weak_signature", "Signed with %s, which is an outdated signature algorithm", algString(bad))
+				}
+			}
 		}
 	}
 
-	if alg == "RSA" {
-		key := cert.PublicKey.(*rsa.PublicKey)
-		if key.E < 3 {
-			warning = "Public key exponent in RSA key is less than 3"
+	if len(chain) == 0 {
+		return warnings
+	}
+	leaf := chain[0]
+	if !leaf.NotBefore.Before(maxValidityStart) {
+		if days := int(leaf.NotAfter.Sub(leaf.NotBefore).Hours() / 24); days > maxValidityDays {
+			add(0, "long_validity", "Validity period of %d days exceeds %d days", days, maxValidityDays)
 		}
-		if key.N.Sign() != 1 {
-			warning = "Public key modulus in RSA key appears to be zero/negative"
+	}
+	if len(leaf.DNSNames) == 0 && len(leaf.IPAddresses) == 0 {
+		add(0, "missing_san", "Certificate has no subjectAltName, hostname is only in CommonName")
+	}
+	if leaf.KeyUsage != 0 {
+		alg, _ := decodeKey(leaf.PublicKey)
+		ok := leaf.KeyUsage&x509.KeyUsageDigitalSignature != 0
+		if alg == "RSA" {
+			ok = ok || leaf.KeyUsage&x509.KeyUsageKeyEncipherment != 0
+		}
+		if !ok {
+			add(0, "key_usage", "Key usage does not allow TLS server authentication")
 		}
 	}
+	if len(leaf.ExtKeyUsage) > 0 || len(leaf.UnknownExtKeyUsage) > 0 {
+		serverAuth := false
+		for _, eku := range leaf.ExtKeyUsage {
+			if eku == x509.ExtKeyUsageServerAuth || eku == x509.ExtKeyUsageAny {
+				serverAuth = true
+			}
+		}
+		if !serverAuth {
+			add(0, "ext_key_usage", "Extended key usage does not include TLS server authentication")
+		}
+	}
+	return warnings
+}
 
-	return warning
+// AlgWarningString 把告警拼成一条字符串，用于兼容旧的 AlgWarning 字段
+func AlgWarningString(warnings []models.CertWarning) string {
+	var msgs []string
+	for _, w := range warnings {
+		msgs = append(msgs, w.Message)
+	}
+	return strings.Join(msgs, "; ")
 }
 
 // decodeKey returns the algorithm and key size for a public key.
@@ -149,6 +282,8 @@ func decodeKey(publicKey interface{}) (string, int) {
 		return "ECDSA", publicKey.(*ecdsa.PublicKey).Curve.Params().BitSize
 	case *rsa.PublicKey:
 		return "RSA", publicKey.(*rsa.PublicKey).N.BitLen()
+	case ed25519.PublicKey:
+		return "Ed25519", 256
 	default:
 		return "", 0
 	}
@@ -0,0 +1,212 @@
+package utils
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"scan-website/models"
+	"strings"
+	"time"
+)
+
+var (
+	oidSCTList    = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 11129, 2, 4, 2}
+	oidTLSFeature = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 1, 24}
+)
+
+// CA/B Forum 证书策略 OID，以及仍在使用自有 EV OID 的主要 CA
+var policyValidationType = map[string]string{
+	"2.23.140.1.1":               "EV",
+	"2.23.140.1.2.1":             "DV",
+	"2.23.140.1.2.2":             "OV",
+	"2.23.140.1.2.3":             "IV",
+	"1.3.6.1.4.1.44947.1.1.1":    "DV", // ISRG (Let's Encrypt)
+	"2.16.840.1.114412.2.1":      "EV", // DigiCert
+	"1.3.6.1.4.1.6449.1.2.1.5.1": "EV", // Sectigo/Comodo
+	"1.3.6.1.4.1.4146.1.1":       "EV", // GlobalSign
+	"2.16.840.1.114028.10.1.2":   "EV", // Entrust
+	"2.16.840.1.114413.1.7.23.3": "EV", // GoDaddy
+	"2.16.840.1.114414.1.7.23.3": "EV", // Starfield
+	"2.16.840.1.113733.1.7.23.6": "EV", // Symantec/VeriSign
+	"0.4.0.2042.1.4":             "EV", // ETSI EVCP
+}
+
+// BuildCertInfo 根据 TLS 连接状态提取证书信息，state 为 nil（HTTP）时返回空结构
+func BuildCertInfo(state *tls.ConnectionState, dnsName string) *models.CertInfo {
+	var certInfo models.CertInfo
+	if state == nil || len(state.PeerCertificates) == 0 {
+		return &certInfo
+	}
+	goChain := state.PeerCertificates
+	endCert := goChain[0]
+
+	// 证书验证：同一条链只验证一次，主机名每次单独校验，不匹配时视为不可信
+	verdict := verifyChainCached(goChain)
+	certInfo.TrustedStores = verdict.trustedBy
+	certInfo.VerifyError = verdict.verifyErr
+	hostErr := endCert.VerifyHostname(dnsName)
+	certInfo.IsHostnameMatch = hostErr == nil
+	if dnsName != "" && hostErr != nil {
+		certInfo.TrustedStores = nil
+		if certInfo.VerifyError == "" {
+			certInfo.VerifyError = fmt.Sprintf("certificate verify failed: %v", hostErr)
+		}
+	}
+	certInfo.IsTrusted = len(certInfo.TrustedStores) > 0
+	certInfo.IsExpired = endCert.NotAfter.Before(time.Now())
+	certInfo.IsSelfSigned = IsSelfSigned(endCert)
+	certInfo.ChainReport = verdict.report
+	certInfo.IsInOrder = certInfo.ChainReport.Order
+	certInfo.TLSVersion = state.Version
+	certInfo.TLSSession = BuildTLSSession(state)
+
+	// 提取证书的其他信息
+	certInfo.Subject = endCert.Subject.CommonName
+	certInfo.Issuer = endCert.Issuer.String()
+	certInfo.SignatureAlg = endCert.SignatureAlgorithm.String()
+	certInfo.AlgWarnings = verdict.warnings
+	certInfo.AlgWarning = AlgWarningString(certInfo.AlgWarnings)
+	FillCertDetails(&certInfo, goChain)
+
+	// 有证书库时只保留 ChainFingerprints 作为引用，否则将证书编码为 base64 格式
+	if DefaultCertStore != nil {
+		DefaultCertStore.Put(goChain)
+		return &certInfo
+	}
+	for _, cert := range goChain {
+		certInfo.RawCerts = append(certInfo.RawCerts, base64.StdEncoding.EncodeToString(cert.Raw))
+	}
+	return &certInfo
+}
+
+func verifyChainCached(chain []*x509.Certificate) *chainVerdict {
+	var key strings.Builder
+	for _, c := range chain {
+		key.WriteString(certFingerprint(c))
+		key.WriteByte(',')
+	}
+	if v, ok := chainVerdicts.Load(key.String()); ok {
+		return v.(*chainVerdict)
+	}
+
+	verdict := &chainVerdict{warnings: AlgWarnings(chain)}
+	var verifyErr error
+	verdict.trustedBy, verifyErr = VerifyCertificateWithStores(chain, "")
+	if verifyErr != nil {
+		verdict.verifyErr = verifyErr.Error()
+	}
+	if verifier, err := DefaultVerifier(); err == nil {
+		verdict.report = AnalyzeChainWithRoots(chain, verifier.IssuedByRoot)
+	} else {
+		verdict.report = AnalyzeChain(chain)
+	}
+	v, _ := chainVerdicts.LoadOrStore(key.String(), verdict)
+	return v.(*chainVerdict)
+}
+
+// FillCertDetails 填充有效期、SAN、公钥、指纹、SCT、吊销地址和验证类型等字段
+func FillCertDetails(certInfo *models.CertInfo, chain []*x509.Certificate) {
+	if len(chain) == 0 {
+		return
+	}
+	leaf := chain[0]
+	certInfo.NotBefore = leaf.NotBefore
+	certInfo.NotAfter = leaf.NotAfter
+	certInfo.DaysToExpiry = int(math.Floor(time.Until(leaf.NotAfter).Hours() / 24))
+	certInfo.SANs = certSANs(leaf)
+	certInfo.PublicKeyType, certInfo.PublicKeySize = decodeKey(leaf.PublicKey)
+	certInfo.Fingerprint = certFingerprint(leaf)
+	certInfo.ChainFingerprints = nil
+	for _, c := range chain {
+		certInfo.ChainFingerprints = append(certInfo.ChainFingerprints, certFingerprint(c))
+	}
+	certInfo.SCTCount = embeddedSCTCount(leaf)
+	certInfo.OCSPServers = leaf.OCSPServer
+	certInfo.CRLDistributionPoints = leaf.CRLDistributionPoints
+	certInfo.MustStaple = isMustStaple(leaf)
+	certInfo.ValidationType = ValidationType(leaf)
+}
+
+// 完整的 SAN 列表（DNS、IP、邮箱、URI）
+func certSANs(cert *x509.Certificate) []string {
+	var sans []string
+	sans = append(sans, cert.DNSNames...)
+	for _, ip := range cert.IPAddresses {
+		sans = append(sans, ip.String())
+	}
+	sans = append(sans, cert.EmailAddresses...)
+	for _, u := range cert.URIs {
+		sans = append(sans, u.String())
+	}
+	return sans
+}
+
+// 证书中嵌入的 SCT 数量（RFC 6962 3.3）
+func embeddedSCTCount(cert *x509.Certificate) int {
+	for _, ext := range cert.Extensions {
+		if !ext.Id.Equal(oidSCTList) {
+			continue
+		}
+		var list []byte
+		if _, err := asn1.Unmarshal(ext.Value, &list); err != nil || len(list) < 2 {
+			return 0
+		}
+		total := int(binary.BigEndian.Uint16(list))
+		list = list[2:]
+		if total > len(list) {
+			return 0
+		}
+		list = list[:total]
+		count := 0
+		for len(list) >= 2 {
+			n := int(binary.BigEndian.Uint16(list))
+			if n+2 > len(list) {
+				break
+			}
+			list = list[n+2:]
+			count++
+		}
+		return count
+	}
+	return 0
+}
+
+// TLS Feature 扩展中包含 status_request(5) 即为 must-staple（RFC 7633）
+func isMustStaple(cert *x509.Certificate) bool {
+	for _, ext := range cert.Extensions {
+		if !ext.Id.Equal(oidTLSFeature) {
+			continue
+		}
+		var features []int
+		if _, err := asn1.Unmarshal(ext.Value, &features); err != nil {
+			return false
+		}
+		for _, f := range features {
+			if f == 5 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ValidationType 根据证书策略判断 EV/OV/DV/IV，无已知策略时为 unknown
+func ValidationType(cert *x509.Certificate) string {
+	result := ""
+	for _, oid := range cert.PolicyIdentifiers {
+		if t, ok := policyValidationType[oid.String()]; ok {
+			// EV 优先级最高
+			if t == "EV" || result == "" {
+				result = t
+			}
+		}
+	}
+	if result != "" {
+		return result
+	}
+	return "unknown"
+}
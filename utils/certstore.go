@@ -0,0 +1,138 @@
+package utils
+
+import (
+	"bufio"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"scan-website/models"
+	"scan-website/pipeline"
+	"sync"
+)
+
+// 扫描时使用的证书库。非空时结果中只保存证书 ID（CertInfo.ChainFingerprints），
+// 证书本体由调用方从 TakeNew 取出另行保存；为空时仍把整条链写进 RawCerts
+var DefaultCertStore *CertStore
+
+// CertStore 以 SHA-256 指纹为键保存证书，同一张证书只保存一次
+type CertStore struct {
+	mu      sync.Mutex
+	certs   map[string][]byte
+	pending []models.CertRecord // 尚未被 TakeNew 取走的新证书
+}
+
+func NewCertStore() *CertStore {
+	return &CertStore{certs: make(map[string][]byte)}
+}
+
+// Put 保存整条链，返回每张证书的 ID
+func (s *CertStore) Put(chain []*x509.Certificate) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ids := make([]string, 0, len(chain))
+	for _, c := range chain {
+		id := certFingerprint(c)
+		ids = append(ids, id)
+		if _, ok := s.certs[id]; ok {
+			continue
+		}
+		s.certs[id] = c.Raw
+		s.pending = append(s.pending, models.CertRecord{
+			ID:       id,
+			Subject:  c.Subject.String(),
+			Issuer:   c.Issuer.String(),
+			NotAfter: c.NotAfter,
+			Raw:      base64.StdEncoding.EncodeToString(c.Raw),
+		})
+	}
+	return ids
+}
+
+// TakeNew 取出上次调用之后新加入的证书
+func (s *CertStore) TakeNew() []models.CertRecord {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	pending := s.pending
+	s.pending = nil
+	return pending
+}
+
+func (s *CertStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.certs)
+}
+
+// Get 按 ID 取出证书
+func (s *CertStore) Get(id string) (*x509.Certificate, error) {
+	s.mu.Lock()
+	raw, ok := s.certs[id]
+	s.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("certificate %s not found", id)
+	}
+	return x509.ParseCertificate(raw)
+}
+
+// Chain 按 ID 列表还原证书链
+func (s *CertStore) Chain(ids []string) ([]*x509.Certificate, error) {
+	var chain []*x509.Certificate
+	for _, id := range ids {
+		c, err := s.Get(id)
+		if err != nil {
+			return nil, err
+		}
+		chain = append(chain, c)
+	}
+	return chain, nil
+}
+
+// Load 从 certs.jsonl 读入证书（已存在的不会再次进入 TakeNew）
+func (s *CertStore) Load(fileName string) error {
+	file, err := pipeline.Open(fileName)
+	if err != nil {
+		return fmt.Errorf("failed to open cert store: %v", err)
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 {
+			var rec models.CertRecord
+			if jerr := json.Unmarshal(line, &rec); jerr != nil {
+				return fmt.Errorf("invalid cert record: %v", jerr)
+			}
+			raw, derr := base64.StdEncoding.DecodeString(rec.Raw)
+			if derr != nil {
+				return fmt.Errorf("invalid cert %s: %v", rec.ID, derr)
+			}
+			s.certs[rec.ID] = raw
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("error reading cert store: %v", err)
+		}
+	}
+}
+
+// 同一条链只验证一次，与主机名无关；主机名匹配由调用方逐次计算
+type chainVerdict struct {
+	trustedBy []string
+	verifyErr string
+	report    *models.ChainReport
+	warnings  []models.CertWarning
+}
+
+var chainVerdicts sync.Map // chainKey -> *chainVerdict
+
+// ResetChainCache 清空链验证缓存，长时间运行时按批调用，避免缓存随域名数无限增长
+func ResetChainCache() {
+	chainVerdicts.Clear()
+}
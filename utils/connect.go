@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"os"
 	"os/exec"
+	"scan-website/config"
 	"scan-website/models"
 	"strings"
 )
@@ -14,9 +15,8 @@ import (
 func RunZGrab2WithResult(protocol, hostname, port, mode string) (bool, *models.ConnectInfo, error) {
 	fmt.Print("Running test\n")
 
-	// pythonPath := "python3" //linux
-	pythonPath := "python" //windows
-	scriptPath := "/home/wzq/scan-website/tlscheck/test_tls.py"
+	pythonPath := config.Get().Paths.Python
+	scriptPath := config.Get().Paths.TLSCheckScript
 
 	cmd := exec.Command(pythonPath, scriptPath,
 		"--protocol", protocol,
@@ -161,9 +161,8 @@ func writeCSV(hostname string) (string, error) {
 func RunZGrab2(protocol, hostname, port, mode string) (bool, error) { //4.22python
 	fmt.Print("Running test\n")
 
-	// pythonPath := "python3" //linux
-	pythonPath := "python" //windows
-	scriptPath := "/home/wzq/scan-website/tlscheck/test_tls.py"
+	pythonPath := config.Get().Paths.Python
+	scriptPath := config.Get().Paths.TLSCheckScript
 
 	cmd := exec.Command(pythonPath, scriptPath,
 		"--protocol", protocol,
@@ -0,0 +1,139 @@
+package utils
+
+import (
+	"crypto/x509"
+	"fmt"
+	"scan-website/config"
+	"scan-website/models"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+var tlsaUsageNames = map[uint8]string{
+	0: "PKIX-TA",
+	1: "PKIX-EE",
+	2: "DANE-TA",
+	3: "DANE-EE",
+}
+
+// TLSAName 返回 _port._tcp.host 形式的查询名
+func TLSAName(host string, port int) string {
+	return fmt.Sprintf("_%d._tcp.%s", port, dns.Fqdn(host))
+}
+
+// LookupTLSA 查询主机端口的 TLSA 记录
+func LookupTLSA(host string, port int) ([]*dns.TLSA, error) {
+	client := &dns.Client{
+		Net:     "udp",
+		Timeout: config.Get().Timeouts.DNS.Std(),
+	}
+	msg := new(dns.Msg)
+	msg.SetQuestion(TLSAName(host, port), dns.TypeTLSA)
+	msg.RecursionDesired = true
+	msg.SetEdns0(4096, true)
+
+	resolver := config.Get().Resolver()
+	response, _, err := client.Exchange(msg, resolver)
+	if err == nil && response.Truncated {
+		client.Net = "tcp"
+		response, _, err = client.Exchange(msg, resolver)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("TLSA query failed: %v", err)
+	}
+	var records []*dns.TLSA
+	for _, ans := range response.Answer {
+		if tlsa, ok := ans.(*dns.TLSA); ok {
+			records = append(records, tlsa)
+		}
+	}
+	return records, nil
+}
+
+// CheckDANE 查询 TLSA、验证其 DNSSEC 状态，并与连接时看到的证书链匹配；chain 可为空（未能连接）
+func CheckDANE(host string, port int, chain []*x509.Certificate) *models.DANEResult {
+	result := &models.DANEResult{Name: TLSAName(host, port)}
+	records, err := LookupTLSA(host, port)
+	if err != nil {
+		result.Verdict = "error"
+		result.Error = err.Error()
+		return result
+	}
+	if len(records) == 0 {
+		result.Verdict = "none"
+		return result
+	}
+	for _, r := range records {
+		result.Records = append(result.Records, r.String())
+	}
+	if validator, err := DefaultDNSSECValidator(); err == nil {
+		result.DNSSEC = validator.Validate(result.Name, dns.TypeTLSA)
+	}
+	MatchTLSA(result, records, chain, host)
+	return result
+}
+
+// MatchTLSA 按 RFC 7671 匹配：只有 DNSSEC 验证为 secure 的 TLSA 才可用（RFC 7672 2.2），
+// 不可用时仍记录匹配情况，但结论为 unusable
+func MatchTLSA(result *models.DANEResult, records []*dns.TLSA, chain []*x509.Certificate, host string) {
+	if len(chain) == 0 {
+		result.Verdict = "no-chain"
+		return
+	}
+	for _, r := range records {
+		if tlsaMatches(r, chain, host) {
+			result.MatchedUsage = tlsaUsageNames[r.Usage]
+			break
+		}
+	}
+	switch {
+	case result.DNSSEC == nil || result.DNSSEC.Status != DNSSECSecure:
+		result.Verdict = "unusable"
+	case result.MatchedUsage != "":
+		result.Verdict = "valid"
+	default:
+		result.Verdict = "mismatch"
+	}
+}
+
+func tlsaMatches(r *dns.TLSA, chain []*x509.Certificate, host string) bool {
+	leaf := chain[0]
+	switch r.Usage {
+	case 3: // DANE-EE：只看服务器证书本身，不检查有效期和名称
+		return tlsaVerify(r, leaf)
+	case 2: // DANE-TA：链中某个颁发者匹配，且从 leaf 到它的签名关系成立
+		return taMatches(r, chain)
+	case 1: // PKIX-EE：leaf 匹配且通过常规 PKIX 验证
+		if !tlsaVerify(r, leaf) {
+			return false
+		}
+		trusted, _ := VerifyCertificate(chain, host)
+		return trusted
+	case 0: // PKIX-TA
+		if !taMatches(r, chain) {
+			return false
+		}
+		trusted, _ := VerifyCertificate(chain, host)
+		return trusted
+	}
+	return false
+}
+
+// 按选择器和匹配类型计算证书摘要并与记录比较（记录中的十六进制大小写不敏感）
+func tlsaVerify(r *dns.TLSA, cert *x509.Certificate) bool {
+	c, err := dns.CertificateToDANE(r.Selector, r.MatchingType, cert)
+	return err == nil && strings.EqualFold(c, r.Certificate)
+}
+
+func taMatches(r *dns.TLSA, chain []*x509.Certificate) bool {
+	for i := 1; i < len(chain); i++ {
+		if !issuedBy(chain[i-1], chain[i]) {
+			return false
+		}
+		if tlsaVerify(r, chain[i]) {
+			return true
+		}
+	}
+	return false
+}
@@ -2,8 +2,7 @@ package utils
 
 import (
 	"fmt"
-	"scan-website/models"
-	"sort"
+	"scan-website/config"
 	"strings"
 	"time"
 
@@ -14,8 +13,8 @@ import (
 // DNS查询相关函数
 func LookupSRVWithAD_autodiscover(domain string) (string, bool, error) {
 	// DNS Resolver configuration
-	resolverAddr := "8.8.8.8:53" // Google Public DNS
-	timeout := 5 * time.Second   // Timeout for DNS query
+	resolverAddr := config.Get().Resolver()
+	timeout := config.Get().Timeouts.DNS.Std()
 
 	// Create a DNS client
 	client := &dns.Client{
@@ -46,14 +45,11 @@ func LookupSRVWithAD_autodiscover(domain string) (string, bool, error) {
 		}
 	}
 	var uriDNS string
+	if IsSRVNotOffered(srvRecords) {
+		return "", adBit, fmt.Errorf("service not offered (target '.')")
+	}
+	srvRecords = OrderSRV(FilterSRVTargets(srvRecords))
 	if len(srvRecords) > 0 {
-		sort.Slice(srvRecords, func(i, j int) bool {
-			if srvRecords[i].Priority == srvRecords[j].Priority {
-				return srvRecords[i].Weight > srvRecords[j].Weight
-			}
-			return srvRecords[i].Priority < srvRecords[j].Priority
-		})
-
 		hostname := srvRecords[0].Target
 		port := srvRecords[0].Port
 		if hostname != "." {
@@ -76,8 +72,8 @@ func LookupSRVWithAD_autodiscover(domain string) (string, bool, error) {
 
 // 查询CNAME部分
 func LookupCNAME(domain string) ([]string, error) {
-	resolverAddr := "8.8.8.8:53" // Google Public DNS
-	timeout := 5 * time.Second   // Timeout for DNS query
+	resolverAddr := config.Get().Resolver()
+	timeout := config.Get().Timeouts.DNS.Std()
 
 	client := &dns.Client{
 		Net:     "udp",
@@ -113,47 +109,40 @@ func LookupCNAME(domain string) ([]string, error) {
 	return nil, lastErr
 }
 
-// 获取MX记录
-func ResolveMXRecord(domain string) (string, error) {
-	//创建DNS客户端并设置超时时间
-	client := &dns.Client{
-		Timeout: 15 * time.Second, // 设置超时时间
-	}
-
-	// 创建DNS消息
-	msg := new(dns.Msg)
-	msg.SetQuestion(dns.Fqdn(domain), dns.TypeMX)
-	//发送DNS查询
-	response, _, err := client.Exchange(msg, models.DnsServer)
+// LookupTXT 查询 TXT 记录，每条记录的多个字符串按 RFC 7208 3.3 直接拼接；NXDOMAIN 视为没有记录
+func LookupTXT(name string) ([]string, error) {
+	response, err := exchange(name, dns.TypeTXT)
 	if err != nil {
-		fmt.Printf("Failed to query DNS for %s: %v\n", domain, err)
-		return "", err
+		return nil, err
+	}
+	if response.Rcode == dns.RcodeNameError {
+		return nil, nil
 	}
-
-	//处理响应
 	if response.Rcode != dns.RcodeSuccess {
-		fmt.Printf("DNS query failed with Rcode %d\n", response.Rcode)
-		return "", fmt.Errorf("DNS query failed with Rcode %d", response.Rcode)
+		return nil, fmt.Errorf("TXT query failed with Rcode %s", dns.RcodeToString[response.Rcode])
 	}
-
-	var mxRecords []*dns.MX
+	var records []string
 	for _, ans := range response.Answer {
-		if mxRecord, ok := ans.(*dns.MX); ok {
-			fmt.Printf("MX record for %s: %s, the priority is %d\n", domain, mxRecord.Mx, mxRecord.Preference)
-			mxRecords = append(mxRecords, mxRecord)
+		if txt, ok := ans.(*dns.TXT); ok {
+			records = append(records, strings.Join(txt.Txt, ""))
 		}
 	}
-	if len(mxRecords) == 0 {
+	return records, nil
+}
+
+// 获取MX记录，返回优先级最高的一个；null MX（RFC 7505）视为错误
+func ResolveMXRecord(domain string) (string, error) {
+	mx, err := LookupMX(domain)
+	if err != nil {
+		return "", err
+	}
+	if mx.NullMX {
+		return "", fmt.Errorf("null MX, domain does not accept mail")
+	}
+	if len(mx.Records) == 0 {
 		return "", fmt.Errorf("no MX Record")
 	}
-
-	// 根据Preference字段排序，Preference值越小优先级越高
-	sort.Slice(mxRecords, func(i, j int) bool {
-		return mxRecords[i].Preference < mxRecords[j].Preference
-	})
-	highestMX := mxRecords[0]
-	return highestMX.Mx, nil
-
+	return mx.Records[0].Host, nil
 }
 
 // 提取%MXFULLDOMAIN%和%MXMAINDOMAIN%
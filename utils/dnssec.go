@@ -0,0 +1,562 @@
+package utils
+
+import (
+	"fmt"
+	"scan-website/config"
+	"scan-website/models"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// 本地 DNSSEC 验证：不信任递归服务器的 AD 位，而是带 CD 位取回 RRSIG/DNSKEY/DS，
+// 沿签名者从根信任锚逐级验证。结果为 secure / insecure / bogus / indeterminate
+const (
+	DNSSECSecure        = "secure"        // 整条信任链验证通过
+	DNSSECInsecure      = "insecure"      // 经签名的 NSEC/NSEC3 证明某一级没有 DS，即未签名区
+	DNSSECBogus         = "bogus"         // 应当有签名但签名缺失或验证失败
+	DNSSECIndeterminate = "indeterminate" // 查询失败等原因无法得出结论
+)
+
+// 根区 KSK-2017 与 KSK-2024 的 DS
+var RootAnchors = []string{
+	". 172800 IN DS 20326 8 2 E06D44B80B8F1D39A95C0B0D7C65D08458E880409BBC683457104237C7F8EC8D",
+	". 172800 IN DS 38696 8 2 683D2D0ACB8C9B712A1948B27F741219298D0A450D612C483AF444A4C0FB2B16",
+}
+
+type DNSSECValidator struct {
+	Resolver string    // 递归服务器 host:port，只用于取数据，不信任其验证结果
+	Anchors  []*dns.DS // 根区信任锚
+	Client   *dns.Client
+
+	mu    sync.Mutex
+	zones map[string]*zoneTrust // 区名 -> 验证结果，整个扫描期间复用
+}
+
+type zoneTrust struct {
+	keys   []*dns.DNSKEY
+	status string
+	reason string
+}
+
+// NewDNSSECValidator 使用 RootAnchors 作为信任锚
+func NewDNSSECValidator(resolver string, timeout time.Duration) (*DNSSECValidator, error) {
+	v := &DNSSECValidator{
+		Resolver: resolver,
+		Client:   &dns.Client{Net: "udp", Timeout: timeout},
+		zones:    make(map[string]*zoneTrust),
+	}
+	for _, s := range RootAnchors {
+		rr, err := dns.NewRR(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trust anchor: %v", err)
+		}
+		v.Anchors = append(v.Anchors, rr.(*dns.DS))
+	}
+	return v, nil
+}
+
+var (
+	defaultDNSSECValidator     *DNSSECValidator
+	defaultDNSSECValidatorErr  error
+	defaultDNSSECValidatorOnce sync.Once
+)
+
+// DefaultDNSSECValidator 使用配置中的解析器和超时，只创建一次以共享各区的验证缓存
+func DefaultDNSSECValidator() (*DNSSECValidator, error) {
+	defaultDNSSECValidatorOnce.Do(func() {
+		cfg := config.Get()
+		defaultDNSSECValidator, defaultDNSSECValidatorErr = NewDNSSECValidator(cfg.Resolver(), cfg.Timeouts.DNS.Std())
+	})
+	return defaultDNSSECValidator, defaultDNSSECValidatorErr
+}
+
+// Validate 验证 name/qtype 的应答（包括否定应答）
+func (v *DNSSECValidator) Validate(name string, qtype uint16) *models.DNSSECResult {
+	name = dns.CanonicalName(name)
+	result := &models.DNSSECResult{Name: name, Type: dns.TypeToString[qtype]}
+	result.Status, result.Reason = v.validate(name, qtype)
+	return result
+}
+
+func (v *DNSSECValidator) validate(name string, qtype uint16) (string, string) {
+	resp, err := v.query(name, qtype)
+	if err != nil {
+		return DNSSECIndeterminate, err.Error()
+	}
+	if resp.Rcode != dns.RcodeSuccess && resp.Rcode != dns.RcodeNameError {
+		return DNSSECIndeterminate, fmt.Sprintf("rcode %s", dns.RcodeToString[resp.Rcode])
+	}
+
+	// 肯定应答：逐个 RRset 验证（含 CNAME 链）
+	sets := groupRRsets(resp.Answer)
+	if len(sets) > 0 {
+		for _, set := range sets {
+			sigs := sigsFor(resp.Answer, set[0].Header().Name, set[0].Header().Rrtype)
+			if len(sigs) == 0 {
+				// 没有签名：所在区未签名则为 insecure，否则为 bogus
+				status, reason := v.zoneStatusOf(set[0].Header().Name)
+				if status == DNSSECSecure {
+					return DNSSECBogus, fmt.Sprintf("missing RRSIG for %s %s", set[0].Header().Name, dns.TypeToString[set[0].Header().Rrtype])
+				}
+				return status, reason
+			}
+			owner := dns.CanonicalName(set[0].Header().Name)
+			// 签名者必须是记录所在区，即 owner 本身或其祖先（RFC 4035 5.3.1），
+			// 否则任何已签名区的密钥都能为无关名字"担保"
+			signer := dns.CanonicalName(sigs[0].SignerName)
+			for _, sig := range sigs {
+				if !dns.IsSubDomain(dns.CanonicalName(sig.SignerName), owner) {
+					return DNSSECBogus, fmt.Sprintf("RRSIG signer %s is not an ancestor of %s", sig.SignerName, owner)
+				}
+			}
+			trust := v.zone(signer)
+			if trust.status != DNSSECSecure {
+				return trust.status, trust.reason
+			}
+			sig, err := verifyRRsetSig(set, sigs, trust.keys)
+			if err != nil {
+				return DNSSECBogus, err.Error()
+			}
+			// 签名的 Labels 少于 owner 的标签数说明是通配符展开，
+			// 还需证明没有更接近的名字存在（RFC 4035 5.3.4）
+			if int(sig.Labels) < dns.CountLabel(owner) {
+				if err := proveWildcard(resp.Ns, signer, owner, int(sig.Labels), trust.keys); err != nil {
+					return DNSSECBogus, err.Error()
+				}
+			}
+		}
+		return DNSSECSecure, ""
+	}
+
+	// 否定应答：用权威部分的 SOA 确定所在区，再验证 NSEC/NSEC3
+	// SOA 必须是 name 本身或其祖先，否则换成一个未签名区的 SOA 就能把应答降级为 insecure
+	zone := soaOwner(resp.Ns)
+	if zone == "" {
+		return DNSSECIndeterminate, "no SOA in negative response"
+	}
+	if !dns.IsSubDomain(zone, name) {
+		return DNSSECBogus, fmt.Sprintf("SOA zone %s is not an ancestor of %s", zone, name)
+	}
+	trust := v.zone(zone)
+	if trust.status != DNSSECSecure {
+		return trust.status, trust.reason
+	}
+	denials, err := verifiedDenials(resp.Ns, zone, trust.keys)
+	if err != nil {
+		return DNSSECBogus, err.Error()
+	}
+	if resp.Rcode == dns.RcodeNameError {
+		if denialProvesNXDOMAIN(denials, name) {
+			return DNSSECSecure, ""
+		}
+		return DNSSECBogus, "NXDOMAIN without proof of no name and no wildcard"
+	}
+	if denialMatchesWithout(denials, name, qtype) {
+		return DNSSECSecure, ""
+	}
+	return DNSSECBogus, "NODATA without matching NSEC/NSEC3"
+}
+
+// 查找 name 所在的区并返回该区的状态（用于判断无签名应答）
+func (v *DNSSECValidator) zoneStatusOf(name string) (string, string) {
+	resp, err := v.query(name, dns.TypeSOA)
+	if err != nil {
+		return DNSSECIndeterminate, err.Error()
+	}
+	zone := soaOwner(resp.Answer)
+	if zone == "" {
+		zone = soaOwner(resp.Ns)
+	}
+	if zone == "" {
+		return DNSSECIndeterminate, "cannot find zone of " + name
+	}
+	if !dns.IsSubDomain(zone, name) {
+		return DNSSECBogus, fmt.Sprintf("SOA zone %s is not an ancestor of %s", zone, name)
+	}
+	trust := v.zone(zone)
+	return trust.status, trust.reason
+}
+
+// zone 返回区的 DNSKEY 验证结果，按签名者逐级向上直到根
+func (v *DNSSECValidator) zone(zone string) *zoneTrust {
+	zone = dns.CanonicalName(zone)
+	v.mu.Lock()
+	if t, ok := v.zones[zone]; ok {
+		v.mu.Unlock()
+		return t
+	}
+	v.mu.Unlock()
+
+	t := v.buildZoneTrust(zone)
+	// 查询失败不缓存，留给后面的域名重试
+	if t.status != DNSSECIndeterminate {
+		v.mu.Lock()
+		v.zones[zone] = t
+		v.mu.Unlock()
+	}
+	return t
+}
+
+func (v *DNSSECValidator) buildZoneTrust(zone string) *zoneTrust {
+	var dsSet []*dns.DS
+	if zone == "." {
+		dsSet = v.Anchors
+	} else {
+		resp, err := v.query(zone, dns.TypeDS)
+		if err != nil {
+			return &zoneTrust{status: DNSSECIndeterminate, reason: err.Error()}
+		}
+		ds := rrsetOf(resp.Answer, zone, dns.TypeDS)
+		if len(ds) == 0 {
+			return v.proveNoDS(zone, resp)
+		}
+		sigs := sigsFor(resp.Answer, zone, dns.TypeDS)
+		if len(sigs) == 0 {
+			return &zoneTrust{status: DNSSECBogus, reason: "unsigned DS for " + zone}
+		}
+		// DS 必须由父区签名，否则会无限递归
+		if !dns.IsSubDomain(dns.CanonicalName(sigs[0].SignerName), zone) || dns.CanonicalName(sigs[0].SignerName) == zone {
+			return &zoneTrust{status: DNSSECBogus, reason: "DS for " + zone + " not signed by parent"}
+		}
+		parent := v.zone(sigs[0].SignerName)
+		if parent.status != DNSSECSecure {
+			return parent
+		}
+		if err := verifyRRset(ds, sigs, parent.keys); err != nil {
+			return &zoneTrust{status: DNSSECBogus, reason: err.Error()}
+		}
+		for _, rr := range ds {
+			dsSet = append(dsSet, rr.(*dns.DS))
+		}
+	}
+
+	resp, err := v.query(zone, dns.TypeDNSKEY)
+	if err != nil {
+		return &zoneTrust{status: DNSSECIndeterminate, reason: err.Error()}
+	}
+	keySet := rrsetOf(resp.Answer, zone, dns.TypeDNSKEY)
+	var keys, trusted []*dns.DNSKEY
+	for _, rr := range keySet {
+		key := rr.(*dns.DNSKEY)
+		keys = append(keys, key)
+		if matchesDS(key, dsSet) {
+			trusted = append(trusted, key)
+		}
+	}
+	if len(trusted) == 0 {
+		return &zoneTrust{status: DNSSECBogus, reason: "no DNSKEY of " + zone + " matches DS"}
+	}
+	if err := verifyRRset(keySet, sigsFor(resp.Answer, zone, dns.TypeDNSKEY), trusted); err != nil {
+		return &zoneTrust{status: DNSSECBogus, reason: err.Error()}
+	}
+	return &zoneTrust{keys: keys, status: DNSSECSecure}
+}
+
+// 没有 DS 时需要父区签名的 NSEC/NSEC3 证明，否则视为 bogus
+func (v *DNSSECValidator) proveNoDS(zone string, resp *dns.Msg) *zoneTrust {
+	parentName := soaOwner(resp.Ns)
+	if parentName == "" || parentName == zone || !dns.IsSubDomain(parentName, zone) {
+		return &zoneTrust{status: DNSSECIndeterminate, reason: "no parent SOA in DS response for " + zone}
+	}
+	parent := v.zone(parentName)
+	if parent.status != DNSSECSecure {
+		return parent
+	}
+	denials, err := verifiedDenials(resp.Ns, parentName, parent.keys)
+	if err != nil {
+		return &zoneTrust{status: DNSSECBogus, reason: err.Error()}
+	}
+	if denialMatchesWithout(denials, zone, dns.TypeDS) || optOutCovers(denials, zone) {
+		return &zoneTrust{status: DNSSECInsecure, reason: "no DS for " + zone}
+	}
+	return &zoneTrust{status: DNSSECBogus, reason: "missing proof of no DS for " + zone}
+}
+
+func (v *DNSSECValidator) query(name string, qtype uint16) (*dns.Msg, error) {
+	msg := new(dns.Msg)
+	msg.SetQuestion(dns.Fqdn(name), qtype)
+	msg.RecursionDesired = true
+	msg.CheckingDisabled = true // 让解析器返回未经其验证的数据
+	msg.SetEdns0(4096, true)
+	resp, _, err := v.Client.Exchange(msg, v.Resolver)
+	if err == nil && resp.Truncated {
+		tcp := *v.Client
+		tcp.Net = "tcp"
+		resp, _, err = tcp.Exchange(msg, v.Resolver)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("DNS query %s %s failed: %v", name, dns.TypeToString[qtype], err)
+	}
+	return resp, nil
+}
+
+// 按 (owner, type) 分组，RRSIG 不算在内
+func groupRRsets(rrs []dns.RR) [][]dns.RR {
+	var sets [][]dns.RR
+	index := make(map[string]int)
+	for _, rr := range rrs {
+		if rr.Header().Rrtype == dns.TypeRRSIG {
+			continue
+		}
+		key := dns.CanonicalName(rr.Header().Name) + "/" + dns.TypeToString[rr.Header().Rrtype]
+		if i, ok := index[key]; ok {
+			sets[i] = append(sets[i], rr)
+			continue
+		}
+		index[key] = len(sets)
+		sets = append(sets, []dns.RR{rr})
+	}
+	return sets
+}
+
+func rrsetOf(rrs []dns.RR, name string, rrtype uint16) []dns.RR {
+	var set []dns.RR
+	for _, rr := range rrs {
+		if rr.Header().Rrtype == rrtype && strings.EqualFold(rr.Header().Name, name) {
+			set = append(set, rr)
+		}
+	}
+	return set
+}
+
+func sigsFor(rrs []dns.RR, name string, rrtype uint16) []*dns.RRSIG {
+	var sigs []*dns.RRSIG
+	for _, rr := range rrs {
+		if sig, ok := rr.(*dns.RRSIG); ok && sig.TypeCovered == rrtype && strings.EqualFold(sig.Header().Name, name) {
+			sigs = append(sigs, sig)
+		}
+	}
+	return sigs
+}
+
+func soaOwner(rrs []dns.RR) string {
+	for _, rr := range rrs {
+		if soa, ok := rr.(*dns.SOA); ok {
+			return dns.CanonicalName(soa.Header().Name)
+		}
+	}
+	return ""
+}
+
+// 任意一个签名能用任意一个 key 验证通过且在有效期内即可
+func verifyRRset(set []dns.RR, sigs []*dns.RRSIG, keys []*dns.DNSKEY) error {
+	_, err := verifyRRsetSig(set, sigs, keys)
+	return err
+}
+
+// 同 verifyRRset，另外返回验证通过的签名
+func verifyRRsetSig(set []dns.RR, sigs []*dns.RRSIG, keys []*dns.DNSKEY) (*dns.RRSIG, error) {
+	if len(sigs) == 0 {
+		return nil, fmt.Errorf("missing RRSIG for %s %s", set[0].Header().Name, dns.TypeToString[set[0].Header().Rrtype])
+	}
+	var lastErr error
+	for _, sig := range sigs {
+		if !sig.ValidityPeriod(time.Now()) {
+			lastErr = fmt.Errorf("RRSIG for %s %s expired or not yet valid", set[0].Header().Name, dns.TypeToString[sig.TypeCovered])
+			continue
+		}
+		for _, key := range keys {
+			if key.KeyTag() != sig.KeyTag || key.Algorithm != sig.Algorithm {
+				continue
+			}
+			if err := sig.Verify(key, set); err != nil {
+				lastErr = fmt.Errorf("RRSIG for %s %s invalid: %v", set[0].Header().Name, dns.TypeToString[sig.TypeCovered], err)
+				continue
+			}
+			return sig, nil
+		}
+	}
+	if lastErr == nil {
+		lastErr = fmt.Errorf("no DNSKEY for RRSIG of %s %s", set[0].Header().Name, dns.TypeToString[sigs[0].TypeCovered])
+	}
+	return nil, lastErr
+}
+
+// 通配符展开的应答需要签名有效的 NSEC/NSEC3 证明查询名本身不存在：
+// NSEC 覆盖 owner，或 NSEC3 覆盖"下一个更近的名字"（最近祖先再加一级，RFC 5155 8.8）
+func proveWildcard(ns []dns.RR, zone, owner string, labels int, keys []*dns.DNSKEY) error {
+	denials, err := verifiedDenials(ns, zone, keys)
+	if err != nil {
+		return fmt.Errorf("wildcard answer for %s without proof: %v", owner, err)
+	}
+	ownerLabels := dns.SplitDomainName(owner)
+	nextCloser := dns.Fqdn(strings.Join(ownerLabels[len(ownerLabels)-labels-1:], "."))
+	for _, rr := range denials {
+		switch d := rr.(type) {
+		case *dns.NSEC:
+			if nsecCovers(d.Header().Name, d.NextDomain, owner) {
+				return nil
+			}
+		case *dns.NSEC3:
+			if d.Cover(nextCloser) {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("wildcard answer for %s without NSEC/NSEC3 proof of no closer match", owner)
+}
+
+func matchesDS(key *dns.DNSKEY, dsSet []*dns.DS) bool {
+	for _, ds := range dsSet {
+		if ds.KeyTag != key.KeyTag() || ds.Algorithm != key.Algorithm {
+			continue
+		}
+		computed := key.ToDS(ds.DigestType)
+		if computed != nil && strings.EqualFold(computed.Digest, ds.Digest) {
+			return true
+		}
+	}
+	return false
+}
+
+// 取出权威部分中签名有效的 NSEC/NSEC3，owner 必须在 zone 内
+func verifiedDenials(ns []dns.RR, zone string, keys []*dns.DNSKEY) ([]dns.RR, error) {
+	var denials []dns.RR
+	for _, set := range groupRRsets(ns) {
+		rrtype := set[0].Header().Rrtype
+		if rrtype != dns.TypeNSEC && rrtype != dns.TypeNSEC3 {
+			continue
+		}
+		if !dns.IsSubDomain(zone, set[0].Header().Name) {
+			return nil, fmt.Errorf("%s %s is outside zone %s", dns.TypeToString[rrtype], set[0].Header().Name, zone)
+		}
+		if err := verifyRRset(set, sigsFor(ns, set[0].Header().Name, rrtype), keys); err != nil {
+			return nil, err
+		}
+		denials = append(denials, set...)
+	}
+	if len(denials) == 0 {
+		return nil, fmt.Errorf("missing NSEC/NSEC3 in negative response")
+	}
+	return denials, nil
+}
+
+// name 存在但没有 qtype 记录
+func denialMatchesWithout(denials []dns.RR, name string, qtype uint16) bool {
+	for _, rr := range denials {
+		switch d := rr.(type) {
+		case *dns.NSEC:
+			if strings.EqualFold(d.Header().Name, name) && !hasType(d.TypeBitMap, qtype) && !hasType(d.TypeBitMap, dns.TypeCNAME) {
+				return true
+			}
+		case *dns.NSEC3:
+			if d.Match(name) && !hasType(d.TypeBitMap, qtype) && !hasType(d.TypeBitMap, dns.TypeCNAME) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// name 不存在（RFC 4035 5.4）：NSEC 区间覆盖 name，且最近祖先下的通配符 *.ce 也被覆盖；
+// NSEC3 需要最近祖先证明（匹配 ce 并覆盖下一个更近的名字），且覆盖 *.ce（RFC 5155 8.4）
+func denialProvesNXDOMAIN(denials []dns.RR, name string) bool {
+	var nsecs []*dns.NSEC
+	var nsec3s []*dns.NSEC3
+	for _, rr := range denials {
+		switch d := rr.(type) {
+		case *dns.NSEC:
+			nsecs = append(nsecs, d)
+		case *dns.NSEC3:
+			nsec3s = append(nsec3s, d)
+		}
+	}
+	nsecCoversName := func(target string) bool {
+		for _, d := range nsecs {
+			if nsecCovers(d.Header().Name, d.NextDomain, target) {
+				return true
+			}
+		}
+		return false
+	}
+	for _, d := range nsecs {
+		if !nsecCovers(d.Header().Name, d.NextDomain, name) {
+			continue
+		}
+		// 最近祖先是 name 与区间两端共同后缀中较长的那个
+		n := dns.CompareDomainName(name, d.Header().Name)
+		if m := dns.CompareDomainName(name, d.NextDomain); m > n {
+			n = m
+		}
+		if nsecCoversName(wildcardAt(lastLabels(name, n))) {
+			return true
+		}
+	}
+
+	nsec3Any := func(f func(*dns.NSEC3) bool) bool {
+		for _, d := range nsec3s {
+			if f(d) {
+				return true
+			}
+		}
+		return false
+	}
+	labels := dns.SplitDomainName(name)
+	for i := 1; i <= len(labels); i++ {
+		ce := lastLabels(name, len(labels)-i)
+		if !nsec3Any(func(d *dns.NSEC3) bool { return d.Match(ce) }) {
+			continue
+		}
+		nextCloser := lastLabels(name, len(labels)-i+1)
+		return nsec3Any(func(d *dns.NSEC3) bool { return d.Cover(nextCloser) }) &&
+			nsec3Any(func(d *dns.NSEC3) bool { return d.Cover(wildcardAt(ce)) })
+	}
+	return false
+}
+
+// name 最右边的 n 个标签，n 为 0 时是根
+func lastLabels(name string, n int) string {
+	labels := dns.SplitDomainName(name)
+	if n <= 0 {
+		return "."
+	}
+	return dns.Fqdn(strings.Join(labels[len(labels)-n:], "."))
+}
+
+func wildcardAt(ce string) string {
+	if ce == "." {
+		return "*."
+	}
+	return "*." + ce
+}
+
+// 带 opt-out 标志的 NSEC3 覆盖了该名字，说明这是一个未签名的委派
+func optOutCovers(denials []dns.RR, name string) bool {
+	for _, rr := range denials {
+		if d, ok := rr.(*dns.NSEC3); ok && d.Flags&1 == 1 && d.Cover(name) {
+			return true
+		}
+	}
+	return false
+}
+
+func hasType(bitmap []uint16, t uint16) bool {
+	for _, b := range bitmap {
+		if b == t {
+			return true
+		}
+	}
+	return false
+}
+
+// owner < name < next（按 RFC 4034 6.1 规范顺序），next 回到区顶点时表示区内最后一个名字
+func nsecCovers(owner, next, name string) bool {
+	if canonicalCompare(owner, next) < 0 {
+		return canonicalCompare(owner, name) < 0 && canonicalCompare(name, next) < 0
+	}
+	return canonicalCompare(owner, name) < 0 || canonicalCompare(name, next) < 0
+}
+
+func canonicalCompare(a, b string) int {
+	la := dns.SplitDomainName(strings.ToLower(a))
+	lb := dns.SplitDomainName(strings.ToLower(b))
+	for i, j := len(la)-1, len(lb)-1; i >= 0 && j >= 0; i, j = i-1, j-1 {
+		if c := strings.Compare(la[i], lb[j]); c != 0 {
+			return c
+		}
+	}
+	return len(la) - len(lb)
+}
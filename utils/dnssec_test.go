@@ -0,0 +1,309 @@
+package utils
+
+import (
+	"crypto"
+	"net"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// 本地权威服务器上的测试区：根区 "." 签名并委派已签名的 secure. 和未签名的 insecure.
+type testZone struct {
+	name   string
+	key    *dns.DNSKEY
+	priv   crypto.Signer
+	rrs    map[string][]dns.RR // name/type -> RRset
+	sigs   map[string][]dns.RR // name/type -> RRSIG
+	names  map[string][]uint16 // name -> 该名字下的类型
+	signed bool
+}
+
+func rrKey(name string, rrtype uint16) string {
+	return dns.CanonicalName(name) + "/" + dns.TypeToString[rrtype]
+}
+
+func newTestZone(t *testing.T, name string, signed bool) *testZone {
+	z := &testZone{
+		name:   name,
+		rrs:    make(map[string][]dns.RR),
+		sigs:   make(map[string][]dns.RR),
+		names:  make(map[string][]uint16),
+		signed: signed,
+	}
+	z.add(t, name+" 3600 IN SOA ns.invalid. hostmaster.invalid. 1 3600 600 86400 300")
+	if signed {
+		z.key = &dns.DNSKEY{
+			Hdr:       dns.RR_Header{Name: name, Rrtype: dns.TypeDNSKEY, Class: dns.ClassINET, Ttl: 3600},
+			Flags:     257,
+			Protocol:  3,
+			Algorithm: dns.ECDSAP256SHA256,
+		}
+		priv, err := z.key.Generate(256)
+		if err != nil {
+			t.Fatalf("generate key for %s: %v", name, err)
+		}
+		z.priv = priv.(crypto.Signer)
+		z.addRR(z.key)
+	}
+	return z
+}
+
+func (z *testZone) add(t *testing.T, s string) {
+	rr, err := dns.NewRR(s)
+	if err != nil {
+		t.Fatalf("invalid record %q: %v", s, err)
+	}
+	z.addRR(rr)
+}
+
+func (z *testZone) addRR(rr dns.RR) {
+	h := rr.Header()
+	k := rrKey(h.Name, h.Rrtype)
+	if len(z.rrs[k]) == 0 {
+		name := dns.CanonicalName(h.Name)
+		z.names[name] = append(z.names[name], h.Rrtype)
+	}
+	z.rrs[k] = append(z.rrs[k], rr)
+}
+
+func (z *testZone) sign(t *testing.T, set []dns.RR, inception, expiration time.Time) *dns.RRSIG {
+	sig := &dns.RRSIG{
+		Hdr:        dns.RR_Header{Ttl: 3600},
+		Algorithm:  z.key.Algorithm,
+		SignerName: z.name,
+		KeyTag:     z.key.KeyTag(),
+		Inception:  uint32(inception.Unix()),
+		Expiration: uint32(expiration.Unix()),
+	}
+	if err := sig.Sign(z.priv, set); err != nil {
+		t.Fatalf("sign %s: %v", set[0].Header().Name, err)
+	}
+	return sig
+}
+
+// 生成 NSEC 链并签名所有 RRset
+func (z *testZone) finish(t *testing.T) {
+	if !z.signed {
+		return
+	}
+	var names []string
+	for name := range z.names {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool { return canonicalCompare(names[i], names[j]) < 0 })
+	for i, name := range names {
+		types := append([]uint16{dns.TypeNSEC, dns.TypeRRSIG}, z.names[name]...)
+		sort.Slice(types, func(i, j int) bool { return types[i] < types[j] })
+		z.addRR(&dns.NSEC{
+			Hdr:        dns.RR_Header{Name: name, Rrtype: dns.TypeNSEC, Class: dns.ClassINET, Ttl: 300},
+			NextDomain: names[(i+1)%len(names)],
+			TypeBitMap: types,
+		})
+	}
+	now := time.Now()
+	for k, set := range z.rrs {
+		z.sigs[k] = []dns.RR{z.sign(t, set, now.Add(-time.Hour), now.Add(24*time.Hour))}
+	}
+}
+
+func (z *testZone) withSigs(name string, rrtype uint16) []dns.RR {
+	k := rrKey(name, rrtype)
+	return append(append([]dns.RR(nil), z.rrs[k]...), z.sigs[k]...)
+}
+
+// 覆盖 name 的 NSEC 及其签名
+func (z *testZone) coveringNSEC(name string) []dns.RR {
+	for owner := range z.names {
+		for _, rr := range z.rrs[rrKey(owner, dns.TypeNSEC)] {
+			if nsecCovers(owner, rr.(*dns.NSEC).NextDomain, name) {
+				return z.withSigs(owner, dns.TypeNSEC)
+			}
+		}
+	}
+	return nil
+}
+
+// name 最近的存在的祖先（包括空的非终结名字）
+func (z *testZone) closestEncloser(name string) string {
+	for ce := name; ; {
+		for owner := range z.names {
+			if dns.IsSubDomain(ce, owner) {
+				return ce
+			}
+		}
+		off, end := dns.NextLabel(ce, 0)
+		if end {
+			return "."
+		}
+		ce = ce[off:]
+	}
+}
+
+type testDNS struct {
+	zones   []*testZone
+	extra   map[string][]dns.RR // name/type -> 固定应答，用于构造异常数据
+	extraNx map[string][]dns.RR // name/type -> 固定的 NXDOMAIN 权威部分
+}
+
+// name 所在的最深的区；DS 由父区应答
+func (s *testDNS) zoneFor(name string, parent bool) *testZone {
+	var best *testZone
+	for _, z := range s.zones {
+		if !dns.IsSubDomain(z.name, name) || (parent && z.name == name) {
+			continue
+		}
+		if best == nil || dns.CountLabel(z.name) > dns.CountLabel(best.name) {
+			best = z
+		}
+	}
+	return best
+}
+
+func (s *testDNS) ServeDNS(w dns.ResponseWriter, req *dns.Msg) {
+	q := req.Question[0]
+	name := dns.CanonicalName(q.Name)
+	resp := new(dns.Msg)
+	resp.SetReply(req)
+	defer w.WriteMsg(resp)
+
+	if extra, ok := s.extra[rrKey(name, q.Qtype)]; ok {
+		resp.Answer = extra
+		return
+	}
+	if ns, ok := s.extraNx[rrKey(name, q.Qtype)]; ok {
+		resp.Rcode = dns.RcodeNameError
+		resp.Ns = ns
+		return
+	}
+	z := s.zoneFor(name, q.Qtype == dns.TypeDS)
+	if set := z.withSigs(name, q.Qtype); len(set) > 0 {
+		resp.Answer = set
+		return
+	}
+	if _, ok := z.names[name]; ok {
+		resp.Ns = append(z.withSigs(z.name, dns.TypeSOA), z.withSigs(name, dns.TypeNSEC)...)
+		return
+	}
+	// 通配符展开：按 *.父名字 的记录合成应答，签名原样复制
+	labels := dns.SplitDomainName(name)
+	wildcard := "*." + strings.Join(labels[1:], ".") + "."
+	if set := z.rrs[rrKey(wildcard, q.Qtype)]; len(set) > 0 {
+		for _, rr := range z.withSigs(wildcard, q.Qtype) {
+			rr = dns.Copy(rr)
+			rr.Header().Name = name
+			resp.Answer = append(resp.Answer, rr)
+		}
+		if labels[0] != "noproof" {
+			resp.Ns = z.coveringNSEC(name)
+		}
+		return
+	}
+	// NXDOMAIN 同时证明 name 和最近祖先下的通配符都不存在
+	resp.Rcode = dns.RcodeNameError
+	cover := z.coveringNSEC(name)
+	resp.Ns = append(z.withSigs(z.name, dns.TypeSOA), cover...)
+	if wc := z.coveringNSEC("*." + z.closestEncloser(name)); len(wc) > 0 && (len(cover) == 0 || wc[0].Header().Name != cover[0].Header().Name) {
+		resp.Ns = append(resp.Ns, wc...)
+	}
+}
+
+func startTestDNS(t *testing.T) *DNSSECValidator {
+	root := newTestZone(t, ".", true)
+	secure := newTestZone(t, "secure.", true)
+	insecure := newTestZone(t, "insecure.", false)
+
+	secure.add(t, "secure. 3600 IN MX 10 mail.secure.")
+	secure.add(t, "_imaps._tcp.secure. 3600 IN SRV 0 1 993 mail.secure.")
+	secure.add(t, "*.wild.secure. 3600 IN MX 10 mail.secure.")
+	secure.finish(t)
+
+	// 签名内容被篡改、签名已过期的记录，加在 NSEC 链生成之后只是为了不影响其他测试
+	now := time.Now()
+	bad, _ := dns.NewRR("bad.secure. 3600 IN MX 10 mail.secure.")
+	badSig := secure.sign(t, []dns.RR{bad}, now.Add(-time.Hour), now.Add(time.Hour))
+	badSig.Signature = secure.sign(t, secure.rrs[rrKey("secure.", dns.TypeMX)], now.Add(-time.Hour), now.Add(time.Hour)).Signature
+	old, _ := dns.NewRR("old.secure. 3600 IN MX 10 mail.secure.")
+	oldSig := secure.sign(t, []dns.RR{old}, now.Add(-48*time.Hour), now.Add(-24*time.Hour))
+	// 用 secure. 的密钥为 insecure. 下的名字签名（字符串后缀相同但不是祖先）
+	smuggled, _ := dns.NewRR("smuggled.insecure. 3600 IN A 192.0.2.1")
+	smuggledSig := secure.sign(t, []dns.RR{smuggled}, now.Add(-time.Hour), now.Add(time.Hour))
+
+	// 伪造的 NXDOMAIN：带未签名区的 SOA；或者 NSEC 只覆盖 name 而没有否认已存在的通配符
+	foreignSOA := insecure.withSigs("insecure.", dns.TypeSOA)
+	forgedNx := append(secure.withSigs("secure.", dns.TypeSOA), secure.coveringNSEC("forged.wild.secure.")...)
+
+	insecure.add(t, "insecure. 3600 IN MX 10 mail.insecure.")
+
+	root.add(t, ". 3600 IN NS ns.")
+	root.add(t, "secure. 3600 IN NS ns.secure.")
+	root.addRR(secure.key.ToDS(dns.SHA256))
+	root.add(t, "insecure. 3600 IN NS ns.insecure.")
+	root.finish(t)
+
+	h := &testDNS{
+		zones: []*testZone{root, secure, insecure},
+		extra: map[string][]dns.RR{
+			rrKey("bad.secure.", dns.TypeMX):       {bad, badSig},
+			rrKey("old.secure.", dns.TypeMX):       {old, oldSig},
+			rrKey("smuggled.insecure.", dns.TypeA): {smuggled, smuggledSig},
+		},
+		extraNx: map[string][]dns.RR{
+			rrKey("foreign.secure.", dns.TypeMX):     foreignSOA,
+			rrKey("forged.wild.secure.", dns.TypeMX): forgedNx,
+		},
+	}
+
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	started := make(chan struct{})
+	srv := &dns.Server{PacketConn: pc, Handler: h, NotifyStartedFunc: func() { close(started) }}
+	go srv.ActivateAndServe()
+	<-started
+	t.Cleanup(func() { srv.Shutdown() })
+
+	v := &DNSSECValidator{
+		Resolver: pc.LocalAddr().String(),
+		Anchors:  []*dns.DS{root.key.ToDS(dns.SHA256)},
+		Client:   &dns.Client{Net: "udp", Timeout: 2 * time.Second},
+		zones:    make(map[string]*zoneTrust),
+	}
+	return v
+}
+
+func TestDNSSECValidate(t *testing.T) {
+	v := startTestDNS(t)
+	tests := []struct {
+		desc   string
+		name   string
+		qtype  uint16
+		status string
+	}{
+		{"secure MX", "secure.", dns.TypeMX, DNSSECSecure},
+		{"secure SRV", "_imaps._tcp.secure.", dns.TypeSRV, DNSSECSecure},
+		{"insecure without DS", "insecure.", dns.TypeMX, DNSSECInsecure},
+		{"bad signature", "bad.secure.", dns.TypeMX, DNSSECBogus},
+		{"expired signature", "old.secure.", dns.TypeMX, DNSSECBogus},
+		{"NXDOMAIN", "nonexistent.secure.", dns.TypeMX, DNSSECSecure},
+		{"NODATA", "secure.", dns.TypeTXT, DNSSECSecure},
+		{"wildcard with proof", "host.wild.secure.", dns.TypeMX, DNSSECSecure},
+		{"wildcard without proof", "noproof.wild.secure.", dns.TypeMX, DNSSECBogus},
+		{"signer not an ancestor", "smuggled.insecure.", dns.TypeA, DNSSECBogus},
+		{"foreign SOA", "foreign.secure.", dns.TypeMX, DNSSECBogus},
+		{"NXDOMAIN with existing wildcard", "forged.wild.secure.", dns.TypeMX, DNSSECBogus},
+		{"NXDOMAIN below empty non-terminal", "x._tcp.secure.", dns.TypeMX, DNSSECSecure},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			got := v.Validate(tt.name, tt.qtype)
+			if got.Status != tt.status {
+				t.Errorf("Validate(%s, %s) = %s (%s), want %s", tt.name, dns.TypeToString[tt.qtype], got.Status, got.Reason, tt.status)
+			}
+		})
+	}
+}
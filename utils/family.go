@@ -0,0 +1,21 @@
+package utils
+
+import "net"
+
+// FilterFamily 按拨号网络名筛选地址：tcp4 只留 IPv4，tcp6 只留 IPv6，其他原样返回
+func FilterFamily(addrs []string, network string) []string {
+	if network != "tcp4" && network != "tcp6" {
+		return addrs
+	}
+	var filtered []string
+	for _, addr := range addrs {
+		ip := net.ParseIP(addr)
+		if ip == nil {
+			continue
+		}
+		if (ip.To4() != nil) == (network == "tcp4") {
+			filtered = append(filtered, addr)
+		}
+	}
+	return filtered
+}
@@ -11,6 +11,10 @@ func GetRedirects(resp *http.Response) (history []map[string]interface{}) {
 			"URL":    req.URL.String(),
 			"Status": status,
 		}
+		// 每个 HTTPS 跳都记录证书，便于统计非配置端点的证书问题
+		if resp.TLS != nil {
+			entry["CertInfo"] = BuildCertInfo(resp.TLS, req.URL.Hostname())
+		}
 		history = append(history, entry)
 		resp = resp.Request.Response
 	}
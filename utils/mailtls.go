@@ -0,0 +1,138 @@
+package utils
+
+import (
+	"bufio"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"scan-website/config"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 邮件端口的默认协议和 TLS 方式
+var mailPortDefaults = map[int][2]string{
+	25:  {"smtp", "starttls"},
+	465: {"smtp", "tls"},
+	587: {"smtp", "starttls"},
+	143: {"imap", "starttls"},
+	993: {"imap", "tls"},
+	110: {"pop3", "starttls"},
+	995: {"pop3", "tls"},
+}
+
+// MailPortDefaults 返回端口对应的协议和 TLS 方式，未知端口返回空
+func MailPortDefaults(port int) (protocol, mode string) {
+	d := mailPortDefaults[port]
+	return d[0], d[1]
+}
+
+// MailTLSHandshake 连接邮件服务器并完成 TLS 握手（mode 为 starttls 时先走明文协商），
+// 不验证证书，只返回连接状态供后续分析
+func MailTLSHandshake(host string, port int, protocol, mode string, timeout time.Duration) (*tls.ConnectionState, error) {
+	addr := net.JoinHostPort(host, strconv.Itoa(port))
+	conn, err := net.DialTimeout(config.Get().Network(), addr, timeout)
+	if err != nil {
+		return nil, fmt.Errorf("dial %s failed: %v", addr, err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(timeout))
+
+	if mode == "starttls" {
+		if err := startTLS(conn, protocol); err != nil {
+			return nil, fmt.Errorf("STARTTLS on %s failed: %v", addr, err)
+		}
+	}
+	tlsConn := tls.Client(conn, &tls.Config{
+		ServerName:         host,
+		InsecureSkipVerify: true,
+		MinVersion:         tls.VersionTLS10,
+	})
+	if err := tlsConn.Handshake(); err != nil {
+		return nil, fmt.Errorf("TLS handshake with %s failed: %v", addr, err)
+	}
+	state := tlsConn.ConnectionState()
+	return &state, nil
+}
+
+func startTLS(conn net.Conn, protocol string) error {
+	r := bufio.NewReader(conn)
+	switch protocol {
+	case "smtp":
+		if _, err := readSMTPReply(r, "220"); err != nil {
+			return err
+		}
+		fmt.Fprintf(conn, "EHLO scanner.invalid\r\n")
+		lines, err := readSMTPReply(r, "250")
+		if err != nil {
+			return err
+		}
+		if !strings.Contains(strings.ToUpper(strings.Join(lines, "\n")), "STARTTLS") {
+			return fmt.Errorf("STARTTLS not advertised")
+		}
+		fmt.Fprintf(conn, "STARTTLS\r\n")
+		_, err = readSMTPReply(r, "220")
+		return err
+	case "imap":
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return err
+		}
+		if !strings.HasPrefix(line, "* OK") {
+			return fmt.Errorf("unexpected greeting: %s", strings.TrimSpace(line))
+		}
+		fmt.Fprintf(conn, "a1 STARTTLS\r\n")
+		for {
+			line, err = r.ReadString('\n')
+			if err != nil {
+				return err
+			}
+			if strings.HasPrefix(line, "a1 ") {
+				if !strings.HasPrefix(strings.ToUpper(line), "A1 OK") {
+					return fmt.Errorf("STARTTLS rejected: %s", strings.TrimSpace(line))
+				}
+				return nil
+			}
+		}
+	case "pop3":
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return err
+		}
+		if !strings.HasPrefix(line, "+OK") {
+			return fmt.Errorf("unexpected greeting: %s", strings.TrimSpace(line))
+		}
+		fmt.Fprintf(conn, "STLS\r\n")
+		line, err = r.ReadString('\n')
+		if err != nil {
+			return err
+		}
+		if !strings.HasPrefix(line, "+OK") {
+			return fmt.Errorf("STLS rejected: %s", strings.TrimSpace(line))
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown protocol %q", protocol)
+	}
+}
+
+// 读取一个（可能多行的）SMTP 应答并检查状态码
+func readSMTPReply(r *bufio.Reader, code string) ([]string, error) {
+	var lines []string
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return lines, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		lines = append(lines, line)
+		if len(line) < 4 || line[3] != '-' {
+			break
+		}
+	}
+	if !strings.HasPrefix(lines[len(lines)-1], code) {
+		return lines, fmt.Errorf("unexpected reply: %s", lines[len(lines)-1])
+	}
+	return lines, nil
+}
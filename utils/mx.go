@@ -0,0 +1,118 @@
+package utils
+
+import (
+	"fmt"
+	"scan-website/config"
+	"scan-website/models"
+	"sort"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// LookupMX 查询域名的全部 MX 记录（按优先级排序），并解析每个主机的 A/AAAA 地址；服务商由 provider 包标注
+func LookupMX(domain string) (*models.MXResult, error) {
+	response, err := exchange(domain, dns.TypeMX)
+	if err != nil {
+		return nil, err
+	}
+	if response.Rcode != dns.RcodeSuccess {
+		return nil, fmt.Errorf("DNS query failed with Rcode %d", response.Rcode)
+	}
+
+	result := &models.MXResult{}
+	for _, ans := range response.Answer {
+		if mx, ok := ans.(*dns.MX); ok {
+			result.Records = append(result.Records, models.MXRecord{
+				Host:       strings.TrimSuffix(mx.Mx, "."),
+				Preference: mx.Preference,
+			})
+		}
+	}
+	// RFC 7505：唯一一条 "0 ." 表示不接收邮件
+	if len(result.Records) == 1 && result.Records[0].Host == "" && result.Records[0].Preference == 0 {
+		result.NullMX = true
+		result.Records = nil
+		return result, nil
+	}
+	if len(result.Records) == 0 {
+		return nil, fmt.Errorf("no MX Record")
+	}
+	sort.SliceStable(result.Records, func(i, j int) bool {
+		return result.Records[i].Preference < result.Records[j].Preference
+	})
+	for i := range result.Records {
+		r := &result.Records[i]
+		if r.Host == "" { // 与其他记录混在一起的 "."，不可用
+			continue
+		}
+		r.A, _ = LookupAddrs(r.Host, dns.TypeA)
+		r.AAAA, _ = LookupAddrs(r.Host, dns.TypeAAAA)
+	}
+	return result, nil
+}
+
+// LookupAddrs 查询主机的 A 或 AAAA 地址（跟随应答中的 CNAME）
+func LookupAddrs(host string, qtype uint16) ([]string, error) {
+	response, err := exchange(host, qtype)
+	if err != nil {
+		return nil, err
+	}
+	var addrs []string
+	for _, ans := range response.Answer {
+		switch rr := ans.(type) {
+		case *dns.A:
+			addrs = append(addrs, rr.A.String())
+		case *dns.AAAA:
+			addrs = append(addrs, rr.AAAA.String())
+		}
+	}
+	return addrs, nil
+}
+
+// 发送一次递归查询，应答被截断时改用 TCP
+func exchange(name string, qtype uint16) (*dns.Msg, error) {
+	client := &dns.Client{
+		Net:     "udp",
+		Timeout: config.Get().Timeouts.DNS.Std(),
+	}
+	msg := new(dns.Msg)
+	msg.SetQuestion(dns.Fqdn(name), qtype)
+	msg.RecursionDesired = true
+	msg.SetEdns0(4096, false)
+
+	resolver := config.Get().Resolver()
+	response, _, err := client.Exchange(msg, resolver)
+	if err == nil && response.Truncated {
+		client.Net = "tcp"
+		response, _, err = client.Exchange(msg, resolver)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("DNS query for %s failed: %v", name, err)
+	}
+	return response, nil
+}
+
+// MXDomains 返回 MX 主机按可注册域名去重后的列表（每个可注册域名取优先级最高的主机），
+// allMX 为 false 时只返回优先级最高的主机
+func MXDomains(mx *models.MXResult, allMX bool) []string {
+	seen := make(map[string]bool)
+	var hosts []string
+	for _, r := range mx.Records {
+		if r.Host == "" {
+			continue
+		}
+		if !allMX {
+			return []string{r.Host}
+		}
+		_, mainDomain, err := ExtractDomains(r.Host)
+		if err != nil {
+			mainDomain = r.Host
+		}
+		if !seen[mainDomain] {
+			seen[mainDomain] = true
+			hosts = append(hosts, r.Host)
+		}
+	}
+	return hosts
+}
@@ -0,0 +1,99 @@
+package utils
+
+import (
+	"math/rand"
+	"sort"
+
+	"github.com/miekg/dns"
+)
+
+// SortSRV 按优先级升序、权重降序、目标、端口排出确定的顺序，用于保存结果，
+// 同样的 DNS 数据每次扫描得到同样的输出
+func SortSRV(records []*dns.SRV) []*dns.SRV {
+	sorted := append([]*dns.SRV(nil), records...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, b := sorted[i], sorted[j]
+		if a.Priority != b.Priority {
+			return a.Priority < b.Priority
+		}
+		if a.Weight != b.Weight {
+			return a.Weight > b.Weight
+		}
+		if a.Target != b.Target {
+			return a.Target < b.Target
+		}
+		return a.Port < b.Port
+	})
+	return sorted
+}
+
+// OrderSRV 按 RFC 2782 给出客户端应尝试的顺序：优先级小的在前，
+// 同一优先级内按权重随机选取（权重为 0 的先放在前面，只有在其他记录都不可选时才有机会被选中）。
+// 结果每次不同，只在真正要挑选连接目标时使用，保存的记录用 SortSRV
+func OrderSRV(records []*dns.SRV) []*dns.SRV {
+	byPriority := make(map[uint16][]*dns.SRV)
+	var priorities []uint16
+	for _, r := range records {
+		if _, ok := byPriority[r.Priority]; !ok {
+			priorities = append(priorities, r.Priority)
+		}
+		byPriority[r.Priority] = append(byPriority[r.Priority], r)
+	}
+	sort.Slice(priorities, func(i, j int) bool { return priorities[i] < priorities[j] })
+
+	ordered := make([]*dns.SRV, 0, len(records))
+	for _, p := range priorities {
+		ordered = append(ordered, weightedOrder(byPriority[p])...)
+	}
+	return ordered
+}
+
+func weightedOrder(group []*dns.SRV) []*dns.SRV {
+	var remaining []*dns.SRV
+	for _, r := range group {
+		if r.Weight == 0 {
+			remaining = append(remaining, r)
+		}
+	}
+	for _, r := range group {
+		if r.Weight != 0 {
+			remaining = append(remaining, r)
+		}
+	}
+
+	ordered := make([]*dns.SRV, 0, len(group))
+	for len(remaining) > 0 {
+		total := 0
+		for _, r := range remaining {
+			total += int(r.Weight)
+		}
+		pick := rand.Intn(total + 1) // [0, total]
+		sum, idx := 0, 0
+		for i, r := range remaining {
+			sum += int(r.Weight)
+			if sum >= pick {
+				idx = i
+				break
+			}
+		}
+		ordered = append(ordered, remaining[idx])
+		remaining = append(remaining[:idx], remaining[idx+1:]...)
+	}
+	return ordered
+}
+
+// IsSRVNotOffered 唯一一条记录的目标为 "." 表示该服务明确不提供（RFC 2782）
+func IsSRVNotOffered(records []*dns.SRV) bool {
+	return len(records) == 1 && records[0].Target == "."
+}
+
+// FilterSRVTargets 去掉目标为 "." 的记录（与其他记录同时出现时没有意义）
+func FilterSRVTargets(records []*dns.SRV) []*dns.SRV {
+	var filtered []*dns.SRV
+	for _, r := range records {
+		if r.Target != "." {
+			filtered = append(filtered, r)
+		}
+	}
+	return filtered
+}
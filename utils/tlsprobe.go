@@ -0,0 +1,69 @@
+package utils
+
+import (
+	"crypto/tls"
+	"net"
+	"scan-website/config"
+	"scan-website/models"
+	"time"
+)
+
+var probeVersions = []uint16{tls.VersionTLS10, tls.VersionTLS11, tls.VersionTLS12, tls.VersionTLS13}
+
+// 探测时提供包括不安全套件在内的全部套件，避免只支持旧套件的服务器被误判为不支持该版本
+var probeCipherSuites = func() []uint16 {
+	var ids []uint16
+	for _, cs := range tls.CipherSuites() {
+		ids = append(ids, cs.ID)
+	}
+	for _, cs := range tls.InsecureCipherSuites() {
+		ids = append(ids, cs.ID)
+	}
+	return ids
+}()
+
+// BuildTLSSession 记录协商得到的 TLS 会话参数
+func BuildTLSSession(state *tls.ConnectionState) *models.TLSSessionInfo {
+	if state == nil {
+		return nil
+	}
+	return &models.TLSSessionInfo{
+		Version:     tls.VersionName(state.Version),
+		CipherSuite: tls.CipherSuiteName(state.CipherSuite),
+		ALPN:        state.NegotiatedProtocol,
+		DidResume:   state.DidResume,
+		OCSPStapled: len(state.OCSPResponse) > 0,
+		SNI:         state.ServerName,
+	}
+}
+
+// ProbeTLSVersions 分别只允许 TLS 1.0~1.3 中的一个版本进行握手，记录服务器接受哪些版本
+func ProbeTLSVersions(host, port string, timeout time.Duration) models.TLSVersionProbe {
+	probe := models.TLSVersionProbe{
+		Host:     host,
+		Port:     port,
+		Versions: make(map[string]bool),
+	}
+	addr := net.JoinHostPort(host, port)
+	network := config.Get().Network()
+	for _, v := range probeVersions {
+		conf := &tls.Config{
+			ServerName:         host,
+			InsecureSkipVerify: true,
+			MinVersion:         v,
+			MaxVersion:         v,
+			CipherSuites:       probeCipherSuites,
+		}
+		rawConn, err := net.DialTimeout(network, addr, timeout)
+		if err != nil {
+			// TCP 都连不上就不用再试其他版本了
+			probe.Error = err.Error()
+			break
+		}
+		rawConn.SetDeadline(time.Now().Add(timeout))
+		conn := tls.Client(rawConn, conf)
+		probe.Versions[tls.VersionName(v)] = conn.Handshake() == nil
+		conn.Close()
+	}
+	return probe
+}
@@ -0,0 +1,294 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"scan-website/config"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/zakjan/cert-chain-resolver/certUtil"
+)
+
+// IntermediateFetcher 为缺失的中间证书提供补全途径（如 AIA 下载），返回的证书不含 leaf
+type IntermediateFetcher func(cert *x509.Certificate) ([]*x509.Certificate, error)
+
+// AIAFetcher 通过证书中的 AIA 地址下载颁发者证书
+func AIAFetcher(cert *x509.Certificate) ([]*x509.Certificate, error) {
+	chain, err := certUtil.FetchCertificateChain(cert)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch certificate chain:%v", err)
+	}
+	if len(chain) > 0 && chain[0].Equal(cert) {
+		chain = chain[1:]
+	}
+	return chain, nil
+}
+
+type trustStore struct {
+	name string
+	pool *x509.CertPool
+}
+
+// CertVerifier 一次性加载多个信任库，并缓存中间证书，避免每次验证都读文件、联网
+type CertVerifier struct {
+	stores []trustStore
+
+	mu            sync.RWMutex
+	intermediates *x509.CertPool
+	known         map[string]struct{}            // 已在中间证书池中的证书指纹
+	fetched       map[string][]*x509.Certificate // AIA 地址 -> 下载到的证书
+
+	Fetcher IntermediateFetcher // 为空时不补全
+}
+
+// VerifyResult 记录各信任库的验证结果
+type VerifyResult struct {
+	TrustedBy []string          // 信任该证书链的信任库
+	Errors    map[string]string // 不信任的信任库 -> 错误信息
+	Fetched   bool              // 是否使用了补全得到的中间证书
+	FetchErr  error
+}
+
+func NewCertVerifier() *CertVerifier {
+	return &CertVerifier{
+		intermediates: x509.NewCertPool(),
+		known:         make(map[string]struct{}),
+		fetched:       make(map[string][]*x509.Certificate),
+	}
+}
+
+func (v *CertVerifier) AddStore(name string, pool *x509.CertPool) {
+	v.stores = append(v.stores, trustStore{name: name, pool: pool})
+}
+
+func (v *CertVerifier) AddStoreFromPEMFile(name, pemFile string) error {
+	pem, err := os.ReadFile(pemFile)
+	if err != nil {
+		return fmt.Errorf("failed to read root certificate:%v", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return fmt.Errorf("failed to import root certificate from %s", pemFile)
+	}
+	v.AddStore(name, pool)
+	return nil
+}
+
+func (v *CertVerifier) AddSystemStore() error {
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		return fmt.Errorf("failed to load system roots:%v", err)
+	}
+	v.AddStore("system", pool)
+	return nil
+}
+
+// StoreNames 返回已加载的信任库名称
+func (v *CertVerifier) StoreNames() []string {
+	var names []string
+	for _, s := range v.stores {
+		names = append(names, s.name)
+	}
+	return names
+}
+
+// AddIntermediates 把中间证书加入缓存池，后续所有验证都可使用
+func (v *CertVerifier) AddIntermediates(certs []*x509.Certificate) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	for _, c := range certs {
+		fp := certFingerprint(c)
+		if _, ok := v.known[fp]; ok {
+			continue
+		}
+		v.known[fp] = struct{}{}
+		v.intermediates.AddCert(c)
+	}
+}
+
+func (v *CertVerifier) AddIntermediatesFromPEMFile(pemFile string) error {
+	pem, err := os.ReadFile(pemFile)
+	if err != nil {
+		return fmt.Errorf("failed to read intermediate certificates:%v", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return fmt.Errorf("failed to import intermediate certificates from %s", pemFile)
+	}
+	v.mu.Lock()
+	v.intermediates = pool
+	v.known = make(map[string]struct{})
+	v.mu.Unlock()
+	return nil
+}
+
+// Verify 用所有信任库分别验证证书链，dnsName 为空时不校验主机名
+func (v *CertVerifier) Verify(chain []*x509.Certificate, dnsName string) *VerifyResult {
+	result := &VerifyResult{Errors: make(map[string]string)}
+	if len(chain) == 0 {
+		result.Errors["chain"] = "empty certificate chain"
+		return result
+	}
+
+	v.mu.RLock()
+	pool := v.intermediates.Clone()
+	v.mu.RUnlock()
+	for _, c := range chain[1:] {
+		pool.AddCert(c)
+	}
+	v.verifyStores(result, chain[0], pool, dnsName)
+
+	// 所有信任库都因缺少颁发者失败时再尝试补全
+	if len(result.TrustedBy) == 0 && v.Fetcher != nil && allUnknownAuthority(result) {
+		extra, err := v.fetch(chain[0])
+		if err != nil {
+			result.FetchErr = err
+			return result
+		}
+		for _, c := range extra {
+			pool.AddCert(c)
+		}
+		result.Fetched = true
+		result.Errors = make(map[string]string)
+		v.verifyStores(result, chain[0], pool, dnsName)
+	}
+	return result
+}
+
+// IssuedByRoot 判断证书是否直接由某个信任库中的根证书签发（不借助任何中间证书）
+func (v *CertVerifier) IssuedByRoot(cert *x509.Certificate) bool {
+	for _, s := range v.stores {
+		opts := x509.VerifyOptions{
+			Roots:       s.pool,
+			KeyUsages:   []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+			CurrentTime: cert.NotBefore.Add(cert.NotAfter.Sub(cert.NotBefore) / 2), // 只看签发关系，不看是否过期
+		}
+		if _, err := cert.Verify(opts); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+func (v *CertVerifier) verifyStores(result *VerifyResult, leaf *x509.Certificate, pool *x509.CertPool, dnsName string) {
+	for _, s := range v.stores {
+		opts := x509.VerifyOptions{
+			Roots:         s.pool,
+			Intermediates: pool,
+			DNSName:       dnsName,
+		}
+		if _, err := leaf.Verify(opts); err != nil {
+			result.Errors[s.name] = err.Error()
+		} else {
+			result.TrustedBy = append(result.TrustedBy, s.name)
+		}
+	}
+}
+
+// 按 AIA 地址缓存下载结果，同一颁发者只下载一次
+func (v *CertVerifier) fetch(leaf *x509.Certificate) ([]*x509.Certificate, error) {
+	key := strings.Join(leaf.IssuingCertificateURL, ",")
+	if key != "" {
+		v.mu.RLock()
+		certs, ok := v.fetched[key]
+		v.mu.RUnlock()
+		if ok {
+			return certs, nil
+		}
+	}
+	certs, err := v.Fetcher(leaf)
+	if err != nil {
+		return nil, err
+	}
+	if key != "" {
+		v.mu.Lock()
+		v.fetched[key] = certs
+		v.mu.Unlock()
+	}
+	return certs, nil
+}
+
+func allUnknownAuthority(result *VerifyResult) bool {
+	if len(result.Errors) == 0 {
+		return false
+	}
+	for _, e := range result.Errors {
+		if !strings.Contains(e, "certificate signed by unknown authority") {
+			return false
+		}
+	}
+	return true
+}
+
+// Error 汇总为一条错误，全部信任时返回 nil
+func (r *VerifyResult) Error() error {
+	if len(r.TrustedBy) > 0 {
+		return nil
+	}
+	if r.FetchErr != nil {
+		return r.FetchErr
+	}
+	if len(r.Errors) == 0 {
+		return errors.New("certificate verify failed: no trust store loaded")
+	}
+	var names []string
+	for name := range r.Errors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var msgs []string
+	for _, name := range names {
+		msgs = append(msgs, fmt.Sprintf("%s: %s", name, r.Errors[name]))
+	}
+	return fmt.Errorf("certificate verify failed: %s", strings.Join(msgs, "; "))
+}
+
+func certFingerprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	return hex.EncodeToString(sum[:])
+}
+
+var (
+	defaultVerifier     *CertVerifier
+	defaultVerifierErr  error
+	defaultVerifierOnce sync.Once
+)
+
+// DefaultVerifier 按配置中的 trust_stores/use_system_store/fetch_aia 构造，只加载一次
+func DefaultVerifier() (*CertVerifier, error) {
+	defaultVerifierOnce.Do(func() {
+		cfg := config.Get()
+		v := NewCertVerifier()
+		var names []string
+		for name := range cfg.TrustStores {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if cfg.TrustStores[name] == "" {
+				continue
+			}
+			if err := v.AddStoreFromPEMFile(name, cfg.TrustStores[name]); err != nil {
+				defaultVerifierErr = err
+				return
+			}
+		}
+		if cfg.UseSystemStore {
+			if err := v.AddSystemStore(); err != nil {
+				defaultVerifierErr = err
+				return
+			}
+		}
+		if cfg.FetchAIA {
+			v.Fetcher = AIAFetcher
+		}
+		defaultVerifier = v
+	})
+	return defaultVerifier, defaultVerifierErr
+}
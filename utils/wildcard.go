@@ -0,0 +1,53 @@
+package utils
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"scan-website/models"
+
+	"github.com/miekg/dns"
+)
+
+// DetectWildcard 解析域名下一个随机子域名，能解析说明存在通配符记录；没有通配符时返回 nil
+func DetectWildcard(domain string) (*models.WildcardInfo, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	probe := "wc-" + hex.EncodeToString(b) + "." + domain
+	ips, err := LookupHostAddrs(probe)
+	if err != nil {
+		return nil, err
+	}
+	if len(ips) == 0 {
+		return nil, nil
+	}
+	return &models.WildcardInfo{Probe: probe, IPs: ips}, nil
+}
+
+// LookupHostAddrs 查询主机的 A 和 AAAA 地址
+func LookupHostAddrs(host string) ([]string, error) {
+	a, err := LookupAddrs(host, dns.TypeA)
+	if err != nil {
+		return nil, err
+	}
+	aaaa, _ := LookupAddrs(host, dns.TypeAAAA)
+	return append(a, aaaa...), nil
+}
+
+// MatchesWildcard 判断一组地址是否全部落在通配符解析结果中（即该主机很可能只是通配符命中）
+func MatchesWildcard(w *models.WildcardInfo, ips []string) bool {
+	if w == nil || len(ips) == 0 {
+		return false
+	}
+	set := make(map[string]bool, len(w.IPs))
+	for _, ip := range w.IPs {
+		set[ip] = true
+	}
+	for _, ip := range ips {
+		if !set[ip] {
+			return false
+		}
+	}
+	return true
+}